@@ -0,0 +1,41 @@
+// Package api embeds api.yaml, the OpenAPI description of the server.
+package api
+
+import (
+	"context"
+	_ "embed"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+//go:embed api.yaml
+var Spec []byte
+
+// Load parses and validates Spec.
+func Load() (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(Spec)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// NewRouter loads Spec and returns a router finding its operations under
+// the prefix of every server it lists, whichever host serves them.
+func NewRouter() (routers.Router, error) {
+	doc, err := Load()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return gorillamux.NewRouter(doc)
+}
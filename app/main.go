@@ -1,46 +1,149 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"rest-api/design-pattern/api"
 	"rest-api/design-pattern/config"
 
+	"rest-api/design-pattern/delivery/common"
+	_auditController "rest-api/design-pattern/delivery/controller/audit"
 	_authController "rest-api/design-pattern/delivery/controller/auth"
 	_bookController "rest-api/design-pattern/delivery/controller/book"
+	_docsController "rest-api/design-pattern/delivery/controller/docs"
 	_productController "rest-api/design-pattern/delivery/controller/product"
 	_userController "rest-api/design-pattern/delivery/controller/user"
 	"rest-api/design-pattern/delivery/midware"
 	"rest-api/design-pattern/delivery/router"
 
+	_auditRepo "rest-api/design-pattern/repository/audit"
 	_authRepo "rest-api/design-pattern/repository/auth"
 	_bookRepo "rest-api/design-pattern/repository/book"
 	_productRepo "rest-api/design-pattern/repository/product"
+	_transaction "rest-api/design-pattern/repository/transaction"
 	_userRepo "rest-api/design-pattern/repository/user"
+	_auditService "rest-api/design-pattern/service/audit"
+	_authService "rest-api/design-pattern/service/auth"
+	_bookService "rest-api/design-pattern/service/book"
+	_productService "rest-api/design-pattern/service/product"
+	_purgeService "rest-api/design-pattern/service/purge"
+	_userService "rest-api/design-pattern/service/user"
 	"rest-api/design-pattern/util"
+	"rest-api/design-pattern/util/password"
+	"rest-api/design-pattern/util/token"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/labstack/gommon/log"
 )
 
 func main() {
-	fallback := config.AppConfig{Type: "main"}
-	config := config.GetConfig(&fallback)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(migrate(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "user" {
+		os.Exit(user(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	config, err := config.Load(os.Args[1:])
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
 	db := util.GetDBInstance(config)
 	defer db.Close()
 
+	autoMigrate(config)
+
+	hasher, err := password.New(config.PasswordHasher)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	tokens, err := token.FromConfig(config)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	spec, err := api.NewRouter()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	midware.SetTokenService(tokens)
+	common.SetErrorFormat(config.ErrorFormat)
+	common.SetRequireIfMatch(config.RequireIfMatch)
+
+	auditRepo := _auditRepo.New(db)
 	authRepo := _authRepo.New(db)
-	bookRepo := _bookRepo.New(db)
+	bookRepo := _bookRepo.New(db, config.Driver)
 	productRepo := _productRepo.New(db)
-	userRepo := _userRepo.New(db)
+	userRepo := _userRepo.New(db, hasher)
+	transactions := _transaction.New(db, bookRepo, productRepo, userRepo, auditRepo, authRepo)
+
+	auditService := _auditService.New(auditRepo)
+	authService := _authService.New(authRepo, hasher, tokens, config.RefreshTokenTTL)
+	bookService := _bookService.New(bookRepo, transactions)
+	productService := _productService.New(productRepo, transactions)
+	userService := _userService.New(userRepo, transactions)
+	purgeService := _purgeService.New(config.PurgeRetention, productRepo, bookRepo, userRepo, authRepo)
 
-	authController := _authController.New(authRepo)
-	bookController := _bookController.New(bookRepo)
-	productController := _productController.New(productRepo)
-	userController := _userController.New(userRepo)
+	midware.SetRevocationChecker(authService)
+
+	authController := _authController.New(authService)
+	bookController := _bookController.New(bookService)
+	productController := _productController.New(productService)
+	auditController := _auditController.New(auditService)
+	userController := _userController.New(userService)
+	docsController := _docsController.New(api.Spec)
 
 	e := echo.New()
-	e.Pre(middleware.RemoveTrailingSlash(), midware.CustomLogger())
+	e.HTTPErrorHandler = common.HTTPErrorHandler
+	e.Logger.SetLevel(logLevel(config.LogLevel))
+	e.Server.ReadTimeout = config.ReadTimeout
+	e.Server.WriteTimeout = config.WriteTimeout
+	e.Pre(middleware.RemoveTrailingSlash(), midware.RequestID(), midware.CustomLogger())
+	e.Use(midware.RequestTimeout(config.RequestTimeout))
+
+	deprecations := router.Deprecations{}
 
-	router.RegisterPath(e, authController, bookController, userController, productController)
+	for _, deprecation := range config.Deprecations {
+		deprecations[deprecation.Route] = midware.Deprecation{At: deprecation.At, Sunset: deprecation.Sunset}
+	}
+
+	if err := router.RegisterPath(e, authController, bookController, userController, productController, auditController, docsController, deprecations, midware.ValidateRequests(spec)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if config.PurgeInterval > 0 {
+		go purgeService.Schedule(context.Background(), config.PurgeInterval, e.Logger)
+	}
+
+	e.Logger.Fatal(e.Start(config.Address))
+}
 
-	e.Logger.Fatal(e.Start((":8080")))
+func logLevel(level string) log.Lvl {
+	switch level {
+	case "debug":
+		return log.DEBUG
+	case "warn":
+		return log.WARN
+	case "error":
+		return log.ERROR
+	case "off":
+		return log.OFF
+	default:
+		return log.INFO
+	}
 }
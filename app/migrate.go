@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"rest-api/design-pattern/config"
+	"rest-api/design-pattern/migrations"
+	"rest-api/design-pattern/util"
+	"strconv"
+)
+
+const migrateUsage = `usage: app migrate <command> [configuration flags]
+
+commands:
+  up            apply every pending migration
+  down          revert the most recently applied migration
+  status        list migrations and when they were applied
+  to <version>  migrate up or down to version, 0 reverts everything`
+
+// migrate runs the migrate subcommand on args, the command line following
+// "migrate", and returns the exit code.
+func migrate(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, migrateUsage)
+		return 2
+	}
+
+	command, args := args[0], args[1:]
+	version := 0
+
+	switch command {
+	case "up", "down", "status", "to":
+	default:
+		fmt.Fprintln(stderr, migrateUsage)
+		return 2
+	}
+
+	if command == "to" {
+		if len(args) == 0 {
+			fmt.Fprintln(stderr, migrateUsage)
+			return 2
+		}
+
+		v, err := strconv.Atoi(args[0])
+
+		if err != nil || v < 0 {
+			fmt.Fprintf(stderr, "invalid migration version %v\n", args[0])
+			return 2
+		}
+
+		version, args = v, args[1:]
+	}
+
+	cfg, err := config.Load(args)
+
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	db := util.GetDBInstance(cfg)
+	defer db.Close()
+
+	migrator, err := migrations.New(db)
+
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	var done []migrations.Migration
+
+	switch command {
+	case "up":
+		done, err = migrator.Up()
+	case "down":
+		done, err = migrator.Down()
+	case "to":
+		done, err = migrator.To(version)
+	case "status":
+		return status(migrator, stdout, stderr)
+	}
+
+	for _, m := range done {
+		fmt.Fprintf(stdout, "%v %04d_%v\n", command, m.Version, m.Name)
+	}
+
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if len(done) == 0 {
+		fmt.Fprintln(stdout, "nothing to migrate")
+	}
+
+	return 0
+}
+
+func status(migrator *migrations.Migrator, stdout io.Writer, stderr io.Writer) int {
+	statuses, err := migrator.Status()
+
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	for _, s := range statuses {
+		applied := "pending"
+
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+
+		fmt.Fprintf(stdout, "%04d_%-30v %v\n", s.Version, s.Name, applied)
+	}
+
+	return 0
+}
+
+// autoMigrate applies pending migrations on startup when the configuration
+// asks for it, which it only can in the development profile.
+func autoMigrate(cfg *config.AppConfig) {
+	if !cfg.AutoMigrate {
+		return
+	}
+
+	migrator, err := migrations.New(util.GetDBInstance(cfg))
+
+	if err == nil {
+		var done []migrations.Migration
+
+		done, err = migrator.Up()
+
+		for _, m := range done {
+			fmt.Fprintf(os.Stdout, "migrated %04d_%v\n", m.Version, m.Name)
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
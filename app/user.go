@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"rest-api/design-pattern/config"
+	"rest-api/design-pattern/domain"
+	_auditRepo "rest-api/design-pattern/repository/audit"
+	_authRepo "rest-api/design-pattern/repository/auth"
+	_bookRepo "rest-api/design-pattern/repository/book"
+	_productRepo "rest-api/design-pattern/repository/product"
+	_transaction "rest-api/design-pattern/repository/transaction"
+	_userRepo "rest-api/design-pattern/repository/user"
+	_userService "rest-api/design-pattern/service/user"
+	"rest-api/design-pattern/util"
+	"rest-api/design-pattern/util/password"
+	"strconv"
+)
+
+const userUsage = `usage: app user <command> [configuration flags]
+
+commands:
+  set-role <id> <role>  set the role of the user with id to admin, merchant
+                        or customer, as a way to make the first admin`
+
+// user runs the user subcommand on args, the command line following "user",
+// and returns the exit code. Changes are made as domain.System and audited
+// as made by no one.
+func user(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) < 3 || args[0] != "set-role" {
+		fmt.Fprintln(stderr, userUsage)
+		return 2
+	}
+
+	id, err := strconv.Atoi(args[1])
+
+	if err != nil || id < 1 {
+		fmt.Fprintf(stderr, "invalid user id %v\n", args[1])
+		return 2
+	}
+
+	role := args[2]
+
+	cfg, err := config.Load(args[3:])
+
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	hasher, err := password.New(cfg.PasswordHasher)
+
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	db := util.GetDBInstance(cfg)
+	defer db.Close()
+
+	userRepo := _userRepo.New(db, hasher)
+	transactions := _transaction.New(db, _bookRepo.New(db, cfg.Driver), _productRepo.New(db), userRepo, _auditRepo.New(db), _authRepo.New(db))
+
+	if err := _userService.New(userRepo, transactions).SetRole(context.Background(), domain.System, id, role); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "user %v is now %v\n", id, role)
+
+	return 0
+}
@@ -1,30 +1,522 @@
 package config
 
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Profile string
+
+const (
+	Development Profile = "development"
+	Test        Profile = "test"
+	Production  Profile = "production"
+)
+
 type AppConfig struct {
-	Type     string
-	Driver   string
-	Username string
-	Password string
-	DBName   string
+	Type            Profile
+	Driver          string
+	Username        string
+	Password        string
+	DBName          string
+	DBHost          string
+	DBPort          int
+	AutoMigrate     bool
+	Address         string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	RequestTimeout  time.Duration
+	ErrorFormat     string
+	JWTSecret       string
+	JWTKeyID        string
+	JWTKeysDir      string
+	JWTActiveKey    string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	LogLevel        string
+	PasswordHasher  string
+	Deprecations    []RouteDeprecation
+	RequireIfMatch  bool
+	PurgeRetention  time.Duration
+	PurgeInterval   time.Duration
+}
+
+// RouteDeprecation is a route of the API, such as GET /v1/books, deprecated
+// since At and, unless Sunset is zero, removed at Sunset.
+type RouteDeprecation struct {
+	Route  string
+	At     time.Time
+	Sunset time.Time
 }
 
+// EnvPrefix is prepended to every configuration key to form the name of the
+// environment variable overriding it, e.g. database.host is read from
+// APP_DATABASE_HOST.
+const EnvPrefix = "APP_"
+
 var config *AppConfig
 
-var main AppConfig = AppConfig{
-	Driver:   "mysql",
-	Username: "gotama",
-	Password: "jaladri24",
-	DBName:   "db_sirclo",
+// Load builds the application configuration from, in increasing order of
+// precedence, the defaults of the selected profile, the YAML file named by
+// -config or APP_CONFIG, APP_* environment variables and command line flags.
+// Every key can be set at each layer, including the profile itself.
+func Load(args []string) (*AppConfig, error) {
+	values := map[string]string{}
+
+	flags, path, err := parseFlags(args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if path == "" {
+		path = os.Getenv(EnvPrefix + "CONFIG")
+	}
+
+	if path != "" {
+		fileValues, err := readFile(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		merge(values, fileValues)
+	}
+
+	merge(values, readEnv())
+	merge(values, flags)
+
+	profile := Development
+
+	if value, ok := values["profile"]; ok {
+		profile = Profile(value)
+	}
+
+	cfg := defaults(profile)
+	errs := ValidationError{}
+
+	for key := range values {
+		if lookup(key) == nil {
+			errs.add(key, "unknown configuration key")
+		}
+	}
+
+	for _, f := range fields {
+		if value, ok := values[f.key]; ok {
+			if err := f.set(cfg, value); err != nil {
+				errs.add(f.key, err.Error())
+			}
+		}
+	}
+
+	errs.merge(cfg.Validate())
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	config = cfg
+
+	return config, nil
+}
+
+// GetConfig returns the configuration produced by the last successful Load.
+func GetConfig() *AppConfig {
+	return config
+}
+
+func defaults(profile Profile) *AppConfig {
+	cfg := &AppConfig{
+		Type:            profile,
+		Driver:          "mysql",
+		DBHost:          "127.0.0.1",
+		DBPort:          3306,
+		Address:         ":8080",
+		ReadTimeout:     10 * time.Second,
+		WriteTimeout:    10 * time.Second,
+		RequestTimeout:  5 * time.Second,
+		ErrorFormat:     "envelope",
+		JWTKeyID:        "default",
+		AccessTokenTTL:  time.Hour,
+		RefreshTokenTTL: 30 * 24 * time.Hour,
+		LogLevel:        "info",
+		PasswordHasher:  "bcrypt",
+		PurgeRetention:  30 * 24 * time.Hour,
+		PurgeInterval:   time.Hour,
+	}
+
+	switch profile {
+	case Development:
+		cfg.Username = "root"
+		cfg.DBName = "db_sirclo"
+		cfg.LogLevel = "debug"
+		cfg.AutoMigrate = true
+	case Test:
+		cfg.Username = "root"
+		cfg.DBName = "db_sirclo_test"
+		cfg.LogLevel = "error"
+	}
+
+	return cfg
+}
+
+func (cfg *AppConfig) Validate() ValidationError {
+	errs := ValidationError{}
+
+	switch cfg.Type {
+	case Development, Test, Production:
+	default:
+		errs.add("profile", fmt.Sprintf("must be one of %v, %v or %v", Development, Test, Production))
+	}
+
+	if cfg.Driver == "" {
+		errs.add("database.driver", "must not be empty")
+	}
+
+	if cfg.DBHost == "" {
+		errs.add("database.host", "must not be empty")
+	}
+
+	if cfg.DBPort < 1 || cfg.DBPort > 65535 {
+		errs.add("database.port", "must be between 1 and 65535")
+	}
+
+	if cfg.DBName == "" {
+		errs.add("database.name", "must not be empty")
+	}
+
+	if cfg.Username == "" {
+		errs.add("database.username", "must not be empty")
+	}
+
+	if cfg.Type == Production && cfg.Password == "" {
+		errs.add("database.password", "must not be empty in production")
+	}
+
+	if cfg.AutoMigrate && cfg.Type != Development {
+		errs.add("database.auto_migrate", "only allowed in the development profile, run migrate up instead")
+	}
+
+	if cfg.Address == "" {
+		errs.add("server.address", "must not be empty")
+	}
+
+	if cfg.ReadTimeout < 0 {
+		errs.add("server.read_timeout", "must not be negative")
+	}
+
+	if cfg.WriteTimeout < 0 {
+		errs.add("server.write_timeout", "must not be negative")
+	}
+
+	if cfg.RequestTimeout < 0 {
+		errs.add("server.request_timeout", "must not be negative")
+	}
+
+	switch cfg.ErrorFormat {
+	case "envelope", "problem":
+	default:
+		errs.add("server.error_format", "must be envelope or problem")
+	}
+
+	if cfg.Type == Production && cfg.JWTKeysDir == "" && cfg.JWTSecret == "" {
+		errs.add("jwt.secret", "must be set in production unless jwt.keys_dir is")
+	} else if cfg.Type == Production && cfg.JWTSecret != "" && len(cfg.JWTSecret) < 16 {
+		errs.add("jwt.secret", "must be at least 16 characters in production")
+	}
+
+	if cfg.JWTSecret != "" && cfg.JWTKeyID == "" {
+		errs.add("jwt.key_id", "must not be empty when jwt.secret is set")
+	}
+
+	if cfg.AccessTokenTTL <= 0 {
+		errs.add("jwt.access_ttl", "must be positive")
+	}
+
+	if cfg.RefreshTokenTTL <= cfg.AccessTokenTTL {
+		errs.add("jwt.refresh_ttl", "must be longer than jwt.access_ttl")
+	}
+
+	switch cfg.LogLevel {
+	case "debug", "info", "warn", "error", "off":
+	default:
+		errs.add("log.level", "must be one of debug, info, warn, error or off")
+	}
+
+	switch cfg.PasswordHasher {
+	case "bcrypt", "argon2id":
+	default:
+		errs.add("password.hasher", "must be bcrypt or argon2id")
+	}
+
+	if cfg.PurgeRetention < 0 {
+		errs.add("purge.retention", "must not be negative")
+	}
+
+	if cfg.PurgeInterval < 0 {
+		errs.add("purge.interval", "must not be negative")
+	}
+
+	return errs
+}
+
+type field struct {
+	key   string
+	usage string
+	set   func(*AppConfig, string) error
+}
+
+var fields = []field{
+	{"profile", "configuration profile (development, test, production)", func(c *AppConfig, v string) error { c.Type = Profile(v); return nil }},
+	{"database.driver", "database driver name", func(c *AppConfig, v string) error { c.Driver = v; return nil }},
+	{"database.host", "database host", func(c *AppConfig, v string) error { c.DBHost = v; return nil }},
+	{"database.port", "database port", func(c *AppConfig, v string) error { return setInt(&c.DBPort, v) }},
+	{"database.username", "database user", func(c *AppConfig, v string) error { c.Username = v; return nil }},
+	{"database.password", "database password", func(c *AppConfig, v string) error { c.Password = v; return nil }},
+	{"database.name", "database name", func(c *AppConfig, v string) error { c.DBName = v; return nil }},
+	{"database.auto_migrate", "apply pending migrations on startup (development only)", func(c *AppConfig, v string) error { return setBool(&c.AutoMigrate, v) }},
+	{"server.address", "address the HTTP server listens on", func(c *AppConfig, v string) error { c.Address = v; return nil }},
+	{"server.read_timeout", "HTTP server read timeout", func(c *AppConfig, v string) error { return setDuration(&c.ReadTimeout, v) }},
+	{"server.write_timeout", "HTTP server write timeout", func(c *AppConfig, v string) error { return setDuration(&c.WriteTimeout, v) }},
+	{"server.request_timeout", "deadline of the database work of a request, 0 for none", func(c *AppConfig, v string) error { return setDuration(&c.RequestTimeout, v) }},
+	{"server.error_format", "format of error responses (envelope, problem); clients may ask for problem with Accept", func(c *AppConfig, v string) error { c.ErrorFormat = v; return nil }},
+	{"jwt.secret", "HS256 secret used to sign access tokens", func(c *AppConfig, v string) error { c.JWTSecret = v; return nil }},
+	{"jwt.key_id", "kid of the key built from jwt.secret", func(c *AppConfig, v string) error { c.JWTKeyID = v; return nil }},
+	{"jwt.keys_dir", "directory of <kid>.pem and <kid>.secret signing keys", func(c *AppConfig, v string) error { c.JWTKeysDir = v; return nil }},
+	{"jwt.active_key", "kid of the key used to sign new tokens", func(c *AppConfig, v string) error { c.JWTActiveKey = v; return nil }},
+	{"jwt.access_ttl", "lifetime of access tokens", func(c *AppConfig, v string) error { return setDuration(&c.AccessTokenTTL, v) }},
+	{"jwt.refresh_ttl", "lifetime of refresh tokens", func(c *AppConfig, v string) error { return setDuration(&c.RefreshTokenTTL, v) }},
+	{"log.level", "log level (debug, info, warn, error, off)", func(c *AppConfig, v string) error { c.LogLevel = v; return nil }},
+	{"password.hasher", "password hashing algorithm (bcrypt, argon2id)", func(c *AppConfig, v string) error { c.PasswordHasher = v; return nil }},
+	{"api.deprecations", "comma separated deprecated routes, as METHOD /path deprecated-on [sunset-on]", func(c *AppConfig, v string) error { return setDeprecations(&c.Deprecations, v) }},
+	{"purge.retention", "how long deleted users, books and products are kept to be restored", func(c *AppConfig, v string) error { return setDuration(&c.PurgeRetention, v) }},
+	{"purge.interval", "how often those kept longer are purged, 0 for never", func(c *AppConfig, v string) error { return setDuration(&c.PurgeInterval, v) }},
+	{"api.require_if_match", "reject changes to a resource without an If-Match header with 428", func(c *AppConfig, v string) error { return setBool(&c.RequireIfMatch, v) }},
+}
+
+func lookup(key string) *field {
+	for i := range fields {
+		if fields[i].key == key {
+			return &fields[i]
+		}
+	}
+
+	return nil
+}
+
+func setInt(target *int, value string) error {
+	i, err := strconv.Atoi(value)
+
+	if err != nil {
+		return fmt.Errorf("must be an integer")
+	}
+
+	*target = i
+
+	return nil
+}
+
+func setBool(target *bool, value string) error {
+	b, err := strconv.ParseBool(value)
+
+	if err != nil {
+		return fmt.Errorf("must be true or false")
+	}
+
+	*target = b
+
+	return nil
+}
+
+func setDuration(target *time.Duration, value string) error {
+	d, err := time.ParseDuration(value)
+
+	if err != nil {
+		return fmt.Errorf("must be a duration such as 10s")
+	}
+
+	*target = d
+
+	return nil
 }
 
-func GetConfig(fallback *AppConfig) *AppConfig {
-	if config == nil {
-		switch fallback.Type {
-		case "main":
-			config = &main
+// setDeprecations parses entries such as "GET /v1/books 2022-06-01
+// 2022-12-01", separated by commas, with dates taken as midnight UTC.
+func setDeprecations(target *[]RouteDeprecation, value string) error {
+	invalid := fmt.Errorf("must be a comma separated list of METHOD /path deprecated-on [sunset-on], with dates such as 2022-06-01")
+
+	deprecations := []RouteDeprecation{}
+
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.Fields(entry)
+
+		if len(parts) == 0 {
+			continue
+		}
+
+		if len(parts) < 3 || len(parts) > 4 || !strings.HasPrefix(parts[1], "/") {
+			return invalid
+		}
+
+		deprecation := RouteDeprecation{Route: strings.ToUpper(parts[0]) + " " + parts[1]}
+		var err error
+
+		if deprecation.At, err = time.Parse("2006-01-02", parts[2]); err != nil {
+			return invalid
+		}
+
+		if len(parts) == 4 {
+			if deprecation.Sunset, err = time.Parse("2006-01-02", parts[3]); err != nil {
+				return invalid
+			}
+
+			if deprecation.Sunset.Before(deprecation.At) {
+				return fmt.Errorf("%v: sunset must not be before deprecation", deprecation.Route)
+			}
+		}
+
+		deprecations = append(deprecations, deprecation)
+	}
+
+	*target = deprecations
+
+	return nil
+}
+
+func envName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_").Replace(key))
+}
+
+func parseFlags(args []string) (map[string]string, string, error) {
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+
+	path := fs.String("config", "", "path to a YAML configuration file")
+
+	for _, f := range fields {
+		fs.String(f.key, "", f.usage)
+	}
+
+	values := map[string]string{}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, "", err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name != "config" {
+			values[f.Name] = f.Value.String()
+		}
+	})
+
+	return values, *path, nil
+}
+
+func readEnv() map[string]string {
+	values := map[string]string{}
+
+	for _, f := range fields {
+		if value, ok := os.LookupEnv(envName(f.key)); ok {
+			values[f.key] = value
+		}
+	}
+
+	return values
+}
+
+func readFile(path string) (map[string]string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+	default:
+		return nil, fmt.Errorf("config file %v: unsupported format, expected .yaml or .yml", path)
+	}
+
+	content, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("config file %v: %v", path, err)
+	}
+
+	tree := map[string]interface{}{}
+
+	if err := yaml.Unmarshal(content, &tree); err != nil {
+		return nil, fmt.Errorf("config file %v: %v", path, err)
+	}
+
+	values := map[string]string{}
+	flatten("", tree, values)
+
+	return values, nil
+}
+
+func flatten(prefix string, tree map[string]interface{}, values map[string]string) {
+	for key, value := range tree {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flatten(key, v, values)
+		case []interface{}:
+			items := make([]string, len(v))
+
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+
+			values[key] = strings.Join(items, ",")
+		case nil:
 		default:
-			config = fallback
+			values[key] = fmt.Sprint(v)
 		}
 	}
-	return config
+}
+
+func merge(dst map[string]string, src map[string]string) {
+	for key, value := range src {
+		dst[key] = value
+	}
+}
+
+// ValidationError maps every invalid configuration key to what is wrong with
+// it, so a single run reports all problems at once.
+type ValidationError map[string]string
+
+func (ve ValidationError) add(key string, message string) {
+	if _, ok := ve[key]; !ok {
+		ve[key] = message
+	}
+}
+
+func (ve ValidationError) merge(other ValidationError) {
+	for key, message := range other {
+		ve.add(key, message)
+	}
+}
+
+func (ve ValidationError) Error() string {
+	keys := make([]string, 0, len(ve))
+
+	for key := range ve {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("%v (%v): %v", key, envName(key), ve[key]))
+	}
+
+	return "invalid configuration:\n  " + strings.Join(lines, "\n  ")
 }
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	ioutil.WriteFile(path, []byte(content), 0600)
+
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Run("TestLoadDefaults", func(t *testing.T) {
+		cfg, err := Load(nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, Development, cfg.Type)
+		assert.Equal(t, ":8080", cfg.Address)
+		assert.Equal(t, 3306, cfg.DBPort)
+		assert.Equal(t, "debug", cfg.LogLevel)
+		assert.True(t, cfg.AutoMigrate)
+		assert.Equal(t, cfg, GetConfig())
+	})
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	t.Run("TestLoadPrecedence", func(t *testing.T) {
+		path := writeFile(t, `
+profile: test
+server:
+  address: ":7000"
+  read_timeout: 3s
+database:
+  host: file-host
+  port: 3307
+  name: file-db
+`)
+
+		t.Setenv("APP_CONFIG", path)
+		t.Setenv("APP_DATABASE_HOST", "env-host")
+		t.Setenv("APP_DATABASE_NAME", "env-db")
+
+		cfg, err := Load([]string{"-database.name", "flag-db"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, Test, cfg.Type)
+		assert.Equal(t, "error", cfg.LogLevel)
+		assert.Equal(t, ":7000", cfg.Address)
+		assert.Equal(t, 3*time.Second, cfg.ReadTimeout)
+		assert.Equal(t, 3307, cfg.DBPort)
+		assert.Equal(t, "env-host", cfg.DBHost)
+		assert.Equal(t, "flag-db", cfg.DBName)
+		assert.False(t, cfg.AutoMigrate)
+	})
+}
+
+func TestLoadValidation(t *testing.T) {
+	t.Run("TestLoadValidation", func(t *testing.T) {
+		path := writeFile(t, `
+profile: production
+database:
+  port: abc
+  auto_migrate: true
+server:
+  write_timeout: forever
+  request_timeout: -1s
+  error_format: xml
+api:
+  deprecations: GET /v1/books someday
+purge:
+  retention: -24h
+unknown: value
+`)
+
+		_, err := Load([]string{"-config", path, "-log.level", "loud"})
+
+		assert.IsType(t, ValidationError{}, err)
+		assert.Equal(t, ValidationError{
+			"database.port":          "must be an integer",
+			"server.write_timeout":   "must be a duration such as 10s",
+			"server.request_timeout": "must not be negative",
+			"server.error_format":    "must be envelope or problem",
+			"database.username":      "must not be empty",
+			"database.name":          "must not be empty",
+			"database.password":      "must not be empty in production",
+			"database.auto_migrate":  "only allowed in the development profile, run migrate up instead",
+			"jwt.secret":             "must be set in production unless jwt.keys_dir is",
+			"log.level":              "must be one of debug, info, warn, error or off",
+			"api.deprecations":       "must be a comma separated list of METHOD /path deprecated-on [sunset-on], with dates such as 2022-06-01",
+			"purge.retention":        "must not be negative",
+			"unknown":                "unknown configuration key",
+		}, err)
+	})
+}
+
+func TestLoadDeprecations(t *testing.T) {
+	t.Run("TestLoadDeprecationsFile", func(t *testing.T) {
+		path := writeFile(t, `
+api:
+  deprecations:
+    - GET /v1/books 2022-06-01 2022-12-01
+    - delete /v1/books/:id 2022-06-01
+`)
+
+		cfg, err := Load([]string{"-config", path})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []RouteDeprecation{
+			{Route: "GET /v1/books", At: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC), Sunset: time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC)},
+			{Route: "DELETE /v1/books/:id", At: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)},
+		}, cfg.Deprecations)
+	})
+
+	t.Run("TestLoadDeprecationsSunsetBeforeDeprecation", func(t *testing.T) {
+		t.Setenv("APP_API_DEPRECATIONS", "GET /v1/books 2022-06-01 2022-01-01")
+
+		_, err := Load(nil)
+
+		assert.Equal(t, ValidationError{"api.deprecations": "GET /v1/books: sunset must not be before deprecation"}, err)
+	})
+}
+
+func TestLoadUnsupportedFile(t *testing.T) {
+	t.Run("TestLoadUnsupportedFile", func(t *testing.T) {
+		_, err := Load([]string{"-config", "config.toml"})
+
+		assert.Error(t, err)
+	})
+}
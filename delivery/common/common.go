@@ -2,6 +2,7 @@ package common
 
 import (
 	"net/http"
+	"rest-api/design-pattern/util/query"
 )
 
 type simpleResponse struct {
@@ -22,12 +23,24 @@ func SimpleResponse(code int, message string, data interface{}) simpleResponse {
 		}
 	case http.StatusBadRequest:
 		if message == "" {
-			message = "status bad response"
+			message = "status bad request"
 		}
 	case http.StatusUnauthorized:
 		if message == "" {
 			message = "status unauthorized"
 		}
+	case http.StatusNotFound:
+		if message == "" {
+			message = "status not found"
+		}
+	case http.StatusConflict:
+		if message == "" {
+			message = "status conflict"
+		}
+	case http.StatusUnprocessableEntity:
+		if message == "" {
+			message = "status unprocessable entity"
+		}
 	default:
 		if message == "" {
 			message = "status ok"
@@ -35,3 +48,18 @@ func SimpleResponse(code int, message string, data interface{}) simpleResponse {
 	}
 	return simpleResponse{code, message, data}
 }
+
+type pagedResponse struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+	Meta    query.Page  `json:"meta"`
+}
+
+// PagedResponse is SimpleResponse for listings, carrying the pagination
+// metadata of data under meta.
+func PagedResponse(code int, message string, data interface{}, page query.Page) pagedResponse {
+	simple := SimpleResponse(code, message, data)
+
+	return pagedResponse{simple.Code, simple.Message, simple.Data, page}
+}
@@ -0,0 +1,81 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"rest-api/design-pattern/domain"
+
+	"github.com/labstack/echo/v4"
+)
+
+var statuses = []struct {
+	kind error
+	code int
+}{
+	{domain.ErrNotFound, http.StatusNotFound},
+	{domain.ErrConflict, http.StatusConflict},
+	{domain.ErrForbidden, http.StatusForbidden},
+	{domain.ErrValidation, http.StatusUnprocessableEntity},
+	{domain.ErrUnauthorized, http.StatusUnauthorized},
+	{domain.ErrPreconditionFailed, http.StatusPreconditionFailed},
+}
+
+// Error returns the error a handler reports for err: err itself if it is a
+// domain error, otherwise an internal server error described by message so
+// that the cause is logged rather than shown to the client.
+func Error(err error, message string) error {
+	var domainErr *domain.Error
+
+	if errors.As(err, &domainErr) {
+		return err
+	}
+
+	return echo.NewHTTPError(http.StatusInternalServerError, message).SetInternal(err)
+}
+
+// HTTPErrorHandler writes the errors returned by handlers and middleware,
+// like Fail, with the status of their domain error kind or, for echo errors,
+// their own. Any other error is an internal server error. The fields of a
+// validation error are written as the envelope's data or the problem's
+// errors.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	code, message := http.StatusInternalServerError, ""
+
+	var domainErr *domain.Error
+	var httpErr *echo.HTTPError
+	var fields []domain.FieldError
+
+	switch {
+	case errors.As(err, &domainErr):
+		message, fields = domainErr.Message, domainErr.Fields
+
+		for _, status := range statuses {
+			if errors.Is(domainErr, status.kind) {
+				code = status.code
+			}
+		}
+	case errors.As(err, &httpErr):
+		code, message = httpErr.Code, fmt.Sprint(httpErr.Message)
+
+		if httpErr.Internal != nil {
+			c.Logger().Error(httpErr.Internal)
+		}
+	default:
+		c.Logger().Error(err)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(code)
+	} else {
+		err = writeError(c, code, message, fields)
+	}
+
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"net/http"
+	"rest-api/design-pattern/domain"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// The headers of conditional requests, which echo does not name.
+const (
+	HeaderETag        = "ETag"
+	HeaderIfMatch     = "If-Match"
+	HeaderIfNoneMatch = "If-None-Match"
+)
+
+var (
+	requireIfMatch   bool
+	requireIfMatchMu sync.Mutex
+)
+
+// SetRequireIfMatch makes If-Match mandatory on changes to a resource, which
+// are otherwise made to whatever version is current. It must be called
+// before the server starts handling requests.
+func SetRequireIfMatch(require bool) {
+	requireIfMatchMu.Lock()
+	defer requireIfMatchMu.Unlock()
+
+	requireIfMatch = require
+}
+
+// ETag returns the entity tag of the version of a resource, a strong one
+// since every change moves it to a new version.
+func ETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// SetETag sets the ETag header of the response to the tag of version.
+func SetETag(c echo.Context, version int) {
+	c.Response().Header().Set(HeaderETag, ETag(version))
+}
+
+// NotModified reports whether the If-None-Match header of c lists the tag of
+// version, compared weakly, or is *. The handler then answers 304 instead
+// of the resource.
+func NotModified(c echo.Context, version int) bool {
+	header := c.Request().Header.Get(HeaderIfNoneMatch)
+
+	return header != "" && matches(header, ETag(version), true)
+}
+
+// IfMatch checks the If-Match header of c against version, the current one
+// of the resource the request changes, and returns the version the change is
+// to be made to. The header must list the tag of version, compared strongly,
+// or be *; without it the change is made to version too, unless If-Match is
+// required.
+func IfMatch(c echo.Context, version int) (int, error) {
+	header := c.Request().Header.Get(HeaderIfMatch)
+
+	if header == "" {
+		requireIfMatchMu.Lock()
+		required := requireIfMatch
+		requireIfMatchMu.Unlock()
+
+		if required {
+			return 0, echo.NewHTTPError(http.StatusPreconditionRequired, "if-match required")
+		}
+
+		return version, nil
+	}
+
+	if !matches(header, ETag(version), false) {
+		return 0, domain.PreconditionFailed("if-match does not match the current version")
+	}
+
+	return version, nil
+}
+
+// matches reports whether the list of entity tags header is * or holds etag,
+// ignoring the weakness of the tags when weak is true; weak tags never match
+// otherwise.
+func matches(header string, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+
+		if tag == "*" {
+			return true
+		}
+
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+
+		if tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
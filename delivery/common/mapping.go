@@ -0,0 +1,130 @@
+package common
+
+import "rest-api/design-pattern/entity"
+
+// The functions below map the entities the services return to the shapes
+// the API serves them in.
+
+func NewBookResponse(book entity.Book) BookResponse {
+	return BookResponse{
+		Id:        book.Id,
+		Title:     book.Title,
+		Author:    book.Author,
+		Publisher: book.Publisher,
+		Language:  book.Language,
+		Pages:     book.Pages,
+		ISBN13:    book.ISBN13,
+		CreatedAt: book.CreatedAt,
+		UpdatedAt: book.UpdatedAt,
+		CreatedBy: book.CreatedBy,
+		UpdatedBy: book.UpdatedBy,
+		DeletedAt: book.DeletedAt,
+	}
+}
+
+func NewBookResponses(books []entity.Book) []BookResponse {
+	responses := make([]BookResponse, len(books))
+
+	for i, book := range books {
+		responses[i] = NewBookResponse(book)
+	}
+
+	return responses
+}
+
+func NewBookSearchResults(matches []entity.BookMatch) []BookSearchResult {
+	results := make([]BookSearchResult, len(matches))
+
+	for i, match := range matches {
+		results[i] = BookSearchResult{
+			BookResponse: NewBookResponse(match.Book),
+			Score:        match.Score,
+			Highlights:   match.Highlights,
+		}
+	}
+
+	return results
+}
+
+func NewProductResponse(product entity.Product) ProductResponse {
+	return ProductResponse{
+		Id:        product.Id,
+		Merchant:  product.Merchant,
+		Name:      product.Name,
+		Price:     product.Price,
+		CreatedAt: product.CreatedAt,
+		UpdatedAt: product.UpdatedAt,
+		CreatedBy: product.CreatedBy,
+		UpdatedBy: product.UpdatedBy,
+		DeletedAt: product.DeletedAt,
+	}
+}
+
+func NewProductResponses(products []entity.Product) []ProductResponse {
+	responses := make([]ProductResponse, len(products))
+
+	for i, product := range products {
+		responses[i] = NewProductResponse(product)
+	}
+
+	return responses
+}
+
+// NewUserResponse leaves out the password of user, which the API never
+// serves back.
+func NewUserResponse(user entity.User) UserResponse {
+	return UserResponse{
+		Id:        user.Id,
+		Name:      user.Name,
+		Email:     user.Email,
+		Role:      user.Role,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		CreatedBy: user.CreatedBy,
+		UpdatedBy: user.UpdatedBy,
+		DeletedAt: user.DeletedAt,
+	}
+}
+
+func NewUserResponses(users []entity.User) []UserResponse {
+	responses := make([]UserResponse, len(users))
+
+	for i, user := range users {
+		responses[i] = NewUserResponse(user)
+	}
+
+	return responses
+}
+
+func NewAuditResponse(record entity.Audit) AuditResponse {
+	return AuditResponse{
+		Id:         record.Id,
+		ActorID:    record.ActorID,
+		Action:     record.Action,
+		Resource:   record.Resource,
+		ResourceID: record.ResourceID,
+		Before:     record.Before,
+		After:      record.After,
+		RequestID:  record.RequestID,
+		CreatedAt:  record.CreatedAt,
+	}
+}
+
+func NewAuditResponses(records []entity.Audit) []AuditResponse {
+	responses := make([]AuditResponse, len(records))
+
+	for i, record := range records {
+		responses[i] = NewAuditResponse(record)
+	}
+
+	return responses
+}
+
+func NewTokenResponse(tokens entity.Tokens) TokenResponse {
+	return TokenResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    tokens.TokenType,
+		ExpiresIn:    tokens.ExpiresIn,
+	}
+}
@@ -0,0 +1,88 @@
+package common
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// The media types of the patch documents PATCH requests carry.
+const (
+	MIMEApplicationMergePatchJSON = "application/merge-patch+json"
+	MIMEApplicationJSONPatchJSON  = "application/json-patch+json"
+)
+
+// BindPatch applies the body of c, a JSON Merge Patch (RFC 7396) or a JSON
+// Patch (RFC 6902) by its content type, to current, the resource as clients
+// read it. It decodes the members the patch changes into i and returns their
+// names, sorted. The error returned for a patch of another media type is an
+// unsupported media type, for one that cannot be decoded a bad request, and
+// for a JSON Patch whose operations do not apply to current, a failed test
+// included, a conflict.
+func BindPatch(c echo.Context, current interface{}, i interface{}) ([]string, error) {
+	original, err := json.Marshal(current)
+
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "binding failed").SetInternal(err)
+	}
+
+	var patched []byte
+
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+
+	switch mediaType {
+	case MIMEApplicationMergePatchJSON:
+		if patched, err = jsonpatch.MergePatch(original, body); err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "binding failed").SetInternal(err)
+		}
+	case MIMEApplicationJSONPatchJSON:
+		patch, err := jsonpatch.DecodePatch(body)
+
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "binding failed").SetInternal(err)
+		}
+
+		if patched, err = patch.Apply(original); err != nil {
+			return nil, echo.NewHTTPError(http.StatusConflict, "patch does not apply").SetInternal(err)
+		}
+	default:
+		return nil, echo.NewHTTPError(http.StatusUnsupportedMediaType, "unsupported media type")
+	}
+
+	changes, err := jsonpatch.CreateMergePatch(original, patched)
+
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "binding failed").SetInternal(err)
+	}
+
+	members := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(changes, &members); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "binding failed").SetInternal(err)
+	}
+
+	if err := json.Unmarshal(changes, i); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "binding failed").SetInternal(err)
+	}
+
+	fields := make([]string, 0, len(members))
+
+	for field := range members {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+
+	return fields, nil
+}
@@ -0,0 +1,95 @@
+package common
+
+import (
+	"net/http"
+	"rest-api/design-pattern/domain"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// The formats error responses can be written in.
+const (
+	FormatEnvelope = "envelope"
+	FormatProblem  = "problem"
+)
+
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+var (
+	errorFormat   = FormatEnvelope
+	errorFormatMu sync.Mutex
+)
+
+// SetErrorFormat selects the format of every error response. With the
+// default, FormatEnvelope, only clients accepting application/problem+json
+// get a Problem. It must be called before the server starts handling
+// requests.
+func SetErrorFormat(format string) {
+	errorFormatMu.Lock()
+	defer errorFormatMu.Unlock()
+
+	errorFormat = format
+}
+
+// Problem is an RFC 7807 problem details object. Its type is always
+// about:blank, the status alone tells what went wrong, and title is the
+// status text; detail is the message the envelope would carry.
+type Problem struct {
+	Type     string              `json:"type"`
+	Title    string              `json:"title"`
+	Status   int                 `json:"status"`
+	Detail   string              `json:"detail,omitempty"`
+	Instance string              `json:"instance,omitempty"`
+	Errors   []domain.FieldError `json:"errors,omitempty"`
+}
+
+// Fail writes an error response of code with message, as a Problem or a
+// SimpleResponse depending on the configured format and the Accept header.
+func Fail(c echo.Context, code int, message string) error {
+	return writeError(c, code, message, nil)
+}
+
+func writeError(c echo.Context, code int, message string, fields []domain.FieldError) error {
+	if !wantsProblem(c.Request()) {
+		var data interface{}
+
+		if len(fields) > 0 {
+			data = fields
+		}
+
+		return c.JSON(code, SimpleResponse(code, message, data))
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+
+	return c.JSON(code, Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(code),
+		Status:   code,
+		Detail:   message,
+		Instance: c.Request().URL.Path,
+		Errors:   fields,
+	})
+}
+
+func wantsProblem(r *http.Request) bool {
+	errorFormatMu.Lock()
+	format := errorFormat
+	errorFormatMu.Unlock()
+
+	if format == FormatProblem {
+		return true
+	}
+
+	for _, accepted := range strings.Split(r.Header.Get(echo.HeaderAccept), ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(accepted, ";", 2)[0])
+
+		if strings.EqualFold(mediaType, MIMEApplicationProblemJSON) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,34 +1,90 @@
 package common
 
-import "rest-api/design-pattern/entity"
+import (
+	"encoding/json"
+	"rest-api/design-pattern/util/query"
+	"time"
+)
 
 type ProductResponse struct {
-	Id       int    `json:"id" form:"id"`
-	Merchant string `json:"merchant" form:"merchant"`
-	Name     string `json:"name" form:"name"`
-	Price    int    `json:"price" form:"price"`
+	Id        int        `json:"id" form:"id"`
+	Merchant  string     `json:"merchant" form:"merchant"`
+	Name      string     `json:"name" form:"name"`
+	Price     int        `json:"price" form:"price"`
+	CreatedAt time.Time  `json:"created_at" form:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" form:"updated_at"`
+	CreatedBy *int       `json:"created_by" form:"created_by"`
+	UpdatedBy *int       `json:"updated_by" form:"updated_by"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" form:"deleted_at"`
 }
 
 type BookResponse struct {
-	Id        int    `json:"id" form:"id"`
-	Title     string `json:"title" form:"title"`
-	Author    string `json:"author" form:"author"`
-	Publisher string `json:"publisher" form:"publisher"`
-	Language  string `json:"language" form:"language"`
-	Pages     int    `json:"page" form:"page"`
-	ISBN13    string `json:"isbn13" form:"isbn13"`
+	Id        int        `json:"id" form:"id"`
+	Title     string     `json:"title" form:"title"`
+	Author    string     `json:"author" form:"author"`
+	Publisher string     `json:"publisher" form:"publisher"`
+	Language  string     `json:"language" form:"language"`
+	Pages     int        `json:"pages" form:"pages"`
+	ISBN13    string     `json:"isbn13" form:"isbn13"`
+	CreatedAt time.Time  `json:"created_at" form:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" form:"updated_at"`
+	CreatedBy *int       `json:"created_by" form:"created_by"`
+	UpdatedBy *int       `json:"updated_by" form:"updated_by"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" form:"deleted_at"`
+}
+
+// BookSearchResult is a book matching a search, with its relevance and the
+// matched fragments of its title, author and publisher.
+type BookSearchResult struct {
+	BookResponse
+	Score      float64           `json:"score" form:"score"`
+	Highlights map[string]string `json:"highlights" form:"highlights"`
 }
 
 type UserResponse struct {
-	Id    int    `json:"id" form:"id"`
-	Name  string `json:"name" form:"name"`
-	Email string `json:"email" form:"email"`
+	Id        int        `json:"id" form:"id"`
+	Name      string     `json:"name" form:"name"`
+	Email     string     `json:"email" form:"email"`
+	Role      string     `json:"role" form:"role"`
+	CreatedAt time.Time  `json:"created_at" form:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" form:"updated_at"`
+	CreatedBy *int       `json:"created_by" form:"created_by"`
+	UpdatedBy *int       `json:"updated_by" form:"updated_by"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" form:"deleted_at"`
+}
+
+// UserRoleResponse is the user whose role was set, by id alone.
+type UserRoleResponse struct {
+	Id   int    `json:"id" form:"id"`
+	Role string `json:"role" form:"role"`
+}
+
+// AuditResponse is an audit record, with the resource as served before and
+// after the change.
+type AuditResponse struct {
+	Id         int             `json:"id" form:"id"`
+	ActorID    *int            `json:"actor_id" form:"actor_id"`
+	Action     string          `json:"action" form:"action"`
+	Resource   string          `json:"resource" form:"resource"`
+	ResourceID int             `json:"resource_id" form:"resource_id"`
+	Before     json.RawMessage `json:"before" form:"before"`
+	After      json.RawMessage `json:"after" form:"after"`
+	RequestID  string          `json:"request_id" form:"request_id"`
+	CreatedAt  time.Time       `json:"created_at" form:"created_at"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token" form:"access_token"`
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+	TokenType    string `json:"token_type" form:"token_type"`
+	ExpiresIn    int    `json:"expires_in" form:"expires_in"`
 }
 
 type GetAllUsersResponse struct {
 	Code    int            `json:"code" form:"code"`
 	Message string         `json:"message" form:"message"`
 	Data    []UserResponse `json:"data" form:"data"`
+	Meta    query.Page     `json:"meta" form:"meta"`
 }
 
 type GetUserResponse struct {
@@ -38,15 +94,15 @@ type GetUserResponse struct {
 }
 
 type CreateUserResponse struct {
-	Code    int           `json:"code" form:"code"`
-	Message string        `json:"message" form:"message"`
-	Data    []entity.User `json:"data" form:"data"`
+	Code    int            `json:"code" form:"code"`
+	Message string         `json:"message" form:"message"`
+	Data    []UserResponse `json:"data" form:"data"`
 }
 
 type UpdateUserResponse struct {
-	Code    int           `json:"code" form:"code"`
-	Message string        `json:"message" form:"message"`
-	Data    []entity.User `json:"data" form:"data"`
+	Code    int            `json:"code" form:"code"`
+	Message string         `json:"message" form:"message"`
+	Data    []UserResponse `json:"data" form:"data"`
 }
 
 type DeleteUserResponse struct {
@@ -59,6 +115,7 @@ type GetAllProductsResponse struct {
 	Code    int               `json:"code" form:"code"`
 	Message string            `json:"message" form:"message"`
 	Data    []ProductResponse `json:"data" form:"data"`
+	Meta    query.Page        `json:"meta" form:"meta"`
 }
 
 type GetProductResponse struct {
@@ -74,9 +131,9 @@ type CreateProductResponse struct {
 }
 
 type UpdateProductResponse struct {
-	Code    int              `json:"code" form:"code"`
-	Message string           `json:"message" form:"message"`
-	Data    []entity.Product `json:"data" form:"data"`
+	Code    int               `json:"code" form:"code"`
+	Message string            `json:"message" form:"message"`
+	Data    []ProductResponse `json:"data" form:"data"`
 }
 
 type DeleteProductResponse struct {
@@ -89,6 +146,14 @@ type GetAllBooksResponse struct {
 	Code    int            `json:"code" form:"code"`
 	Message string         `json:"message" form:"message"`
 	Data    []BookResponse `json:"data" form:"data"`
+	Meta    query.Page     `json:"meta" form:"meta"`
+}
+
+type SearchBooksResponse struct {
+	Code    int                `json:"code" form:"code"`
+	Message string             `json:"message" form:"message"`
+	Data    []BookSearchResult `json:"data" form:"data"`
+	Meta    query.Page         `json:"meta" form:"meta"`
 }
 
 type GetBookResponse struct {
@@ -98,15 +163,15 @@ type GetBookResponse struct {
 }
 
 type CreateBookResponse struct {
-	Code    int           `json:"code" form:"code"`
-	Message string        `json:"message" form:"message"`
-	Data    []entity.Book `json:"data" form:"data"`
+	Code    int            `json:"code" form:"code"`
+	Message string         `json:"message" form:"message"`
+	Data    []BookResponse `json:"data" form:"data"`
 }
 
 type UpdateBookResponse struct {
-	Code    int           `json:"code" form:"code"`
-	Message string        `json:"message" form:"message"`
-	Data    []entity.Book `json:"data" form:"data"`
+	Code    int            `json:"code" form:"code"`
+	Message string         `json:"message" form:"message"`
+	Data    []BookResponse `json:"data" form:"data"`
 }
 
 type DeleteBookResponse struct {
@@ -120,3 +185,10 @@ type LoginResponse struct {
 	Message string      `json:"message" form:"message"`
 	Data    interface{} `json:"data" form:"data"`
 }
+
+type GetAllAuditResponse struct {
+	Code    int             `json:"code" form:"code"`
+	Message string          `json:"message" form:"message"`
+	Data    []AuditResponse `json:"data" form:"data"`
+	Meta    query.Page      `json:"meta" form:"meta"`
+}
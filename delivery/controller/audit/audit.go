@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"net/http"
+	"rest-api/design-pattern/delivery/common"
+	"rest-api/design-pattern/delivery/midware"
+	auditService "rest-api/design-pattern/service/audit"
+	"rest-api/design-pattern/util/query"
+
+	"github.com/labstack/echo/v4"
+)
+
+type AuditController struct {
+	service auditService.Audit
+}
+
+func New(audit auditService.Audit) *AuditController {
+	return &AuditController{
+		service: audit,
+	}
+}
+
+func (ac AuditController) GetAll() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		code := http.StatusOK
+
+		actor, err := midware.ExtractActor(c)
+
+		if err != nil {
+			code = http.StatusUnauthorized
+			return common.Fail(c, code, "unauthorized")
+		}
+
+		opts, err := query.Parse(c.QueryParams(), auditService.ListSpec)
+
+		if err != nil {
+			code = http.StatusBadRequest
+			return common.Fail(c, code, err.Error())
+		}
+
+		records, page, err := ac.service.GetAll(c.Request().Context(), actor, opts)
+
+		if err != nil {
+			return common.Error(err, "get audit failed")
+		}
+
+		page.SetLinks(c.Request().URL)
+
+		if len(records) == 0 {
+			return c.JSON(code, common.PagedResponse(code, "audit empty", nil, page))
+		}
+
+		return c.JSON(code, common.PagedResponse(code, "get audit success", common.NewAuditResponses(records), page))
+	}
+}
@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"rest-api/design-pattern/delivery/common"
+	"rest-api/design-pattern/delivery/midware"
+	"rest-api/design-pattern/entity"
+	auditRepo "rest-api/design-pattern/repository/audit"
+	auditService "rest-api/design-pattern/service/audit"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var auditColumns = []string{"id", "actor_id", "action", "resource", "resource_id", "before_snapshot", "after_snapshot", "request_id", "created_at"}
+
+// getAudit serves GET /audit?query to user 1 of role, or anonymously when
+// role is empty.
+func getAudit(controller *AuditController, role string, query string) (int, common.GetAllAuditResponse) {
+	request := httptest.NewRequest(http.MethodGet, "/audit?"+query, nil)
+
+	if role != "" {
+		token, _ := midware.CreateToken(1, "user1", role)
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+	}
+
+	response := httptest.NewRecorder()
+
+	e := echo.New()
+	e.HTTPErrorHandler = common.HTTPErrorHandler
+
+	context := e.NewContext(request, response)
+	context.SetPath("/audit")
+
+	if err := midware.JWTMiddleware()(controller.GetAll())(context); err != nil {
+		e.HTTPErrorHandler(err, context)
+	}
+
+	actual := common.GetAllAuditResponse{}
+	json.Unmarshal(response.Body.Bytes(), &actual)
+
+	return response.Code, actual
+}
+
+func TestGetAudit(t *testing.T) {
+	t.Run("TestGetAuditFiltered", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		deletedAt := time.Date(2022, 6, 2, 8, 30, 0, 0, time.UTC)
+		before := `{"id":42,"merchant":"user1","name":"product42","price":100}`
+
+		mock.ExpectQuery("SELECT COUNT(*) FROM audit WHERE resource = ? AND resource_id = ?").
+			WithArgs("product", 42).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("SELECT id, actor_id, action, resource, resource_id, before_snapshot, after_snapshot, request_id, created_at FROM audit WHERE resource = ? AND resource_id = ? ORDER BY id DESC LIMIT ?").
+			WithArgs("product", 42, 21).
+			WillReturnRows(sqlmock.NewRows(auditColumns).AddRow(7, 1, "delete", "product", 42, []byte(before), nil, "request1", deletedAt))
+
+		code, actual := getAudit(New(auditService.New(auditRepo.New(db))), entity.RoleAdmin, "resource=product&resource_id=42&sort=-id")
+
+		actor := 1
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "get audit success", actual.Message)
+		assert.Equal(t, []common.AuditResponse{{
+			Id:         7,
+			ActorID:    &actor,
+			Action:     entity.AuditDelete,
+			Resource:   entity.AuditProduct,
+			ResourceID: 42,
+			Before:     json.RawMessage(before),
+			After:      json.RawMessage("null"),
+			RequestID:  "request1",
+			CreatedAt:  deletedAt,
+		}}, actual.Data)
+		assert.Equal(t, 1, actual.Meta.Total)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("TestGetAuditSince", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		since := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+		mock.ExpectQuery("SELECT COUNT(*) FROM audit WHERE actor_id = ? AND created_at >= ?").
+			WithArgs(3, since).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("SELECT id, actor_id, action, resource, resource_id, before_snapshot, after_snapshot, request_id, created_at FROM audit WHERE actor_id = ? AND created_at >= ? ORDER BY id ASC LIMIT ?").
+			WithArgs(3, since, 21).
+			WillReturnRows(sqlmock.NewRows(auditColumns))
+
+		code, actual := getAudit(New(auditService.New(auditRepo.New(db))), entity.RoleAdmin, "actor_id=3&since=2022-06-01T00:00:00Z")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "audit empty", actual.Message)
+		assert.Empty(t, actual.Data)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	cases := []struct {
+		name, role, query string
+		code              int
+		message           string
+	}{
+		{"TestGetAuditAnonymous", "", "", http.StatusUnauthorized, "missing or malformed jwt"},
+		{"TestGetAuditNotAdmin", entity.RoleMerchant, "", http.StatusForbidden, "forbidden"},
+		{"TestGetAuditInvalidFilter", entity.RoleAdmin, "resource_id=abc", http.StatusBadRequest, "invalid resource_id"},
+		{"TestGetAuditInvalidTime", entity.RoleAdmin, "until=yesterday", http.StatusBadRequest, "invalid until"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			defer db.Close()
+
+			code, actual := getAudit(New(auditService.New(auditRepo.New(db))), tc.role, tc.query)
+
+			assert.Equal(t, tc.code, code)
+			assert.Equal(t, tc.message, actual.Message)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
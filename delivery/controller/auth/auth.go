@@ -1,21 +1,27 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
 	"rest-api/design-pattern/delivery/common"
+	"rest-api/design-pattern/delivery/midware"
 	"rest-api/design-pattern/entity"
-	authRepo "rest-api/design-pattern/repository/auth"
+	authService "rest-api/design-pattern/service/auth"
 
 	"github.com/labstack/echo/v4"
 )
 
 type AuthController struct {
-	repository authRepo.Auth
+	service authService.Auth
 }
 
-func New(auth authRepo.Auth) *AuthController {
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+}
+
+func New(auth authService.Auth) *AuthController {
 	return &AuthController{
-		repository: auth,
+		service: auth,
 	}
 }
 
@@ -25,15 +31,105 @@ func (a AuthController) Login() echo.HandlerFunc {
 
 		if err := c.Bind(&login); err != nil {
 			code := http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "binding failed", ""))
+			return common.Fail(c, code, "binding failed")
+		}
+
+		tokens, err := a.service.Login(c.Request().Context(), login.Name, login.Password)
+
+		// The login stands when only upgrading the password hash failed.
+		if errors.Is(err, authService.ErrRehash) {
+			c.Logger().Error(err)
+			err = nil
+		}
+
+		if err != nil {
+			return common.Error(err, "login failed")
+		}
+
+		return c.JSON(http.StatusOK, common.SimpleResponse(http.StatusOK, "login success", common.NewTokenResponse(tokens)))
+	}
+}
+
+func (a AuthController) Refresh() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		input := refreshRequest{}
+
+		if err := c.Bind(&input); err != nil || input.RefreshToken == "" {
+			code := http.StatusBadRequest
+			return common.Fail(c, code, "binding failed")
+		}
+
+		tokens, err := a.service.Refresh(c.Request().Context(), input.RefreshToken)
+
+		if err != nil {
+			return common.Error(err, "refresh token failed")
+		}
+
+		return c.JSON(http.StatusOK, common.SimpleResponse(http.StatusOK, "refresh token success", common.NewTokenResponse(tokens)))
+	}
+}
+
+func (a AuthController) Logout() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		code := http.StatusOK
+
+		userid, err := midware.ExtractId(c)
+
+		if err != nil {
+			code = http.StatusUnauthorized
+			return common.Fail(c, code, "unauthorized")
+		}
+
+		jti, expiresAt, err := midware.ExtractJti(c)
+
+		if err != nil {
+			code = http.StatusUnauthorized
+			return common.Fail(c, code, "unauthorized")
+		}
+
+		input := refreshRequest{}
+
+		if err := c.Bind(&input); err != nil {
+			code = http.StatusBadRequest
+			return common.Fail(c, code, "binding failed")
+		}
+
+		if err := a.service.Logout(c.Request().Context(), userid, jti, expiresAt, input.RefreshToken); err != nil {
+			return common.Error(err, "logout failed")
+		}
+
+		return c.JSON(code, common.SimpleResponse(code, "logout success", nil))
+	}
+}
+
+func (a AuthController) LogoutAll() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		code := http.StatusOK
+
+		userid, err := midware.ExtractId(c)
+
+		if err != nil {
+			code = http.StatusUnauthorized
+			return common.Fail(c, code, "unauthorized")
 		}
 
-		token, code := a.repository.Login(login.Name, login.Password)
+		jti, expiresAt, err := midware.ExtractJti(c)
 
-		if code != http.StatusOK {
-			return c.JSON(code, common.SimpleResponse(code, token, ""))
+		if err != nil {
+			code = http.StatusUnauthorized
+			return common.Fail(c, code, "unauthorized")
 		}
 
-		return c.JSON(code, common.SimpleResponse(code, "login success", token))
+		if err := a.service.LogoutAll(c.Request().Context(), userid, jti, expiresAt); err != nil {
+			return common.Error(err, "logout failed")
+		}
+
+		return c.JSON(code, common.SimpleResponse(code, "logout all sessions success", nil))
+	}
+}
+
+func (a AuthController) JWKS() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, midware.TokenService().JWKS())
 	}
 }
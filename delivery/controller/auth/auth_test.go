@@ -2,22 +2,61 @@ package auth
 
 import (
 	"bytes"
+	"context"
+	"database/sql/driver"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"rest-api/design-pattern/delivery/common"
+	"rest-api/design-pattern/delivery/midware"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/entity"
+	authRepo "rest-api/design-pattern/repository/auth"
+	authService "rest-api/design-pattern/service/auth"
+	"rest-api/design-pattern/util/password"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // TEST SUCCESS
 
-type mockAuthRepositorySuccess struct{}
+type mockAuthServiceSuccess struct{}
 
-func (m mockAuthRepositorySuccess) Login(string, string) (string, int) {
-	return "aValidToken", http.StatusOK
+func (m mockAuthServiceSuccess) Login(context.Context, string, string) (entity.Tokens, error) {
+	return entity.Tokens{
+		AccessToken:  "aValidToken",
+		RefreshToken: "aValidRefreshToken",
+		TokenType:    "Bearer",
+		ExpiresIn:    3600,
+	}, nil
+}
+
+func (m mockAuthServiceSuccess) Refresh(context.Context, string) (entity.Tokens, error) {
+	return entity.Tokens{
+		AccessToken:  "aNewValidToken",
+		RefreshToken: "aNewValidRefreshToken",
+		TokenType:    "Bearer",
+		ExpiresIn:    3600,
+	}, nil
+}
+
+func (m mockAuthServiceSuccess) Logout(context.Context, int, string, time.Time, string) error {
+	return nil
+}
+
+func (m mockAuthServiceSuccess) LogoutAll(context.Context, int, string, time.Time) error {
+	return nil
+}
+
+func (m mockAuthServiceSuccess) IsRevoked(context.Context, string, int, time.Time) (bool, error) {
+	return false, nil
 }
 
 func TestLoginSuccess(t *testing.T) {
@@ -33,12 +72,15 @@ func TestLoginSuccess(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/login")
 
-		authController := New(mockAuthRepositorySuccess{})
-		authController.Login()(context)
+		authController := New(mockAuthServiceSuccess{})
+		if err := authController.Login()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.LoginResponse{}
 		body := response.Body.String()
@@ -47,7 +89,12 @@ func TestLoginSuccess(t *testing.T) {
 		expected := common.LoginResponse{
 			Code:    http.StatusOK,
 			Message: "login success",
-			Data:    "aValidToken",
+			Data: map[string]interface{}{
+				"access_token":  "aValidToken",
+				"refresh_token": "aValidRefreshToken",
+				"token_type":    "Bearer",
+				"expires_in":    float64(3600),
+			},
 		}
 
 		assert.Equal(t, expected, actual)
@@ -56,10 +103,26 @@ func TestLoginSuccess(t *testing.T) {
 
 // TEST FAIL
 
-type mockAuthRepositoryFailRepo struct{}
+type mockAuthServiceFailRepo struct{}
+
+func (m mockAuthServiceFailRepo) Login(context.Context, string, string) (entity.Tokens, error) {
+	return entity.Tokens{}, fmt.Errorf("get user failed")
+}
+
+func (m mockAuthServiceFailRepo) Refresh(context.Context, string) (entity.Tokens, error) {
+	return entity.Tokens{}, fmt.Errorf("get user failed")
+}
+
+func (m mockAuthServiceFailRepo) Logout(context.Context, int, string, time.Time, string) error {
+	return fmt.Errorf("get user failed")
+}
+
+func (m mockAuthServiceFailRepo) LogoutAll(context.Context, int, string, time.Time) error {
+	return fmt.Errorf("get user failed")
+}
 
-func (m mockAuthRepositoryFailRepo) Login(string, string) (string, int) {
-	return "get user failed", http.StatusInternalServerError
+func (m mockAuthServiceFailRepo) IsRevoked(context.Context, string, int, time.Time) (bool, error) {
+	return false, nil
 }
 
 func TestLoginFailRepo(t *testing.T) {
@@ -75,12 +138,15 @@ func TestLoginFailRepo(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/login")
 
-		authController := New(mockAuthRepositoryFailRepo{})
-		authController.Login()(context)
+		authController := New(mockAuthServiceFailRepo{})
+		if err := authController.Login()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.LoginResponse{}
 		body := response.Body.String()
@@ -88,8 +154,7 @@ func TestLoginFailRepo(t *testing.T) {
 
 		expected := common.LoginResponse{
 			Code:    http.StatusInternalServerError,
-			Message: "get user failed",
-			Data:    "",
+			Message: "login failed",
 		}
 
 		assert.Equal(t, expected, actual)
@@ -109,12 +174,15 @@ func TestLoginFailBinding(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/login")
 
-		authController := New(mockAuthRepositoryFailRepo{})
-		authController.Login()(context)
+		authController := New(mockAuthServiceFailRepo{})
+		if err := authController.Login()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.LoginResponse{}
 		body := response.Body.String()
@@ -123,17 +191,33 @@ func TestLoginFailBinding(t *testing.T) {
 		expected := common.LoginResponse{
 			Code:    http.StatusBadRequest,
 			Message: "binding failed",
-			Data:    "",
+			Data:    nil,
 		}
 
 		assert.Equal(t, expected, actual)
 	})
 }
 
-type mockAuthRepositoryFailUserNotFound struct{}
+type mockAuthServiceFailUserNotFound struct{}
+
+func (m mockAuthServiceFailUserNotFound) Login(context.Context, string, string) (entity.Tokens, error) {
+	return entity.Tokens{}, domain.Unauthorized("user does not exist")
+}
+
+func (m mockAuthServiceFailUserNotFound) Refresh(context.Context, string) (entity.Tokens, error) {
+	return entity.Tokens{}, domain.Unauthorized("user does not exist")
+}
+
+func (m mockAuthServiceFailUserNotFound) Logout(context.Context, int, string, time.Time, string) error {
+	return domain.Unauthorized("user does not exist")
+}
 
-func (m mockAuthRepositoryFailUserNotFound) Login(string, string) (string, int) {
-	return "user does not exist", http.StatusUnauthorized
+func (m mockAuthServiceFailUserNotFound) LogoutAll(context.Context, int, string, time.Time) error {
+	return domain.Unauthorized("user does not exist")
+}
+
+func (m mockAuthServiceFailUserNotFound) IsRevoked(context.Context, string, int, time.Time) (bool, error) {
+	return false, nil
 }
 
 func TestLoginFailUserNotFound(t *testing.T) {
@@ -149,12 +233,15 @@ func TestLoginFailUserNotFound(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/login")
 
-		authController := New(mockAuthRepositoryFailUserNotFound{})
-		authController.Login()(context)
+		authController := New(mockAuthServiceFailUserNotFound{})
+		if err := authController.Login()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.LoginResponse{}
 		body := response.Body.String()
@@ -163,17 +250,32 @@ func TestLoginFailUserNotFound(t *testing.T) {
 		expected := common.LoginResponse{
 			Code:    http.StatusUnauthorized,
 			Message: "user does not exist",
-			Data:    "",
 		}
 
 		assert.Equal(t, expected, actual)
 	})
 }
 
-type mockAuthRepositoryFailPasswordIncorrect struct{}
+type mockAuthServiceFailPasswordIncorrect struct{}
 
-func (m mockAuthRepositoryFailPasswordIncorrect) Login(string, string) (string, int) {
-	return "password incorrect", http.StatusUnauthorized
+func (m mockAuthServiceFailPasswordIncorrect) Login(context.Context, string, string) (entity.Tokens, error) {
+	return entity.Tokens{}, domain.Unauthorized("password incorrect")
+}
+
+func (m mockAuthServiceFailPasswordIncorrect) Refresh(context.Context, string) (entity.Tokens, error) {
+	return entity.Tokens{}, domain.Unauthorized("password incorrect")
+}
+
+func (m mockAuthServiceFailPasswordIncorrect) Logout(context.Context, int, string, time.Time, string) error {
+	return domain.Unauthorized("password incorrect")
+}
+
+func (m mockAuthServiceFailPasswordIncorrect) LogoutAll(context.Context, int, string, time.Time) error {
+	return domain.Unauthorized("password incorrect")
+}
+
+func (m mockAuthServiceFailPasswordIncorrect) IsRevoked(context.Context, string, int, time.Time) (bool, error) {
+	return false, nil
 }
 
 func TestLoginFailPasswordIncorrect(t *testing.T) {
@@ -189,12 +291,15 @@ func TestLoginFailPasswordIncorrect(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/login")
 
-		authController := New(mockAuthRepositoryFailPasswordIncorrect{})
-		authController.Login()(context)
+		authController := New(mockAuthServiceFailPasswordIncorrect{})
+		if err := authController.Login()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.LoginResponse{}
 		body := response.Body.String()
@@ -203,17 +308,32 @@ func TestLoginFailPasswordIncorrect(t *testing.T) {
 		expected := common.LoginResponse{
 			Code:    http.StatusUnauthorized,
 			Message: "password incorrect",
-			Data:    "",
 		}
 
 		assert.Equal(t, expected, actual)
 	})
 }
 
-type mockAuthRepositoryFailTokenCreation struct{}
+type mockAuthServiceFailTokenCreation struct{}
+
+func (m mockAuthServiceFailTokenCreation) Login(context.Context, string, string) (entity.Tokens, error) {
+	return entity.Tokens{}, fmt.Errorf("token creation failed")
+}
 
-func (m mockAuthRepositoryFailTokenCreation) Login(string, string) (string, int) {
-	return "token creation failed", http.StatusInternalServerError
+func (m mockAuthServiceFailTokenCreation) Refresh(context.Context, string) (entity.Tokens, error) {
+	return entity.Tokens{}, fmt.Errorf("token creation failed")
+}
+
+func (m mockAuthServiceFailTokenCreation) Logout(context.Context, int, string, time.Time, string) error {
+	return fmt.Errorf("token creation failed")
+}
+
+func (m mockAuthServiceFailTokenCreation) LogoutAll(context.Context, int, string, time.Time) error {
+	return fmt.Errorf("token creation failed")
+}
+
+func (m mockAuthServiceFailTokenCreation) IsRevoked(context.Context, string, int, time.Time) (bool, error) {
+	return false, nil
 }
 
 func TestLoginFailTokenCreation(t *testing.T) {
@@ -229,12 +349,15 @@ func TestLoginFailTokenCreation(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/login")
 
-		authController := New(mockAuthRepositoryFailTokenCreation{})
-		authController.Login()(context)
+		authController := New(mockAuthServiceFailTokenCreation{})
+		if err := authController.Login()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.LoginResponse{}
 		body := response.Body.String()
@@ -242,10 +365,683 @@ func TestLoginFailTokenCreation(t *testing.T) {
 
 		expected := common.LoginResponse{
 			Code:    http.StatusInternalServerError,
-			Message: "token creation failed",
-			Data:    "",
+			Message: "login failed",
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+func TestJWKS(t *testing.T) {
+	t.Run("TestJWKS", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/.well-known/jwks.json")
+
+		authController := New(mockAuthServiceSuccess{})
+		if err := authController.JWKS()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := map[string]interface{}{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := map[string]interface{}{
+			"keys": []interface{}{},
 		}
 
+		assert.Equal(t, http.StatusOK, response.Code)
 		assert.Equal(t, expected, actual)
 	})
 }
+
+// TEST SQL INJECTION
+
+const injection = "' OR '1'='1' -- "
+
+func TestLoginInjectionName(t *testing.T) {
+	t.Run("TestLoginInjectionName", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT id, password, role FROM users WHERE name = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(injection).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "password", "role"}))
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"name":     injection,
+			"password": "password1",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/login")
+
+		authController := New(authService.New(authRepo.New(db), password.NewBcrypt(bcrypt.MinCost), midware.TokenService(), 24*time.Hour))
+		if err := authController.Login()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.LoginResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.LoginResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "user does not exist",
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestLoginInjectionPassword(t *testing.T) {
+	t.Run("TestLoginInjectionPassword", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT id, password, role FROM users WHERE name = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs("user1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "password", "role"}).AddRow(1, "password1", "customer"))
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"name":     "user1",
+			"password": injection,
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/login")
+
+		authController := New(authService.New(authRepo.New(db), password.NewBcrypt(bcrypt.MinCost), midware.TokenService(), 24*time.Hour))
+		if err := authController.Login()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.LoginResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.LoginResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "password incorrect",
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TEST PASSWORD HASHING
+
+type hashOf struct {
+	plain string
+}
+
+func (h hashOf) Match(v driver.Value) bool {
+	hash, ok := v.(string)
+
+	return ok && bcrypt.CompareHashAndPassword([]byte(hash), []byte(h.plain)) == nil
+}
+
+func TestLoginRehashLegacyPassword(t *testing.T) {
+	t.Run("TestLoginRehashLegacyPassword", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT id, password, role FROM users WHERE name = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs("user1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "password", "role"}).AddRow(1, "password1", "customer"))
+		mock.ExpectPrepare("UPDATE users SET password = ? WHERE id = ?").
+			ExpectExec().
+			WithArgs(hashOf{"password1"}, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectPrepare("INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?)").
+			ExpectExec().
+			WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"name":     "user1",
+			"password": "password1",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/login")
+
+		authController := New(authService.New(authRepo.New(db), password.NewBcrypt(bcrypt.MinCost), midware.TokenService(), 24*time.Hour))
+		if err := authController.Login()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.LoginResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		assert.Equal(t, http.StatusOK, actual.Code)
+		assert.Equal(t, "login success", actual.Message)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestLoginRehashFails(t *testing.T) {
+	t.Run("TestLoginRehashFails", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT id, password, role FROM users WHERE name = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs("user1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "password", "role"}).AddRow(1, "password1", "customer"))
+		mock.ExpectPrepare("UPDATE users SET password = ? WHERE id = ?").
+			ExpectExec().
+			WithArgs(hashOf{"password1"}, 1).
+			WillReturnError(fmt.Errorf("lock wait timeout"))
+		mock.ExpectPrepare("INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?)").
+			ExpectExec().
+			WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"name":     "user1",
+			"password": "password1",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		response := httptest.NewRecorder()
+		logs := &bytes.Buffer{}
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+		e.Logger.SetOutput(logs)
+
+		context := e.NewContext(request, response)
+		context.SetPath("/login")
+
+		authController := New(authService.New(authRepo.New(db), password.NewBcrypt(bcrypt.MinCost), midware.TokenService(), 24*time.Hour))
+		if err := authController.Login()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.LoginResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		assert.Equal(t, http.StatusOK, actual.Code)
+		assert.Equal(t, "login success", actual.Message)
+		assert.Contains(t, logs.String(), "rehash password: lock wait timeout")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestLoginHashedPassword(t *testing.T) {
+	t.Run("TestLoginHashedPassword", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		hasher := password.NewBcrypt(bcrypt.MinCost)
+		hash, _ := hasher.Hash("password1")
+
+		mock.ExpectPrepare("SELECT id, password, role FROM users WHERE name = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs("user1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "password", "role"}).AddRow(1, hash, "customer"))
+		mock.ExpectPrepare("INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?)").
+			ExpectExec().
+			WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"name":     "user1",
+			"password": "password1",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/login")
+
+		authController := New(authService.New(authRepo.New(db), hasher, midware.TokenService(), 24*time.Hour))
+		if err := authController.Login()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.LoginResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		assert.Equal(t, http.StatusOK, actual.Code)
+		assert.Equal(t, "login success", actual.Message)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestLoginBlankPassword(t *testing.T) {
+	t.Run("TestLoginBlankPassword", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"name":     "user1",
+			"password": "",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/login")
+
+		authController := New(authService.New(authRepo.New(db), password.NewBcrypt(bcrypt.MinCost), midware.TokenService(), 24*time.Hour))
+		if err := authController.Login()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.LoginResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		assert.Equal(t, http.StatusUnauthorized, actual.Code)
+		assert.Equal(t, "password incorrect", actual.Message)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TEST REFRESH AND LOGOUT
+
+func TestRefreshSuccess(t *testing.T) {
+	t.Run("TestRefreshSuccess", func(t *testing.T) {
+		requestBody, _ := json.Marshal(map[string]string{
+			"refresh_token": "aValidRefreshToken",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/auth/refresh")
+
+		authController := New(mockAuthServiceSuccess{})
+		if err := authController.Refresh()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.LoginResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.LoginResponse{
+			Code:    http.StatusOK,
+			Message: "refresh token success",
+			Data: map[string]interface{}{
+				"access_token":  "aNewValidToken",
+				"refresh_token": "aNewValidRefreshToken",
+				"token_type":    "Bearer",
+				"expires_in":    float64(3600),
+			},
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+func TestRefreshFailBinding(t *testing.T) {
+	t.Run("TestRefreshFailBinding", func(t *testing.T) {
+		requestBody, _ := json.Marshal(map[string]string{})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/auth/refresh")
+
+		authController := New(mockAuthServiceSuccess{})
+		if err := authController.Refresh()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.LoginResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.LoginResponse{
+			Code:    http.StatusBadRequest,
+			Message: "binding failed",
+			Data:    nil,
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+func TestRefreshRotation(t *testing.T) {
+	t.Run("TestRefreshRotation", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT id, user_id, family_id, expires_at, revoked_at FROM refresh_tokens WHERE token_hash = ?").
+			ExpectQuery().
+			WithArgs(sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "family_id", "expires_at", "revoked_at"}).
+				AddRow(7, 1, "family1", time.Now().Add(time.Hour), nil))
+		mock.ExpectPrepare("UPDATE refresh_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL").
+			ExpectExec().
+			WithArgs(sqlmock.AnyArg(), 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectPrepare("SELECT name, role FROM users WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"name", "role"}).AddRow("user1", "merchant"))
+		mock.ExpectPrepare("INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?)").
+			ExpectExec().
+			WithArgs(1, "family1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(8, 1))
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"refresh_token": "aValidRefreshToken",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/auth/refresh")
+
+		authController := New(authService.New(authRepo.New(db), password.NewBcrypt(bcrypt.MinCost), midware.TokenService(), 24*time.Hour))
+		if err := authController.Refresh()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.LoginResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		assert.Equal(t, http.StatusOK, actual.Code)
+		assert.Equal(t, "refresh token success", actual.Message)
+		assert.NotEqual(t, "aValidRefreshToken", actual.Data.(map[string]interface{})["refresh_token"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRefreshReuseDetected(t *testing.T) {
+	t.Run("TestRefreshReuseDetected", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT id, user_id, family_id, expires_at, revoked_at FROM refresh_tokens WHERE token_hash = ?").
+			ExpectQuery().
+			WithArgs(sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "family_id", "expires_at", "revoked_at"}).
+				AddRow(7, 1, "family1", time.Now().Add(time.Hour), time.Now().Add(-time.Minute)))
+		mock.ExpectPrepare("UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL").
+			ExpectExec().
+			WithArgs(sqlmock.AnyArg(), "family1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"refresh_token": "aRotatedRefreshToken",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/auth/refresh")
+
+		authController := New(authService.New(authRepo.New(db), password.NewBcrypt(bcrypt.MinCost), midware.TokenService(), 24*time.Hour))
+		if err := authController.Refresh()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.LoginResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.LoginResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "refresh token reuse detected",
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestLogoutSuccess(t *testing.T) {
+	t.Run("TestLogoutSuccess", func(t *testing.T) {
+		token, _ := midware.CreateToken(1, "user1", entity.RoleCustomer)
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"refresh_token": "aValidRefreshToken",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/auth/logout")
+
+		authController := New(mockAuthServiceSuccess{})
+		if err := midware.JWTMiddleware()(authController.Logout())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.LoginResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.LoginResponse{
+			Code:    http.StatusOK,
+			Message: "logout success",
+			Data:    nil,
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+func TestLogoutFailRepo(t *testing.T) {
+	t.Run("TestLogoutFailRepo", func(t *testing.T) {
+		token, _ := midware.CreateToken(1, "user1", entity.RoleCustomer)
+
+		request := httptest.NewRequest(http.MethodPost, "/", nil)
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/auth/logout")
+
+		authController := New(mockAuthServiceFailRepo{})
+		if err := midware.JWTMiddleware()(authController.Logout())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.LoginResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.LoginResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "logout failed",
+			Data:    nil,
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+func TestLogoutAllSuccess(t *testing.T) {
+	t.Run("TestLogoutAllSuccess", func(t *testing.T) {
+		token, _ := midware.CreateToken(1, "user1", entity.RoleCustomer)
+
+		request := httptest.NewRequest(http.MethodPost, "/", nil)
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/auth/logout-all")
+
+		authController := New(mockAuthServiceSuccess{})
+		if err := midware.JWTMiddleware()(authController.LogoutAll())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.LoginResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.LoginResponse{
+			Code:    http.StatusOK,
+			Message: "logout all sessions success",
+			Data:    nil,
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+type mockRevokedTokens struct{}
+
+func (m mockRevokedTokens) IsRevoked(context.Context, string, int, time.Time) (bool, error) {
+	return true, nil
+}
+
+func TestRevokedTokenRejected(t *testing.T) {
+	t.Run("TestRevokedTokenRejected", func(t *testing.T) {
+		midware.SetRevocationChecker(mockRevokedTokens{})
+		defer midware.SetRevocationChecker(nil)
+
+		token, _ := midware.CreateToken(1, "user1", entity.RoleCustomer)
+
+		request := httptest.NewRequest(http.MethodPost, "/", nil)
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/auth/logout-all")
+
+		authController := New(mockAuthServiceSuccess{})
+		err := midware.JWTMiddleware()(authController.LogoutAll())(context)
+
+		assert.Equal(t, middleware.ErrJWTInvalid, err)
+	})
+}
+
+func TestDeletedUserTokenRejected(t *testing.T) {
+	t.Run("TestDeletedUserTokenRejected", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		// User 1 has been deleted since the token was issued.
+		mock.ExpectPrepare("SELECT COUNT(*) FROM users WHERE id = ? AND deleted_at IS NULL AND (tokens_revoked_at IS NULL OR tokens_revoked_at < ?) AND NOT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?)").
+			ExpectQuery().
+			WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		midware.SetRevocationChecker(authService.New(authRepo.New(db), password.NewBcrypt(bcrypt.MinCost), midware.TokenService(), 24*time.Hour))
+		defer midware.SetRevocationChecker(nil)
+
+		token, _ := midware.CreateToken(1, "user1", entity.RoleMerchant)
+
+		request := httptest.NewRequest(http.MethodPost, "/", nil)
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/auth/logout-all")
+
+		authController := New(mockAuthServiceSuccess{})
+		err := midware.JWTMiddleware()(authController.LogoutAll())(context)
+
+		assert.Equal(t, middleware.ErrJWTInvalid, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -5,37 +5,93 @@ import (
 	"rest-api/design-pattern/delivery/common"
 	"rest-api/design-pattern/delivery/midware"
 	"rest-api/design-pattern/entity"
-	bookRepo "rest-api/design-pattern/repository/book"
+	bookService "rest-api/design-pattern/service/book"
+	"rest-api/design-pattern/util/query"
 	"strconv"
+	"strings"
+	"unicode"
 
 	"github.com/labstack/echo/v4"
 )
 
 type BookController struct {
-	repository bookRepo.Book
+	service bookService.Book
 }
 
-func New(book bookRepo.Book) *BookController {
+func New(book bookService.Book) *BookController {
 	return &BookController{
-		repository: book,
+		service: book,
 	}
 }
 
 func (bc BookController) GetAll() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		code := http.StatusOK
-		books, err := bc.repository.GetAll()
+
+		opts, err := query.Parse(c.QueryParams(), bookService.ListSpec)
+
+		if err != nil {
+			code = http.StatusBadRequest
+			return common.Fail(c, code, err.Error())
+		}
+
+		// Anonymous requests may list books, but not deleted ones.
+		actor, err := midware.ExtractActor(c)
+
+		if err != nil && opts.IncludeDeleted {
+			code = http.StatusUnauthorized
+			return common.Fail(c, code, "unauthorized")
+		}
+
+		books, page, err := bc.service.GetAll(c.Request().Context(), actor, opts)
 
 		if err != nil {
-			code = http.StatusInternalServerError
-			return c.JSON(code, common.SimpleResponse(code, "get all books failed", nil))
+			return common.Error(err, "get all books failed")
 		}
 
+		page.SetLinks(c.Request().URL)
+
 		if len(books) == 0 {
-			return c.JSON(code, common.SimpleResponse(code, "books directory empty", nil))
+			return c.JSON(code, common.PagedResponse(code, "books directory empty", nil, page))
 		}
 
-		return c.JSON(code, common.SimpleResponse(code, "get all books success", books))
+		return c.JSON(code, common.PagedResponse(code, "get all books success", common.NewBookResponses(books), page))
+	}
+}
+
+func (bc BookController) Search() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		code := http.StatusOK
+
+		q := strings.TrimSpace(c.QueryParam("q"))
+
+		// Only letters and digits are searched for, so a query without any
+		// is as empty as a missing one.
+		if strings.IndexFunc(q, isWordRune) < 0 {
+			code = http.StatusBadRequest
+			return common.Fail(c, code, "missing search query")
+		}
+
+		opts, err := query.ParsePage(c.QueryParams())
+
+		if err != nil {
+			code = http.StatusBadRequest
+			return common.Fail(c, code, err.Error())
+		}
+
+		books, page, err := bc.service.Search(c.Request().Context(), q, opts)
+
+		if err != nil {
+			return common.Error(err, "search books failed")
+		}
+
+		page.SetLinks(c.Request().URL)
+
+		if len(books) == 0 {
+			return c.JSON(code, common.PagedResponse(code, "no matching books", nil, page))
+		}
+
+		return c.JSON(code, common.PagedResponse(code, "search books success", common.NewBookSearchResults(books), page))
 	}
 }
 
@@ -47,22 +103,22 @@ func (bc BookController) Get() echo.HandlerFunc {
 
 		if err != nil {
 			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "invalid book id", nil))
+			return common.Fail(c, code, "invalid book id")
 		}
 
-		book, err := bc.repository.Get(id)
+		book, err := bc.service.Get(c.Request().Context(), id)
 
 		if err != nil {
-			code = http.StatusInternalServerError
-			return c.JSON(code, common.SimpleResponse(code, "get book failed", nil))
+			return common.Error(err, "get book failed")
 		}
 
-		if book == (common.BookResponse{}) {
-			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "book does not exist", nil))
+		common.SetETag(c, book.Version)
+
+		if common.NotModified(c, book.Version) {
+			return c.NoContent(http.StatusNotModified)
 		}
 
-		return c.JSON(code, common.SimpleResponse(code, "get book success", []common.BookResponse{book}))
+		return c.JSON(code, common.SimpleResponse(code, "get book success", []common.BookResponse{common.NewBookResponse(book)}))
 	}
 }
 
@@ -71,26 +127,27 @@ func (bc BookController) Create() echo.HandlerFunc {
 		book := entity.Book{}
 		code := http.StatusOK
 
-		if valid := midware.ValidateToken(c); !valid {
+		actor, err := midware.ExtractActor(c)
+
+		if err != nil {
 			code = http.StatusUnauthorized
-			return c.JSON(code, common.SimpleResponse(code, "unauthorized", nil))
+			return common.Fail(c, code, "unauthorized")
 		}
 
 		if err := c.Bind(&book); err != nil {
 			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "binding failed", nil))
+			return common.Fail(c, code, "binding failed")
 		}
 
-		id, err := bc.repository.Create(book)
+		created, err := bc.service.Create(c.Request().Context(), actor, book)
 
 		if err != nil {
-			code = http.StatusInternalServerError
-			return c.JSON(code, common.SimpleResponse(code, "create book failed", nil))
+			return common.Error(err, "create book failed")
 		}
 
-		book.Id = id
+		common.SetETag(c, created.Version)
 
-		return c.JSON(code, common.SimpleResponse(code, "create book success", []entity.Book{book}))
+		return c.JSON(code, common.SimpleResponse(code, "create book success", []common.BookResponse{common.NewBookResponse(created)}))
 	}
 }
 
@@ -98,32 +155,103 @@ func (bc BookController) Update() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		code := http.StatusOK
 
-		if valid := midware.ValidateToken(c); !valid {
+		actor, err := midware.ExtractActor(c)
+
+		if err != nil {
 			code = http.StatusUnauthorized
-			return c.JSON(code, common.SimpleResponse(code, "unauthorized", nil))
+			return common.Fail(c, code, "unauthorized")
 		}
 
 		id, err := strconv.Atoi(c.Param("id"))
 
 		if err != nil {
 			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "invalid book id", nil))
+			return common.Fail(c, code, "invalid book id")
 		}
 
 		book := entity.Book{}
 
 		if err := c.Bind(&book); err != nil {
 			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "binding failed", nil))
+			return common.Fail(c, code, "binding failed")
+		}
+
+		current, err := bc.service.Get(c.Request().Context(), id)
+
+		if err != nil {
+			return common.Error(err, "update book failed")
+		}
+
+		book.Id = id
+
+		if book.Version, err = common.IfMatch(c, current.Version); err != nil {
+			return err
+		}
+
+		book, err = bc.service.Update(c.Request().Context(), actor, book)
+
+		if err != nil {
+			return common.Error(err, "update book failed")
+		}
+
+		common.SetETag(c, book.Version)
+
+		return c.JSON(code, common.SimpleResponse(code, "update book success", []common.BookResponse{common.NewBookResponse(book)}))
+	}
+}
+
+// Patch applies a JSON Merge Patch or a JSON Patch to the book with id,
+// changing only the fields it names.
+func (bc BookController) Patch() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		code := http.StatusOK
+
+		actor, err := midware.ExtractActor(c)
+
+		if err != nil {
+			code = http.StatusUnauthorized
+			return common.Fail(c, code, "unauthorized")
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+
+		if err != nil {
+			code = http.StatusBadRequest
+			return common.Fail(c, code, "invalid book id")
+		}
+
+		current, err := bc.service.Get(c.Request().Context(), id)
+
+		if err != nil {
+			return common.Error(err, "patch book failed")
+		}
+
+		version, err := common.IfMatch(c, current.Version)
+
+		if err != nil {
+			return err
+		}
+
+		book := entity.Book{}
+
+		fields, err := common.BindPatch(c, common.NewBookResponse(current), &book)
+
+		if err != nil {
+			return err
 		}
 
 		book.Id = id
+		book.Version = version
 
-		if code, err := bc.repository.Update(book); err != nil {
-			return c.JSON(code, common.SimpleResponse(code, err.Error(), nil))
+		book, err = bc.service.Patch(c.Request().Context(), actor, book, fields)
+
+		if err != nil {
+			return common.Error(err, "patch book failed")
 		}
 
-		return c.JSON(code, common.SimpleResponse(code, "update book success", []entity.Book{book}))
+		common.SetETag(c, book.Version)
+
+		return c.JSON(code, common.SimpleResponse(code, "patch book success", []common.BookResponse{common.NewBookResponse(book)}))
 	}
 }
 
@@ -131,22 +259,70 @@ func (bc BookController) Delete() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		code := http.StatusOK
 
-		if valid := midware.ValidateToken(c); !valid {
+		actor, err := midware.ExtractActor(c)
+
+		if err != nil {
 			code = http.StatusUnauthorized
-			return c.JSON(code, common.SimpleResponse(code, "unauthorized", nil))
+			return common.Fail(c, code, "unauthorized")
 		}
 
 		id, err := strconv.Atoi(c.Param("id"))
 
 		if err != nil {
 			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "invalid book id", nil))
+			return common.Fail(c, code, "invalid book id")
 		}
 
-		if code, err := bc.repository.Delete(id); err != nil {
-			return c.JSON(code, common.SimpleResponse(code, err.Error(), nil))
+		current, err := bc.service.Get(c.Request().Context(), id)
+
+		if err != nil {
+			return common.Error(err, "delete book failed")
+		}
+
+		version, err := common.IfMatch(c, current.Version)
+
+		if err != nil {
+			return err
+		}
+
+		if err := bc.service.Delete(c.Request().Context(), actor, id, version); err != nil {
+			return common.Error(err, "delete book failed")
 		}
 
 		return c.JSON(code, common.SimpleResponse(code, "delete book success", nil))
 	}
 }
+
+func (bc BookController) Restore() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		code := http.StatusOK
+
+		actor, err := midware.ExtractActor(c)
+
+		if err != nil {
+			code = http.StatusUnauthorized
+			return common.Fail(c, code, "unauthorized")
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+
+		if err != nil {
+			code = http.StatusBadRequest
+			return common.Fail(c, code, "invalid book id")
+		}
+
+		book, err := bc.service.Restore(c.Request().Context(), actor, id)
+
+		if err != nil {
+			return common.Error(err, "restore book failed")
+		}
+
+		common.SetETag(c, book.Version)
+
+		return c.JSON(code, common.SimpleResponse(code, "restore book success", []common.BookResponse{common.NewBookResponse(book)}))
+	}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
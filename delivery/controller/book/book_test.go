@@ -2,25 +2,69 @@ package book
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"rest-api/design-pattern/delivery/common"
 	"rest-api/design-pattern/delivery/midware"
+	"rest-api/design-pattern/domain"
 	"rest-api/design-pattern/entity"
+	auditRepo "rest-api/design-pattern/repository/audit"
+	bookRepo "rest-api/design-pattern/repository/book"
+	"rest-api/design-pattern/repository/transaction"
+	bookService "rest-api/design-pattern/service/book"
+	"rest-api/design-pattern/util/query"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
 
+// mockAuditRepository keeps the records it is asked to store in stored if
+// set, and discards them otherwise.
+type mockAuditRepository struct {
+	auditRepo.Audit
+	stored *[]entity.Audit
+}
+
+func (m mockAuditRepository) Create(ctx context.Context, record entity.Audit) error {
+	if m.stored != nil {
+		*m.stored = append(*m.stored, record)
+	}
+
+	return nil
+}
+
+// newService returns a service on repository, run without a transaction and
+// unaudited.
+func newService(repository bookRepo.Book) *bookService.BookService {
+	return bookService.New(repository, transaction.Repositories{Books: repository, Audits: mockAuditRepository{}})
+}
+
+var bookColumns = []string{"id", "title", "author", "publisher", "language", "pages", "isbn13", "version", "created_at", "updated_at", "created_by", "updated_by"}
+
+// stamped and stampedBy are when and by whom the rows read back in tests
+// were created and last updated.
+var (
+	stamped   = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	stampedBy = 1
+)
+
 // TEST SUCCESS
 
 type mockBookRepositorySuccess struct{}
 
-func (m mockBookRepositorySuccess) GetAll() ([]common.BookResponse, error) {
-	return []common.BookResponse{
+func (m mockBookRepositorySuccess) GetAll(ctx context.Context, opts query.Options) ([]entity.Book, query.Page, error) {
+	page := opts.NewPage()
+	page.Total = 2
+
+	return []entity.Book{
 		{
 			Id:        1,
 			Title:     "title1",
@@ -28,7 +72,7 @@ func (m mockBookRepositorySuccess) GetAll() ([]common.BookResponse, error) {
 			Publisher: "publisher1",
 			Language:  "language1",
 			Pages:     100,
-			ISBN13:    "isbn1",
+			ISBN13:    "9780134190440",
 		},
 		{
 			Id:        2,
@@ -39,31 +83,72 @@ func (m mockBookRepositorySuccess) GetAll() ([]common.BookResponse, error) {
 			Pages:     100,
 			ISBN13:    "isbn2",
 		},
-	}, nil
+	}, page, nil
 }
 
-func (m mockBookRepositorySuccess) Get(int) (common.BookResponse, error) {
-	return common.BookResponse{
+func (m mockBookRepositorySuccess) Get(context.Context, int) (entity.Book, error) {
+	return entity.Book{
 		Id:        1,
 		Title:     "title1",
 		Author:    "author1",
 		Publisher: "publisher1",
 		Language:  "language1",
 		Pages:     100,
-		ISBN13:    "isbn1",
+		ISBN13:    "9780134190440",
+	}, nil
+}
+
+func (m mockBookRepositorySuccess) Create(ctx context.Context, book entity.Book) (entity.Book, error) {
+	return entity.Book{
+		Id:        1,
+		Title:     book.Title,
+		Author:    book.Author,
+		Publisher: book.Publisher,
+		Language:  book.Language,
+		Pages:     book.Pages,
+		ISBN13:    book.ISBN13,
 	}, nil
 }
 
-func (m mockBookRepositorySuccess) Create(entity.Book) (int, error) {
-	return 1, nil
+func (m mockBookRepositorySuccess) Update(context.Context, entity.Book) error {
+	return nil
 }
 
-func (m mockBookRepositorySuccess) Update(entity.Book) (int, error) {
-	return http.StatusOK, nil
+func (m mockBookRepositorySuccess) Patch(ctx context.Context, book entity.Book, fields []string) (entity.Book, error) {
+	return m.Get(ctx, book.Id)
 }
 
-func (m mockBookRepositorySuccess) Delete(int) (int, error) {
-	return http.StatusOK, nil
+func (m mockBookRepositorySuccess) Delete(context.Context, int, int, int) error {
+	return nil
+}
+
+func (m mockBookRepositorySuccess) Restore(context.Context, int, int) error {
+	return nil
+}
+
+func (m mockBookRepositorySuccess) Purge(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m mockBookRepositorySuccess) Search(ctx context.Context, q string, opts query.Options) ([]entity.BookMatch, query.Page, error) {
+	page := opts.NewPage()
+	page.Total = 1
+
+	return []entity.BookMatch{
+		{
+			Book: entity.Book{
+				Id:        1,
+				Title:     "title1",
+				Author:    "author1",
+				Publisher: "publisher1",
+				Language:  "language1",
+				Pages:     100,
+				ISBN13:    "9780134190440",
+			},
+			Score:      1,
+			Highlights: map[string]string{"title": "<em>title</em>1"},
+		},
+	}, page, nil
 }
 
 func TestGetAllBooksSuccess(t *testing.T) {
@@ -73,12 +158,15 @@ func TestGetAllBooksSuccess(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/books")
 
-		bookController := New(mockBookRepositorySuccess{})
-		bookController.GetAll()(context)
+		bookController := New(newService(mockBookRepositorySuccess{}))
+		if err := bookController.GetAll()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetAllBooksResponse{}
 		body := response.Body.String()
@@ -95,7 +183,7 @@ func TestGetAllBooksSuccess(t *testing.T) {
 					Publisher: "publisher1",
 					Language:  "language1",
 					Pages:     100,
-					ISBN13:    "isbn1",
+					ISBN13:    "9780134190440",
 				},
 				{
 					Id:        2,
@@ -107,6 +195,16 @@ func TestGetAllBooksSuccess(t *testing.T) {
 					ISBN13:    "isbn2",
 				},
 			},
+			Meta: query.Page{
+				Total: 2,
+				Page:  1,
+				Limit: query.DefaultLimit,
+				Links: query.Links{
+					Self:  "/",
+					First: "/?page=1",
+					Last:  "/?page=1",
+				},
+			},
 		}
 
 		assert.Equal(t, expected, actual)
@@ -120,14 +218,17 @@ func TestGetBookSuccess(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/books/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		bookController := New(mockBookRepositorySuccess{})
-		bookController.Get()(context)
+		bookController := New(newService(mockBookRepositorySuccess{}))
+		if err := bookController.Get()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetBookResponse{}
 		body := response.Body.String()
@@ -144,7 +245,7 @@ func TestGetBookSuccess(t *testing.T) {
 					Publisher: "publisher1",
 					Language:  "language1",
 					Pages:     100,
-					ISBN13:    "isbn1",
+					ISBN13:    "9780134190440",
 				},
 			},
 		}
@@ -155,7 +256,7 @@ func TestGetBookSuccess(t *testing.T) {
 
 func TestCreateBookSuccess(t *testing.T) {
 	t.Run("TestCreateBookSuccess", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
 
 		requestBody, _ := json.Marshal(map[string]interface{}{
 			"title":     "title1",
@@ -163,7 +264,7 @@ func TestCreateBookSuccess(t *testing.T) {
 			"publisher": "publisher1",
 			"language":  "language1",
 			"pages":     100,
-			"isbn13":    "isbn1",
+			"isbn13":    "9780134190440",
 		})
 
 		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
@@ -174,12 +275,15 @@ func TestCreateBookSuccess(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/books")
 
-		bookController := New(mockBookRepositorySuccess{})
-		midware.JWTMiddleware()(bookController.Create())(context)
+		bookController := New(newService(mockBookRepositorySuccess{}))
+		if err := midware.JWTMiddleware()(bookController.Create())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.CreateBookResponse{}
 		body := response.Body.String()
@@ -188,7 +292,7 @@ func TestCreateBookSuccess(t *testing.T) {
 		expected := common.CreateBookResponse{
 			Code:    http.StatusOK,
 			Message: "create book success",
-			Data: []entity.Book{
+			Data: []common.BookResponse{
 				{
 					Id:        1,
 					Title:     "title1",
@@ -196,7 +300,7 @@ func TestCreateBookSuccess(t *testing.T) {
 					Publisher: "publisher1",
 					Language:  "language1",
 					Pages:     100,
-					ISBN13:    "isbn1",
+					ISBN13:    "9780134190440",
 				},
 			},
 		}
@@ -207,7 +311,7 @@ func TestCreateBookSuccess(t *testing.T) {
 
 func TestUpdateBookSuccess(t *testing.T) {
 	t.Run("TestUpdateBookSuccess", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
 
 		requestBody, _ := json.Marshal(map[string]interface{}{
 			"title":     "title1",
@@ -215,7 +319,7 @@ func TestUpdateBookSuccess(t *testing.T) {
 			"publisher": "publisher1",
 			"language":  "language1",
 			"pages":     100,
-			"isbn13":    "isbn1",
+			"isbn13":    "9780134190440",
 		})
 
 		request := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(requestBody))
@@ -226,14 +330,17 @@ func TestUpdateBookSuccess(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/books/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		bookController := New(mockBookRepositorySuccess{})
-		midware.JWTMiddleware()(bookController.Update())(context)
+		bookController := New(newService(mockBookRepositorySuccess{}))
+		if err := midware.JWTMiddleware()(bookController.Update())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.UpdateBookResponse{}
 		body := response.Body.String()
@@ -242,7 +349,7 @@ func TestUpdateBookSuccess(t *testing.T) {
 		expected := common.UpdateBookResponse{
 			Code:    http.StatusOK,
 			Message: "update book success",
-			Data: []entity.Book{
+			Data: []common.BookResponse{
 				{
 					Id:        1,
 					Title:     "title1",
@@ -250,7 +357,7 @@ func TestUpdateBookSuccess(t *testing.T) {
 					Publisher: "publisher1",
 					Language:  "language1",
 					Pages:     100,
-					ISBN13:    "isbn1",
+					ISBN13:    "9780134190440",
 				},
 			},
 		}
@@ -261,7 +368,7 @@ func TestUpdateBookSuccess(t *testing.T) {
 
 func TestDeleteBookSuccess(t *testing.T) {
 	t.Run("TestDeleteBookSuccess", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
 
 		request := httptest.NewRequest(http.MethodDelete, "/", nil)
 		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
@@ -270,14 +377,17 @@ func TestDeleteBookSuccess(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/books/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		bookController := New(mockBookRepositorySuccess{})
-		midware.JWTMiddleware()(bookController.Delete())(context)
+		bookController := New(newService(mockBookRepositorySuccess{}))
+		if err := midware.JWTMiddleware()(bookController.Delete())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.DeleteBookResponse{}
 		body := response.Body.String()
@@ -297,24 +407,40 @@ func TestDeleteBookSuccess(t *testing.T) {
 
 type mockBookRepositoryFailRepo struct{}
 
-func (m mockBookRepositoryFailRepo) GetAll() ([]common.BookResponse, error) {
-	return nil, assert.AnError
+func (m mockBookRepositoryFailRepo) GetAll(ctx context.Context, opts query.Options) ([]entity.Book, query.Page, error) {
+	return nil, query.Page{}, assert.AnError
 }
 
-func (m mockBookRepositoryFailRepo) Get(int) (common.BookResponse, error) {
-	return common.BookResponse{}, assert.AnError
+func (m mockBookRepositoryFailRepo) Get(context.Context, int) (entity.Book, error) {
+	return entity.Book{}, assert.AnError
 }
 
-func (m mockBookRepositoryFailRepo) Create(entity.Book) (int, error) {
-	return 0, assert.AnError
+func (m mockBookRepositoryFailRepo) Create(context.Context, entity.Book) (entity.Book, error) {
+	return entity.Book{}, assert.AnError
+}
+
+func (m mockBookRepositoryFailRepo) Update(context.Context, entity.Book) error {
+	return fmt.Errorf("udate book failed")
+}
+
+func (m mockBookRepositoryFailRepo) Patch(ctx context.Context, book entity.Book, fields []string) (entity.Book, error) {
+	return entity.Book{}, assert.AnError
+}
+
+func (m mockBookRepositoryFailRepo) Delete(context.Context, int, int, int) error {
+	return fmt.Errorf("delete book failed")
+}
+
+func (m mockBookRepositoryFailRepo) Restore(context.Context, int, int) error {
+	return fmt.Errorf("restore book failed")
 }
 
-func (m mockBookRepositoryFailRepo) Update(entity.Book) (int, error) {
-	return http.StatusInternalServerError, fmt.Errorf("udate book failed")
+func (m mockBookRepositoryFailRepo) Purge(context.Context, time.Time) (int64, error) {
+	return 0, assert.AnError
 }
 
-func (m mockBookRepositoryFailRepo) Delete(int) (int, error) {
-	return http.StatusInternalServerError, fmt.Errorf("delete book failed")
+func (m mockBookRepositoryFailRepo) Search(ctx context.Context, q string, opts query.Options) ([]entity.BookMatch, query.Page, error) {
+	return nil, query.Page{}, assert.AnError
 }
 
 func TestGetAllBooksFailRepo(t *testing.T) {
@@ -324,12 +450,15 @@ func TestGetAllBooksFailRepo(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/books")
 
-		bookController := New(mockBookRepositoryFailRepo{})
-		bookController.GetAll()(context)
+		bookController := New(newService(mockBookRepositoryFailRepo{}))
+		if err := bookController.GetAll()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetAllBooksResponse{}
 		body := response.Body.String()
@@ -352,14 +481,17 @@ func TestGetBookFailRepo(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/books/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		bookController := New(mockBookRepositoryFailRepo{})
-		bookController.Get()(context)
+		bookController := New(newService(mockBookRepositoryFailRepo{}))
+		if err := bookController.Get()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetBookResponse{}
 		body := response.Body.String()
@@ -377,7 +509,7 @@ func TestGetBookFailRepo(t *testing.T) {
 
 func TestCreateBookFailRepo(t *testing.T) {
 	t.Run("TestCreateBookFailRepo", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
 
 		requestBody, _ := json.Marshal(map[string]interface{}{
 			"title":     "title1",
@@ -385,7 +517,7 @@ func TestCreateBookFailRepo(t *testing.T) {
 			"publisher": "publisher1",
 			"language":  "language1",
 			"pages":     100,
-			"isbn13":    "isbn1",
+			"isbn13":    "9780134190440",
 		})
 
 		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
@@ -396,12 +528,15 @@ func TestCreateBookFailRepo(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/books")
 
-		bookController := New(mockBookRepositoryFailRepo{})
-		midware.JWTMiddleware()(bookController.Create())(context)
+		bookController := New(newService(mockBookRepositoryFailRepo{}))
+		if err := midware.JWTMiddleware()(bookController.Create())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.CreateBookResponse{}
 		body := response.Body.String()
@@ -419,7 +554,7 @@ func TestCreateBookFailRepo(t *testing.T) {
 
 func TestUpdateBookFailRepo(t *testing.T) {
 	t.Run("TestUpdateBookFailRepo", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
 
 		requestBody, _ := json.Marshal(map[string]interface{}{
 			"title":     "title1",
@@ -427,7 +562,7 @@ func TestUpdateBookFailRepo(t *testing.T) {
 			"publisher": "publisher1",
 			"language":  "language1",
 			"pages":     100,
-			"isbn13":    "isbn1",
+			"isbn13":    "9780134190440",
 		})
 
 		request := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(requestBody))
@@ -438,14 +573,17 @@ func TestUpdateBookFailRepo(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/books/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		bookController := New(mockBookRepositoryFailRepo{})
-		midware.JWTMiddleware()(bookController.Update())(context)
+		bookController := New(newService(mockBookRepositoryFailRepo{}))
+		if err := midware.JWTMiddleware()(bookController.Update())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.UpdateBookResponse{}
 		body := response.Body.String()
@@ -453,7 +591,7 @@ func TestUpdateBookFailRepo(t *testing.T) {
 
 		expected := common.UpdateBookResponse{
 			Code:    http.StatusInternalServerError,
-			Message: "udate book failed",
+			Message: "update book failed",
 			Data:    nil,
 		}
 
@@ -463,7 +601,7 @@ func TestUpdateBookFailRepo(t *testing.T) {
 
 func TestDeleteBookFailRepo(t *testing.T) {
 	t.Run("TestDeleteBookFailRepo", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
 
 		request := httptest.NewRequest(http.MethodDelete, "/", nil)
 		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
@@ -472,14 +610,17 @@ func TestDeleteBookFailRepo(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/books/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		bookController := New(mockBookRepositoryFailRepo{})
-		midware.JWTMiddleware()(bookController.Delete())(context)
+		bookController := New(newService(mockBookRepositoryFailRepo{}))
+		if err := midware.JWTMiddleware()(bookController.Delete())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.DeleteBookResponse{}
 		body := response.Body.String()
@@ -499,24 +640,40 @@ func TestDeleteBookFailRepo(t *testing.T) {
 
 type mockBookRepositoryFailOther struct{}
 
-func (m mockBookRepositoryFailOther) GetAll() ([]common.BookResponse, error) {
-	return []common.BookResponse{}, nil
+func (m mockBookRepositoryFailOther) GetAll(ctx context.Context, opts query.Options) ([]entity.Book, query.Page, error) {
+	return []entity.Book{}, opts.NewPage(), nil
 }
 
-func (m mockBookRepositoryFailOther) Get(int) (common.BookResponse, error) {
-	return common.BookResponse{}, nil
+func (m mockBookRepositoryFailOther) Get(context.Context, int) (entity.Book, error) {
+	return entity.Book{}, domain.NotFound("book does not exist")
 }
 
-func (m mockBookRepositoryFailOther) Create(entity.Book) (int, error) {
-	return 0, nil
+func (m mockBookRepositoryFailOther) Create(context.Context, entity.Book) (entity.Book, error) {
+	return entity.Book{}, nil
+}
+
+func (m mockBookRepositoryFailOther) Update(context.Context, entity.Book) error {
+	return nil
+}
+
+func (m mockBookRepositoryFailOther) Patch(ctx context.Context, book entity.Book, fields []string) (entity.Book, error) {
+	return entity.Book{}, nil
+}
+
+func (m mockBookRepositoryFailOther) Delete(context.Context, int, int, int) error {
+	return nil
 }
 
-func (m mockBookRepositoryFailOther) Update(entity.Book) (int, error) {
-	return http.StatusOK, nil
+func (m mockBookRepositoryFailOther) Restore(context.Context, int, int) error {
+	return domain.NotFound("book does not exist")
 }
 
-func (m mockBookRepositoryFailOther) Delete(int) (int, error) {
-	return http.StatusOK, nil
+func (m mockBookRepositoryFailOther) Purge(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m mockBookRepositoryFailOther) Search(ctx context.Context, q string, opts query.Options) ([]entity.BookMatch, query.Page, error) {
+	return []entity.BookMatch{}, opts.NewPage(), nil
 }
 
 func TestGetAllBooksEmptyDirectory(t *testing.T) {
@@ -526,12 +683,15 @@ func TestGetAllBooksEmptyDirectory(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/books")
 
-		bookController := New(mockBookRepositoryFailOther{})
-		bookController.GetAll()(context)
+		bookController := New(newService(mockBookRepositoryFailOther{}))
+		if err := bookController.GetAll()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetAllBooksResponse{}
 		body := response.Body.String()
@@ -541,6 +701,16 @@ func TestGetAllBooksEmptyDirectory(t *testing.T) {
 			Code:    http.StatusOK,
 			Message: "books directory empty",
 			Data:    nil,
+			Meta: query.Page{
+				Total: 0,
+				Page:  1,
+				Limit: query.DefaultLimit,
+				Links: query.Links{
+					Self:  "/",
+					First: "/?page=1",
+					Last:  "/?page=1",
+				},
+			},
 		}
 
 		assert.Equal(t, expected, actual)
@@ -554,14 +724,17 @@ func TestGetBookFailInvalidId(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/books/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("invalid id")
 
-		bookController := New(mockBookRepositoryFailOther{})
-		bookController.Get()(context)
+		bookController := New(newService(mockBookRepositoryFailOther{}))
+		if err := bookController.Get()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetBookResponse{}
 		body := response.Body.String()
@@ -584,21 +757,24 @@ func TestGetBookDoesNotExist(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/books/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		bookController := New(mockBookRepositoryFailOther{})
-		bookController.Get()(context)
+		bookController := New(newService(mockBookRepositoryFailOther{}))
+		if err := bookController.Get()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetBookResponse{}
 		body := response.Body.String()
 		json.Unmarshal([]byte(body), &actual)
 
 		expected := common.GetBookResponse{
-			Code:    http.StatusBadRequest,
+			Code:    http.StatusNotFound,
 			Message: "book does not exist",
 			Data:    nil,
 		}
@@ -609,7 +785,7 @@ func TestGetBookDoesNotExist(t *testing.T) {
 
 func TestCreateBookFailBinding(t *testing.T) {
 	t.Run("TestCreateBookFailBinding", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
 
 		requestBody, _ := json.Marshal(map[string]interface{}{
 			"title":     "title1",
@@ -617,7 +793,7 @@ func TestCreateBookFailBinding(t *testing.T) {
 			"publisher": "publisher1",
 			"language":  "language1",
 			"pages":     "100",
-			"isbn13":    "isbn1",
+			"isbn13":    "9780134190440",
 		})
 
 		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
@@ -628,12 +804,15 @@ func TestCreateBookFailBinding(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/books")
 
-		bookController := New(mockBookRepositoryFailOther{})
-		midware.JWTMiddleware()(bookController.Create())(context)
+		bookController := New(newService(mockBookRepositoryFailOther{}))
+		if err := midware.JWTMiddleware()(bookController.Create())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.CreateBookResponse{}
 		body := response.Body.String()
@@ -649,9 +828,56 @@ func TestCreateBookFailBinding(t *testing.T) {
 	})
 }
 
+func TestCreateBookFailValidation(t *testing.T) {
+	t.Run("TestCreateBookFailValidation", func(t *testing.T) {
+		token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
+
+		requestBody, _ := json.Marshal(map[string]interface{}{
+			"title":     "",
+			"author":    "author1",
+			"publisher": "publisher1",
+			"language":  "language1",
+			"pages":     -1,
+			"isbn13":    "isbn1",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/books")
+
+		bookController := New(newService(mockBookRepositorySuccess{}))
+		if err := midware.JWTMiddleware()(bookController.Create())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := struct {
+			Code    int                 `json:"code"`
+			Message string              `json:"message"`
+			Data    []domain.FieldError `json:"data"`
+		}{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, actual.Code)
+		assert.Equal(t, "validation failed", actual.Message)
+		assert.Equal(t, []domain.FieldError{
+			{Field: "title", Code: "required", Message: "title is required"},
+			{Field: "pages", Code: "gt", Message: "pages must be greater than 0"},
+			{Field: "isbn13", Code: "isbn13", Message: "isbn13 must be a valid ISBN-13"},
+		}, actual.Data)
+	})
+}
+
 func TestUpdateBookFailInvalidId(t *testing.T) {
 	t.Run("TestUpdateBookFailInvalidId", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
 
 		requestBody, _ := json.Marshal(map[string]interface{}{
 			"title":     "title1",
@@ -659,7 +885,7 @@ func TestUpdateBookFailInvalidId(t *testing.T) {
 			"publisher": "publisher1",
 			"language":  "language1",
 			"pages":     100,
-			"isbn13":    "isbn1",
+			"isbn13":    "9780134190440",
 		})
 
 		request := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(requestBody))
@@ -670,14 +896,17 @@ func TestUpdateBookFailInvalidId(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/books/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("invalid id")
 
-		bookController := New(mockBookRepositoryFailOther{})
-		midware.JWTMiddleware()(bookController.Update())(context)
+		bookController := New(newService(mockBookRepositoryFailOther{}))
+		if err := midware.JWTMiddleware()(bookController.Update())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.UpdateBookResponse{}
 		body := response.Body.String()
@@ -695,7 +924,7 @@ func TestUpdateBookFailInvalidId(t *testing.T) {
 
 func TestUpdateBookFailBinding(t *testing.T) {
 	t.Run("TestUpdateBookFailInvalidId", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
 
 		requestBody, _ := json.Marshal(map[string]interface{}{
 			"title":     "title1",
@@ -703,7 +932,7 @@ func TestUpdateBookFailBinding(t *testing.T) {
 			"publisher": "publisher1",
 			"language":  "language1",
 			"pages":     "100",
-			"isbn13":    "isbn1",
+			"isbn13":    "9780134190440",
 		})
 
 		request := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(requestBody))
@@ -714,14 +943,17 @@ func TestUpdateBookFailBinding(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/books/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		bookController := New(mockBookRepositoryFailOther{})
-		midware.JWTMiddleware()(bookController.Update())(context)
+		bookController := New(newService(mockBookRepositoryFailOther{}))
+		if err := midware.JWTMiddleware()(bookController.Update())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.UpdateBookResponse{}
 		body := response.Body.String()
@@ -739,7 +971,7 @@ func TestUpdateBookFailBinding(t *testing.T) {
 
 func TestDeleteBookFailInvalidId(t *testing.T) {
 	t.Run("TestDeleteBookFailInvalidId", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
 
 		request := httptest.NewRequest(http.MethodDelete, "/", nil)
 		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
@@ -748,14 +980,17 @@ func TestDeleteBookFailInvalidId(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/books/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("invalid id")
 
-		bookController := New(mockBookRepositoryFailRepo{})
-		midware.JWTMiddleware()(bookController.Delete())(context)
+		bookController := New(newService(mockBookRepositoryFailRepo{}))
+		if err := midware.JWTMiddleware()(bookController.Delete())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.DeleteBookResponse{}
 		body := response.Body.String()
@@ -770,3 +1005,1073 @@ func TestDeleteBookFailInvalidId(t *testing.T) {
 		assert.Equal(t, expected, actual)
 	})
 }
+
+// TEST AUTHORIZATION
+
+func TestCreateBookFailForbidden(t *testing.T) {
+	t.Run("TestCreateBookFailForbidden", func(t *testing.T) {
+		token, _ := midware.CreateToken(1, "user1", entity.RoleMerchant)
+
+		requestBody, _ := json.Marshal(map[string]interface{}{
+			"title":     "title1",
+			"author":    "author1",
+			"publisher": "publisher1",
+			"language":  "language1",
+			"pages":     100,
+			"isbn13":    "9780134190440",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/books")
+
+		bookController := New(newService(mockBookRepositorySuccess{}))
+		if err := midware.JWTMiddleware()(bookController.Create())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.CreateBookResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.CreateBookResponse{
+			Code:    http.StatusForbidden,
+			Message: "forbidden",
+			Data:    nil,
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+func TestDeleteBookFailForbidden(t *testing.T) {
+	t.Run("TestDeleteBookFailForbidden", func(t *testing.T) {
+		token, _ := midware.CreateToken(1, "user1", entity.RoleCustomer)
+
+		request := httptest.NewRequest(http.MethodDelete, "/", nil)
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/books/:id")
+		context.SetParamNames("id")
+		context.SetParamValues("1")
+
+		bookController := New(newService(mockBookRepositorySuccess{}))
+		if err := midware.JWTMiddleware()(bookController.Delete())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.DeleteBookResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.DeleteBookResponse{
+			Code:    http.StatusForbidden,
+			Message: "forbidden",
+			Data:    nil,
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+// TEST PATCH
+
+// patchBook serves a PATCH of book 1 with a merge patch, by an admin.
+func patchBook(controller *BookController, body string) *httptest.ResponseRecorder {
+	token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
+
+	request := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+	request.Header.Set(echo.HeaderContentType, common.MIMEApplicationMergePatchJSON)
+	request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+
+	response := httptest.NewRecorder()
+
+	e := echo.New()
+	e.HTTPErrorHandler = common.HTTPErrorHandler
+
+	context := e.NewContext(request, response)
+	context.SetPath("/books/:id")
+	context.SetParamNames("id")
+	context.SetParamValues("1")
+
+	if err := midware.JWTMiddleware()(controller.Patch())(context); err != nil {
+		e.HTTPErrorHandler(err, context)
+	}
+
+	return response
+}
+
+func TestPatchBookSuccess(t *testing.T) {
+	t.Run("TestPatchBookSuccess", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(bookColumns).AddRow(1, "title1", "author1", "publisher1", "language1", 100, "9780134190440", 1, stamped, stamped, 1, 1))
+		mock.ExpectQuery("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(bookColumns).AddRow(1, "title1", "author1", "publisher1", "language1", 100, "9780134190440", 1, stamped, stamped, 1, 1))
+		mock.ExpectBegin()
+		mock.ExpectPrepare("SELECT version FROM books WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+		mock.ExpectPrepare("UPDATE books SET language = ?, pages = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL").
+			ExpectExec().
+			WithArgs("language2", 120, sqlmock.AnyArg(), 1, 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectPrepare("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(bookColumns).AddRow(1, "title1", "author1", "publisher1", "language2", 120, "9780134190440", 2, stamped, stamped, 1, 1))
+		mock.ExpectCommit()
+
+		response := patchBook(New(newService(bookRepo.New(db, "mysql"))), `{"pages":120,"language":"language2"}`)
+
+		actual := common.UpdateBookResponse{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		expected := common.UpdateBookResponse{
+			Code:    http.StatusOK,
+			Message: "patch book success",
+			Data: []common.BookResponse{
+				{
+					Id:        1,
+					Title:     "title1",
+					Author:    "author1",
+					Publisher: "publisher1",
+					Language:  "language2",
+					Pages:     120,
+					ISBN13:    "9780134190440",
+					CreatedAt: stamped,
+					UpdatedAt: stamped,
+					CreatedBy: &stampedBy,
+					UpdatedBy: &stampedBy,
+				},
+			},
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPatchBookFailRemove(t *testing.T) {
+	t.Run("TestPatchBookFailRemove", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(bookColumns).AddRow(1, "title1", "author1", "publisher1", "language1", 100, "9780134190440", 1, stamped, stamped, 1, 1))
+
+		response := patchBook(New(newService(bookRepo.New(db, "mysql"))), `{"title":null}`)
+
+		actual := struct {
+			Code int                 `json:"code"`
+			Data []domain.FieldError `json:"data"`
+		}{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, actual.Code)
+		assert.Equal(t, []domain.FieldError{{Field: "title", Code: "required", Message: "title is required"}}, actual.Data)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPatchBookDoesNotExist(t *testing.T) {
+	t.Run("TestPatchBookDoesNotExist", func(t *testing.T) {
+		response := patchBook(New(newService(mockBookRepositoryFailOther{})), `{"pages":120}`)
+
+		assert.Equal(t, http.StatusNotFound, response.Code)
+	})
+}
+
+// TEST SOFT DELETE
+
+// listBooks serves a list of books at target, by an admin unless anonymous.
+func listBooks(controller *BookController, target string, anonymous bool) common.GetAllBooksResponse {
+	request := httptest.NewRequest(http.MethodGet, target, nil)
+
+	if !anonymous {
+		token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+	}
+
+	response := httptest.NewRecorder()
+
+	e := echo.New()
+	e.HTTPErrorHandler = common.HTTPErrorHandler
+
+	context := e.NewContext(request, response)
+	context.SetPath("/books")
+
+	if err := midware.OptionalJWTMiddleware()(controller.GetAll())(context); err != nil {
+		e.HTTPErrorHandler(err, context)
+	}
+
+	actual := common.GetAllBooksResponse{}
+	json.Unmarshal(response.Body.Bytes(), &actual)
+
+	return actual
+}
+
+// restoreBook serves a restore of book 1 by an admin.
+func restoreBook(controller *BookController) common.UpdateBookResponse {
+	token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
+
+	request := httptest.NewRequest(http.MethodPost, "/", nil)
+	request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+
+	response := httptest.NewRecorder()
+
+	e := echo.New()
+	e.HTTPErrorHandler = common.HTTPErrorHandler
+
+	context := e.NewContext(request, response)
+	context.SetPath("/books/:id/restore")
+	context.SetParamNames("id")
+	context.SetParamValues("1")
+
+	if err := midware.JWTMiddleware()(controller.Restore())(context); err != nil {
+		e.HTTPErrorHandler(err, context)
+	}
+
+	actual := common.UpdateBookResponse{}
+	json.Unmarshal(response.Body.Bytes(), &actual)
+
+	return actual
+}
+
+func TestGetAllBooksIncludeDeleted(t *testing.T) {
+	t.Run("TestGetAllBooksIncludeDeleted", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		deletedAt := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+		mock.ExpectQuery("SELECT COUNT(*) FROM books").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("SELECT id, title, author, publisher, language, pages, isbn13, deleted_at, created_at, updated_at, created_by, updated_by FROM books ORDER BY id ASC LIMIT ?").
+			WithArgs(21).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "author", "publisher", "language", "pages", "isbn13", "deleted_at", "created_at", "updated_at", "created_by", "updated_by"}).
+				AddRow(1, "title1", "author1", "publisher1", "language1", 100, "9780134190440", deletedAt, stamped, stamped, 1, 1))
+
+		actual := listBooks(New(newService(bookRepo.New(db, "mysql"))), "/books?include_deleted=true", false)
+
+		assert.Equal(t, http.StatusOK, actual.Code)
+		assert.Equal(t, []common.BookResponse{{Id: 1, Title: "title1", Author: "author1", Publisher: "publisher1", Language: "language1", Pages: 100, ISBN13: "9780134190440", CreatedAt: stamped, UpdatedAt: stamped, CreatedBy: &stampedBy, UpdatedBy: &stampedBy, DeletedAt: &deletedAt}}, actual.Data)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("TestGetAllBooksIncludeDeletedAnonymous", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		actual := listBooks(New(newService(bookRepo.New(db, "mysql"))), "/books?include_deleted=true", true)
+
+		assert.Equal(t, http.StatusUnauthorized, actual.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRestoreBook(t *testing.T) {
+	t.Run("TestRestoreBook", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("UPDATE books SET deleted_at = NULL, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL").
+			ExpectExec().
+			WithArgs(sqlmock.AnyArg(), 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectPrepare("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(bookColumns).AddRow(1, "title1", "author1", "publisher1", "language1", 100, "9780134190440", 3, stamped, stamped, 1, 1))
+
+		actual := restoreBook(New(newService(bookRepo.New(db, "mysql"))))
+
+		assert.Equal(t, http.StatusOK, actual.Code)
+		assert.Equal(t, "restore book success", actual.Message)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("TestRestoreBookFailNotDeleted", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("UPDATE books SET deleted_at = NULL, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL").
+			ExpectExec().
+			WithArgs(sqlmock.AnyArg(), 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare("SELECT deleted_at FROM books WHERE id = ?").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(nil))
+
+		actual := restoreBook(New(newService(bookRepo.New(db, "mysql"))))
+
+		assert.Equal(t, common.UpdateBookResponse{Code: http.StatusConflict, Message: "book is not deleted"}, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TEST REQUEST ID
+
+func TestCreateBookRequestID(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		sent string
+		kept bool
+	}{
+		{"TestCreateBookRequestIDKept", "trace-1.2:3_4", true},
+		{"TestCreateBookRequestIDTooLong", strings.Repeat("a", 65), false},
+		{"TestCreateBookRequestIDInvalid", "request 1; DROP", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
+
+			body := `{"title":"title1","author":"author1","publisher":"publisher1","language":"language1","pages":100,"isbn13":"9780134190440"}`
+
+			request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+			request.Header.Set(echo.HeaderXRequestID, tc.sent)
+
+			response := httptest.NewRecorder()
+
+			e := echo.New()
+			e.HTTPErrorHandler = common.HTTPErrorHandler
+
+			context := e.NewContext(request, response)
+			context.SetPath("/books")
+
+			stored := []entity.Audit{}
+			repository := mockBookRepositorySuccess{}
+			service := bookService.New(repository, transaction.Repositories{Books: repository, Audits: mockAuditRepository{stored: &stored}})
+
+			if err := midware.RequestID()(midware.JWTMiddleware()(New(service).Create()))(context); err != nil {
+				e.HTTPErrorHandler(err, context)
+			}
+
+			id := response.Header().Get(echo.HeaderXRequestID)
+
+			assert.Equal(t, http.StatusOK, response.Code, response.Body.String())
+			assert.Len(t, stored, 1)
+			assert.Equal(t, id, stored[0].RequestID)
+			assert.Equal(t, tc.kept, id == tc.sent)
+			assert.NotEmpty(t, id)
+			assert.LessOrEqual(t, len(id), 64)
+		})
+	}
+}
+
+// TEST CONCURRENT CHANGES
+
+// updateBook serves a PUT of book 1 by an admin, with If-Match set to ifMatch
+// unless it is empty.
+func updateBook(controller *BookController, ifMatch string) *httptest.ResponseRecorder {
+	token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
+
+	body := `{"title":"title1","author":"author1","publisher":"publisher1","language":"language1","pages":120,"isbn13":"9780134190440"}`
+
+	request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+	request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+
+	if ifMatch != "" {
+		request.Header.Set(common.HeaderIfMatch, ifMatch)
+	}
+
+	response := httptest.NewRecorder()
+
+	e := echo.New()
+	e.HTTPErrorHandler = common.HTTPErrorHandler
+
+	context := e.NewContext(request, response)
+	context.SetPath("/books/:id")
+	context.SetParamNames("id")
+	context.SetParamValues("1")
+
+	if err := midware.JWTMiddleware()(controller.Update())(context); err != nil {
+		e.HTTPErrorHandler(err, context)
+	}
+
+	return response
+}
+
+func TestUpdateBookIfMatch(t *testing.T) {
+	t.Run("TestUpdateBookIfMatch", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(bookColumns).AddRow(1, "title1", "author1", "publisher1", "language1", 100, "9780134190440", 3, stamped, stamped, 1, 1))
+		mock.ExpectQuery("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(bookColumns).AddRow(1, "title1", "author1", "publisher1", "language1", 100, "9780134190440", 3, stamped, stamped, 1, 1))
+		mock.ExpectPrepare("UPDATE books SET title = ?, author = ?, publisher = ?, language = ?, pages = ?, isbn13 = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL").
+			ExpectExec().
+			WithArgs("title1", "author1", "publisher1", "language1", 120, "9780134190440", sqlmock.AnyArg(), 1, 1, 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(bookColumns).AddRow(1, "title1", "author1", "publisher1", "language1", 120, "9780134190440", 4, stamped, stamped, 1, 1))
+
+		response := updateBook(New(newService(bookRepo.New(db, "mysql"))), `"3"`)
+
+		assert.Equal(t, http.StatusOK, response.Code, response.Body.String())
+		assert.Equal(t, `"4"`, response.Header().Get(common.HeaderETag))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUpdateBookFailIfMatch(t *testing.T) {
+	t.Run("TestUpdateBookFailIfMatch", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(bookColumns).AddRow(1, "title1", "author1", "publisher1", "language1", 100, "9780134190440", 3, stamped, stamped, 1, 1))
+
+		response := updateBook(New(newService(bookRepo.New(db, "mysql"))), `"2"`)
+
+		actual := common.UpdateBookResponse{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		expected := common.UpdateBookResponse{
+			Code:    http.StatusPreconditionFailed,
+			Message: "if-match does not match the current version",
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUpdateBookFailChanged(t *testing.T) {
+	t.Run("TestUpdateBookFailChanged", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(bookColumns).AddRow(1, "title1", "author1", "publisher1", "language1", 100, "9780134190440", 3, stamped, stamped, 1, 1))
+		mock.ExpectQuery("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(bookColumns).AddRow(1, "title1", "author1", "publisher1", "language1", 100, "9780134190440", 3, stamped, stamped, 1, 1))
+		mock.ExpectPrepare("UPDATE books SET title = ?, author = ?, publisher = ?, language = ?, pages = ?, isbn13 = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL").
+			ExpectExec().
+			WithArgs("title1", "author1", "publisher1", "language1", 120, "9780134190440", sqlmock.AnyArg(), 1, 1, 3).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare("SELECT version FROM books WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+
+		response := updateBook(New(newService(bookRepo.New(db, "mysql"))), "")
+
+		actual := common.UpdateBookResponse{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		expected := common.UpdateBookResponse{
+			Code:    http.StatusPreconditionFailed,
+			Message: "book has been changed",
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPatchBookFailChanged(t *testing.T) {
+	t.Run("TestPatchBookFailChanged", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(bookColumns).AddRow(1, "title1", "author1", "publisher1", "language1", 100, "9780134190440", 3, stamped, stamped, 1, 1))
+		mock.ExpectQuery("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(bookColumns).AddRow(1, "title1", "author1", "publisher1", "language1", 100, "9780134190440", 3, stamped, stamped, 1, 1))
+		mock.ExpectBegin()
+		mock.ExpectPrepare("SELECT version FROM books WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+		mock.ExpectRollback()
+
+		response := patchBook(New(newService(bookRepo.New(db, "mysql"))), `{"pages":120}`)
+
+		actual := common.UpdateBookResponse{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		expected := common.UpdateBookResponse{
+			Code:    http.StatusPreconditionFailed,
+			Message: "book has been changed",
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TEST SQL INJECTION
+
+const injection = "Robert'); DROP TABLE books; -- "
+
+func TestCreateBookInjection(t *testing.T) {
+	t.Run("TestCreateBookInjection", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectPrepare("INSERT INTO books (title, author, publisher, language, pages, isbn13, created_at, updated_at, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		mock.ExpectPrepare("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL")
+		mock.ExpectExec("INSERT INTO books (title, author, publisher, language, pages, isbn13, created_at, updated_at, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)").
+			WithArgs(injection, "author1", "publisher1", "language1", 100, "9780134190440", sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(bookColumns).
+				AddRow(1, injection, "author1", "publisher1", "language1", 100, "9780134190440", 1, stamped, stamped, 1, 1))
+		mock.ExpectCommit()
+
+		token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
+
+		requestBody, _ := json.Marshal(map[string]interface{}{
+			"title":     injection,
+			"author":    "author1",
+			"publisher": "publisher1",
+			"language":  "language1",
+			"pages":     100,
+			"isbn13":    "9780134190440",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/books")
+
+		bookController := New(newService(bookRepo.New(db, "mysql")))
+		if err := midware.JWTMiddleware()(bookController.Create())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.CreateBookResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.CreateBookResponse{
+			Code:    http.StatusOK,
+			Message: "create book success",
+			Data: []common.BookResponse{
+				{
+					Id:        1,
+					Title:     injection,
+					Author:    "author1",
+					Publisher: "publisher1",
+					Language:  "language1",
+					Pages:     100,
+					ISBN13:    "9780134190440",
+					CreatedAt: stamped,
+					UpdatedAt: stamped,
+					CreatedBy: &stampedBy,
+					UpdatedBy: &stampedBy,
+				},
+			},
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUpdateBookInjection(t *testing.T) {
+	t.Run("TestUpdateBookInjection", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(bookColumns).
+				AddRow(1, "title1", "author1", "publisher1", "language1", 100, "9780134190440", 1, stamped, stamped, 1, 1))
+		mock.ExpectQuery("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(bookColumns).
+				AddRow(1, "title1", "author1", "publisher1", "language1", 100, "9780134190440", 1, stamped, stamped, 1, 1))
+		mock.ExpectPrepare("UPDATE books SET title = ?, author = ?, publisher = ?, language = ?, pages = ?, isbn13 = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL").
+			ExpectExec().
+			WithArgs("title1", injection, "publisher1", "language1", 100, "9780134190440", sqlmock.AnyArg(), 1, 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(bookColumns).
+				AddRow(1, "title1", injection, "publisher1", "language1", 100, "9780134190440", 2, stamped, stamped, 1, 1))
+
+		token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
+
+		requestBody, _ := json.Marshal(map[string]interface{}{
+			"title":     "title1",
+			"author":    injection,
+			"publisher": "publisher1",
+			"language":  "language1",
+			"pages":     100,
+			"isbn13":    "9780134190440",
+		})
+
+		request := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/books/:id")
+		context.SetParamNames("id")
+		context.SetParamValues("1")
+
+		bookController := New(newService(bookRepo.New(db, "mysql")))
+		if err := midware.JWTMiddleware()(bookController.Update())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.UpdateBookResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.UpdateBookResponse{
+			Code:    http.StatusOK,
+			Message: "update book success",
+			Data: []common.BookResponse{
+				{
+					Id:        1,
+					Title:     "title1",
+					Author:    injection,
+					Publisher: "publisher1",
+					Language:  "language1",
+					Pages:     100,
+					ISBN13:    "9780134190440",
+					CreatedAt: stamped,
+					UpdatedAt: stamped,
+					CreatedBy: &stampedBy,
+					UpdatedBy: &stampedBy,
+				},
+			},
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TEST CREATE TRANSACTION
+
+func TestCreateBookFailReadBack(t *testing.T) {
+	t.Run("TestCreateBookFailReadBack", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectPrepare("INSERT INTO books (title, author, publisher, language, pages, isbn13, created_at, updated_at, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		mock.ExpectPrepare("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL")
+		mock.ExpectExec("INSERT INTO books (title, author, publisher, language, pages, isbn13, created_at, updated_at, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)").
+			WithArgs("title1", "author1", "publisher1", "language1", 100, "9780134190440", sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 1).
+			WillReturnResult(sqlmock.NewResult(7, 1))
+		mock.ExpectQuery("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			WithArgs(7).
+			WillReturnError(assert.AnError)
+		mock.ExpectRollback()
+
+		token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
+
+		requestBody, _ := json.Marshal(map[string]interface{}{
+			"title":     "title1",
+			"author":    "author1",
+			"publisher": "publisher1",
+			"language":  "language1",
+			"pages":     100,
+			"isbn13":    "9780134190440",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/books")
+
+		bookController := New(newService(bookRepo.New(db, "mysql")))
+		if err := midware.JWTMiddleware()(bookController.Create())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.CreateBookResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.CreateBookResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "create book failed",
+			Data:    nil,
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TEST SEARCH
+
+const (
+	querySearchCount = "SELECT COUNT(*) FROM books WHERE deleted_at IS NULL AND MATCH (title, author, publisher) AGAINST (? IN BOOLEAN MODE)"
+	querySearch      = "SELECT id, title, author, publisher, language, pages, isbn13, created_at, updated_at, created_by, updated_by, MATCH (title, author, publisher) AGAINST (? IN BOOLEAN MODE) AS score FROM books WHERE deleted_at IS NULL AND MATCH (title, author, publisher) AGAINST (? IN BOOLEAN MODE) ORDER BY score DESC, id ASC LIMIT ?"
+	queryLikeCount   = "SELECT COUNT(*) FROM books WHERE deleted_at IS NULL AND (title LIKE ? ESCAPE '!' OR author LIKE ? ESCAPE '!' OR publisher LIKE ? ESCAPE '!')"
+	queryLike        = "SELECT id, title, author, publisher, language, pages, isbn13, created_at, updated_at, created_by, updated_by, (CASE WHEN title LIKE ? ESCAPE '!' THEN 3 ELSE 0 END + CASE WHEN author LIKE ? ESCAPE '!' THEN 2 ELSE 0 END + CASE WHEN publisher LIKE ? ESCAPE '!' THEN 1 ELSE 0 END) AS score FROM books WHERE deleted_at IS NULL AND (title LIKE ? ESCAPE '!' OR author LIKE ? ESCAPE '!' OR publisher LIKE ? ESCAPE '!') ORDER BY score DESC, id ASC LIMIT ?"
+)
+
+var searchColumns = []string{"id", "title", "author", "publisher", "language", "pages", "isbn13", "created_at", "updated_at", "created_by", "updated_by", "score"}
+
+func search(bookController *BookController, target string) common.SearchBooksResponse {
+	request := httptest.NewRequest(http.MethodGet, target, nil)
+
+	response := httptest.NewRecorder()
+
+	e := echo.New()
+	e.HTTPErrorHandler = common.HTTPErrorHandler
+
+	context := e.NewContext(request, response)
+	context.SetPath("/books/search")
+
+	if err := bookController.Search()(context); err != nil {
+		e.HTTPErrorHandler(err, context)
+	}
+
+	actual := common.SearchBooksResponse{}
+	body := response.Body.String()
+	json.Unmarshal([]byte(body), &actual)
+
+	return actual
+}
+
+func TestSearchBooksFullText(t *testing.T) {
+	t.Run("TestSearchBooksFullText", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare(querySearchCount).
+			ExpectQuery().
+			WithArgs("+tolk* +ring*").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(querySearch).
+			WithArgs("+tolk* +ring*", "+tolk* +ring*", query.DefaultLimit+1).
+			WillReturnRows(sqlmock.NewRows(searchColumns).
+				AddRow(1, "The Lord of the Rings", "J.R.R. Tolkien", "Allen & Unwin", "english", 1178, "9780134190440", stamped, stamped, 1, 1, 2.5))
+
+		actual := search(New(newService(bookRepo.New(db, "mysql"))), "/books/search?q=Tolk+(ring)")
+
+		expected := common.SearchBooksResponse{
+			Code:    http.StatusOK,
+			Message: "search books success",
+			Data: []common.BookSearchResult{
+				{
+					BookResponse: common.BookResponse{
+						Id:        1,
+						Title:     "The Lord of the Rings",
+						Author:    "J.R.R. Tolkien",
+						Publisher: "Allen & Unwin",
+						Language:  "english",
+						Pages:     1178,
+						ISBN13:    "9780134190440",
+						CreatedAt: stamped,
+						UpdatedAt: stamped,
+						CreatedBy: &stampedBy,
+						UpdatedBy: &stampedBy,
+					},
+					Score: 2.5,
+					Highlights: map[string]string{
+						"title":  "The Lord of the <em>Ring</em>s",
+						"author": "J.R.R. <em>Tolk</em>ien",
+					},
+				},
+			},
+			Meta: query.Page{
+				Total: 1,
+				Page:  1,
+				Limit: query.DefaultLimit,
+				Links: query.Links{
+					Self:  "/books/search?q=Tolk+(ring)",
+					First: "/books/search?page=1&q=Tolk+%28ring%29",
+					Last:  "/books/search?page=1&q=Tolk+%28ring%29",
+				},
+			},
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSearchBooksFallback(t *testing.T) {
+	t.Run("TestSearchBooksMissingIndex", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare(querySearchCount).
+			ExpectQuery().
+			WithArgs("+50*").
+			WillReturnError(&mysql.MySQLError{Number: 1191, Message: "Can't find FULLTEXT index matching the column list"})
+		mock.ExpectPrepare(queryLikeCount).
+			ExpectQuery().
+			WithArgs("%50!%%", "%50!%%", "%50!%%").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(queryLike).
+			WithArgs("%50!%%", "%50!%%", "%50!%%", "%50!%%", "%50!%%", "%50!%%", query.DefaultLimit+1).
+			WillReturnRows(sqlmock.NewRows(searchColumns).
+				AddRow(1, "50% <off>", "author1", "publisher1", "language1", 100, "9780134190440", stamped, stamped, 1, 1, 3))
+
+		actual := search(New(newService(bookRepo.New(db, "mysql"))), "/books/search?q=50%25")
+
+		assert.Equal(t, http.StatusOK, actual.Code)
+		assert.Equal(t, map[string]string{"title": "<em>50</em>% &lt;off&gt;"}, actual.Data[0].Highlights)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("TestSearchBooksOtherDriver", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare(queryLikeCount).
+			ExpectQuery().
+			WithArgs("%tolkien%", "%tolkien%", "%tolkien%").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(queryLike).
+			WithArgs("%tolkien%", "%tolkien%", "%tolkien%", "%tolkien%", "%tolkien%", "%tolkien%", query.DefaultLimit+1).
+			WillReturnRows(sqlmock.NewRows(searchColumns))
+
+		actual := search(New(newService(bookRepo.New(db, "sqlite3"))), "/books/search?q=tolkien")
+
+		assert.Equal(t, http.StatusOK, actual.Code)
+		assert.Equal(t, "no matching books", actual.Message)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSearchBooksFail(t *testing.T) {
+	t.Run("TestSearchBooksFailMissingQuery", func(t *testing.T) {
+		actual := search(New(newService(mockBookRepositorySuccess{})), "/books/search?q=+")
+
+		expected := common.SearchBooksResponse{
+			Code:    http.StatusBadRequest,
+			Message: "missing search query",
+			Data:    nil,
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("TestSearchBooksFailPunctuationQuery", func(t *testing.T) {
+		actual := search(New(newService(mockBookRepositorySuccess{})), "/books/search?q=!!!")
+
+		expected := common.SearchBooksResponse{
+			Code:    http.StatusBadRequest,
+			Message: "missing search query",
+			Data:    nil,
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("TestSearchBooksFailPage", func(t *testing.T) {
+		actual := search(New(newService(mockBookRepositorySuccess{})), "/books/search?q=title&page=0")
+
+		expected := common.SearchBooksResponse{
+			Code:    http.StatusBadRequest,
+			Message: "invalid page",
+			Data:    nil,
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("TestSearchBooksFailRepo", func(t *testing.T) {
+		actual := search(New(newService(mockBookRepositoryFailRepo{})), "/books/search?q=title")
+
+		expected := common.SearchBooksResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "search books failed",
+			Data:    nil,
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+// TEST REQUEST DEADLINE
+
+func TestGetBookTimeout(t *testing.T) {
+	t.Run("TestGetBookTimeout", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillDelayFor(time.Second).
+			WillReturnRows(sqlmock.NewRows(bookColumns))
+
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/books/:id")
+		context.SetParamNames("id")
+		context.SetParamValues("1")
+
+		bookController := New(newService(bookRepo.New(db, "mysql")))
+
+		start := time.Now()
+		if err := midware.RequestTimeout(10 * time.Millisecond)(bookController.Get())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.GetBookResponse{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		expected := common.GetBookResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "get book failed",
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.Less(t, int64(time.Since(start)), int64(time.Second))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TEST PROBLEM DETAILS
+
+func TestCreateBookProblem(t *testing.T) {
+	t.Run("TestCreateBookProblem", func(t *testing.T) {
+		token, _ := midware.CreateToken(1, "admin", entity.RoleAdmin)
+
+		requestBody, _ := json.Marshal(map[string]interface{}{
+			"title":     "title1",
+			"author":    "author1",
+			"publisher": "publisher1",
+			"language":  "language1",
+			"pages":     100,
+			"isbn13":    "isbn1",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/books", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		request.Header.Set(echo.HeaderAccept, "application/problem+json, application/json;q=0.9")
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/books")
+
+		bookController := New(newService(mockBookRepositorySuccess{}))
+		if err := midware.JWTMiddleware()(bookController.Create())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.Problem{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		expected := common.Problem{
+			Type:     "about:blank",
+			Title:    "Unprocessable Entity",
+			Status:   http.StatusUnprocessableEntity,
+			Detail:   "validation failed",
+			Instance: "/books",
+			Errors: []domain.FieldError{
+				{Field: "isbn13", Code: "isbn13", Message: "isbn13 must be a valid ISBN-13"},
+			},
+		}
+
+		assert.Equal(t, common.MIMEApplicationProblemJSON, response.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("TestGetBookProblemConfigured", func(t *testing.T) {
+		common.SetErrorFormat(common.FormatProblem)
+		defer common.SetErrorFormat(common.FormatEnvelope)
+
+		request := httptest.NewRequest(http.MethodGet, "/books/abc", nil)
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		context := e.NewContext(request, response)
+		context.SetPath("/books/:id")
+		context.SetParamNames("id")
+		context.SetParamValues("abc")
+
+		bookController := New(newService(mockBookRepositorySuccess{}))
+		bookController.Get()(context)
+
+		actual := common.Problem{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		expected := common.Problem{
+			Type:     "about:blank",
+			Title:    "Bad Request",
+			Status:   http.StatusBadRequest,
+			Detail:   "invalid book id",
+			Instance: "/books/abc",
+		}
+
+		assert.Equal(t, common.MIMEApplicationProblemJSON, response.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, expected, actual)
+	})
+}
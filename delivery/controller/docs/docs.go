@@ -0,0 +1,61 @@
+package docs
+
+import (
+	"embed"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/labstack/echo/v4"
+)
+
+const MIMEApplicationYAML = "application/yaml"
+
+// index is the Swagger UI page, which loads Swagger UI from assets.
+//
+//go:embed index.html
+var index []byte
+
+// assets holds the Swagger UI assets, fetched by go generate from a pinned
+// release rather than loaded from a CDN.
+//
+//go:generate sh fetch-swagger-ui.sh
+//go:embed swagger-ui
+var assets embed.FS
+
+type DocsController struct {
+	spec []byte
+}
+
+func New(spec []byte) *DocsController {
+	return &DocsController{
+		spec: spec,
+	}
+}
+
+func (dc DocsController) Spec() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.Blob(http.StatusOK, MIMEApplicationYAML, dc.spec)
+	}
+}
+
+func (dc DocsController) UI() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.HTMLBlob(http.StatusOK, index)
+	}
+}
+
+// Assets serves the Swagger UI assets the page loads.
+func (dc DocsController) Assets() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		name := path.Join("swagger-ui", c.Param("*"))
+		content, err := fs.ReadFile(assets, name)
+
+		if err != nil {
+			return echo.ErrNotFound
+		}
+
+		return c.Blob(http.StatusOK, mime.TypeByExtension(path.Ext(name)), content)
+	}
+}
@@ -0,0 +1,29 @@
+#!/bin/sh
+# Fetches the Swagger UI assets into swagger-ui, checking the package against
+# the integrity the npm registry published for it.
+set -eu
+
+VERSION=4.5.0
+REGISTRY=https://registry.npmjs.org/swagger-ui-dist
+
+cd "$(dirname "$0")"
+
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+curl -fsSL "$REGISTRY/$VERSION" -o "$tmp/package.json"
+curl -fsSL "$REGISTRY/-/swagger-ui-dist-$VERSION.tgz" -o "$tmp/package.tgz"
+
+want=$(sed -n 's/.*"integrity":"\(sha512-[^"]*\)".*/\1/p' "$tmp/package.json")
+got="sha512-$(openssl dgst -sha512 -binary "$tmp/package.tgz" | openssl base64 -A)"
+
+if [ -z "$want" ] || [ "$want" != "$got" ]; then
+	echo "swagger-ui-dist $VERSION: integrity $got, want $want" >&2
+	exit 1
+fi
+
+tar -xzf "$tmp/package.tgz" -C "$tmp"
+
+for file in swagger-ui.css swagger-ui-bundle.js LICENSE; do
+	cp "$tmp/package/$file" "swagger-ui/$file"
+done
@@ -5,37 +5,55 @@ import (
 	"rest-api/design-pattern/delivery/common"
 	"rest-api/design-pattern/delivery/midware"
 	"rest-api/design-pattern/entity"
-	productRepo "rest-api/design-pattern/repository/product"
+	productService "rest-api/design-pattern/service/product"
+	"rest-api/design-pattern/util/query"
 	"strconv"
 
 	"github.com/labstack/echo/v4"
 )
 
 type ProductController struct {
-	repository productRepo.Product
+	service productService.Product
 }
 
-func New(product productRepo.Product) *ProductController {
+func New(product productService.Product) *ProductController {
 	return &ProductController{
-		repository: product,
+		service: product,
 	}
 }
 
 func (pc ProductController) GetAll() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		products, err := pc.repository.GetAll()
 		code := http.StatusOK
 
+		opts, err := query.Parse(c.QueryParams(), productService.ListSpec)
+
+		if err != nil {
+			code = http.StatusBadRequest
+			return common.Fail(c, code, err.Error())
+		}
+
+		// Anonymous requests may list products, but not deleted ones.
+		actor, err := midware.ExtractActor(c)
+
+		if err != nil && opts.IncludeDeleted {
+			code = http.StatusUnauthorized
+			return common.Fail(c, code, "unauthorized")
+		}
+
+		products, page, err := pc.service.GetAll(c.Request().Context(), actor, opts)
+
 		if err != nil {
-			code = http.StatusInternalServerError
-			return c.JSON(code, common.SimpleResponse(code, "get all products failed", nil))
+			return common.Error(err, "get all products failed")
 		}
 
+		page.SetLinks(c.Request().URL)
+
 		if len(products) == 0 {
-			return c.JSON(code, common.SimpleResponse(code, "products directory empty", nil))
+			return c.JSON(code, common.PagedResponse(code, "products directory empty", nil, page))
 		}
 
-		return c.JSON(code, common.SimpleResponse(code, "get all products success", products))
+		return c.JSON(code, common.PagedResponse(code, "get all products success", common.NewProductResponses(products), page))
 	}
 }
 
@@ -46,68 +64,62 @@ func (pc ProductController) Get() echo.HandlerFunc {
 
 		if err != nil {
 			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "invalid product id", nil))
+			return common.Fail(c, code, "invalid product id")
 		}
 
-		product, err := pc.repository.Get(id)
+		product, err := pc.service.Get(c.Request().Context(), id)
 
 		if err != nil {
-			code = http.StatusInternalServerError
-			return c.JSON(code, common.SimpleResponse(code, "get product failed", nil))
+			return common.Error(err, "get product failed")
 		}
 
-		if product == (common.ProductResponse{}) {
-			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "product does not exist", nil))
+		common.SetETag(c, product.Version)
+
+		if common.NotModified(c, product.Version) {
+			return c.NoContent(http.StatusNotModified)
 		}
 
-		return c.JSON(code, common.SimpleResponse(code, "get product success", []common.ProductResponse{product}))
+		return c.JSON(code, common.SimpleResponse(code, "get product success", []common.ProductResponse{common.NewProductResponse(product)}))
 	}
 }
 
 func (pc ProductController) Create() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		userid, err := midware.ExtractId(c)
+		actor, err := midware.ExtractActor(c)
 		code := http.StatusOK
 
 		if err != nil {
 			code = http.StatusUnauthorized
-			return c.JSON(code, common.SimpleResponse(code, "unauthorized", nil))
+			return common.Fail(c, code, "unauthorized")
 		}
 
 		input := entity.Product{}
-		input.UserID = userid
 
 		if err := c.Bind(&input); err != nil {
 			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "binding failed", nil))
+			return common.Fail(c, code, "binding failed")
 		}
 
-		id, name, err := pc.repository.Create(input)
+		product, err := pc.service.Create(c.Request().Context(), actor, input)
 
 		if err != nil {
-			code = http.StatusInternalServerError
-			return c.JSON(code, common.SimpleResponse(code, "create product failed", nil))
+			return common.Error(err, "create product failed")
 		}
 
-		product := common.ProductResponse{}
-		product.Id = id
-		product.Merchant = name
-		product.Name = input.Name
-		product.Price = input.Price
+		common.SetETag(c, product.Version)
 
-		return c.JSON(code, common.SimpleResponse(code, "create product success", []common.ProductResponse{product}))
+		return c.JSON(code, common.SimpleResponse(code, "create product success", []common.ProductResponse{common.NewProductResponse(product)}))
 	}
 }
 
 func (pc ProductController) Update() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		userid, err := midware.ExtractId(c)
+		actor, err := midware.ExtractActor(c)
 		code := http.StatusOK
 
 		if err != nil {
 			code = http.StatusUnauthorized
-			return c.JSON(code, common.SimpleResponse(code, "unauthorized", nil))
+			return common.Fail(c, code, "unauthorized")
 		}
 
 		product := entity.Product{}
@@ -116,46 +128,156 @@ func (pc ProductController) Update() echo.HandlerFunc {
 
 		if err != nil {
 			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "invalid product id", nil))
+			return common.Fail(c, code, "invalid product id")
 		}
 
 		if err := c.Bind(&product); err != nil {
 			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "binding failed", nil))
+			return common.Fail(c, code, "binding failed")
+		}
+
+		current, err := pc.service.Get(c.Request().Context(), id)
+
+		if err != nil {
+			return common.Error(err, "update product failed")
 		}
 
-		product.UserID = userid
 		product.Id = id
 
-		if code, err := pc.repository.Update(product); err != nil {
-			return c.JSON(code, common.SimpleResponse(code, err.Error(), nil))
+		if product.Version, err = common.IfMatch(c, current.Version); err != nil {
+			return err
 		}
 
-		return c.JSON(code, common.SimpleResponse(code, "update product success", []entity.Product{product}))
+		product, err = pc.service.Update(c.Request().Context(), actor, product)
+
+		if err != nil {
+			return common.Error(err, "update product failed")
+		}
+
+		common.SetETag(c, product.Version)
+
+		return c.JSON(code, common.SimpleResponse(code, "update product success", []common.ProductResponse{common.NewProductResponse(product)}))
+	}
+}
+
+// Patch changes the fields of the product with id that the patch in the
+// body, of either media type BindPatch takes, touches.
+func (pc ProductController) Patch() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		code := http.StatusOK
+
+		actor, err := midware.ExtractActor(c)
+
+		if err != nil {
+			code = http.StatusUnauthorized
+			return common.Fail(c, code, "unauthorized")
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+
+		if err != nil {
+			code = http.StatusBadRequest
+			return common.Fail(c, code, "invalid product id")
+		}
+
+		current, err := pc.service.Get(c.Request().Context(), id)
+
+		if err != nil {
+			return common.Error(err, "patch product failed")
+		}
+
+		version, err := common.IfMatch(c, current.Version)
+
+		if err != nil {
+			return err
+		}
+
+		product := entity.Product{}
+
+		fields, err := common.BindPatch(c, common.NewProductResponse(current), &product)
+
+		if err != nil {
+			return err
+		}
+
+		product.Id = id
+		product.Version = version
+
+		product, err = pc.service.Patch(c.Request().Context(), actor, product, fields)
+
+		if err != nil {
+			return common.Error(err, "patch product failed")
+		}
+
+		common.SetETag(c, product.Version)
+
+		return c.JSON(code, common.SimpleResponse(code, "patch product success", []common.ProductResponse{common.NewProductResponse(product)}))
 	}
 }
 
 func (pc ProductController) Delete() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		userid, err := midware.ExtractId(c)
+		actor, err := midware.ExtractActor(c)
 		code := http.StatusOK
 
 		if err != nil {
 			code = http.StatusUnauthorized
-			return c.JSON(code, common.SimpleResponse(code, "unauthorized", nil))
+			return common.Fail(c, code, "unauthorized")
 		}
 
 		id, err := strconv.Atoi(c.Param("id"))
 
 		if err != nil {
 			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "invalid product id", nil))
+			return common.Fail(c, code, "invalid product id")
+		}
+
+		current, err := pc.service.Get(c.Request().Context(), id)
+
+		if err != nil {
+			return common.Error(err, "delete product failed")
+		}
+
+		version, err := common.IfMatch(c, current.Version)
+
+		if err != nil {
+			return err
 		}
 
-		if code, err := pc.repository.Delete(id, userid); err != nil {
-			return c.JSON(code, common.SimpleResponse(code, err.Error(), nil))
+		if err := pc.service.Delete(c.Request().Context(), actor, id, version); err != nil {
+			return common.Error(err, "delete product failed")
 		}
 
 		return c.JSON(code, common.SimpleResponse(code, "delete product success", nil))
 	}
 }
+
+func (pc ProductController) Restore() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		code := http.StatusOK
+
+		actor, err := midware.ExtractActor(c)
+
+		if err != nil {
+			code = http.StatusUnauthorized
+			return common.Fail(c, code, "unauthorized")
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+
+		if err != nil {
+			code = http.StatusBadRequest
+			return common.Fail(c, code, "invalid product id")
+		}
+
+		product, err := pc.service.Restore(c.Request().Context(), actor, id)
+
+		if err != nil {
+			return common.Error(err, "restore product failed")
+		}
+
+		common.SetETag(c, product.Version)
+
+		return c.JSON(code, common.SimpleResponse(code, "restore product success", []common.ProductResponse{common.NewProductResponse(product)}))
+	}
+}
@@ -2,25 +2,63 @@ package product
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"rest-api/design-pattern/delivery/common"
 	"rest-api/design-pattern/delivery/midware"
+	"rest-api/design-pattern/domain"
 	"rest-api/design-pattern/entity"
+	auditRepo "rest-api/design-pattern/repository/audit"
+	productRepo "rest-api/design-pattern/repository/product"
+	"rest-api/design-pattern/repository/transaction"
+	productService "rest-api/design-pattern/service/product"
+	"rest-api/design-pattern/util/query"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
 
+// mockAuditRepository discards the records it is asked to store.
+type mockAuditRepository struct {
+	auditRepo.Audit
+}
+
+func (m mockAuditRepository) Create(context.Context, entity.Audit) error {
+	return nil
+}
+
+// newService returns a service on repository, run without a transaction and
+// unaudited.
+func newService(repository productRepo.Product) *productService.ProductService {
+	return productService.New(repository, transaction.Repositories{Products: repository, Audits: mockAuditRepository{}})
+}
+
+var productColumns = []string{"id", "user_id", "merchant", "name", "price", "version", "created_at", "updated_at", "created_by", "updated_by"}
+
+// stamped and stampedBy are when and by whom the rows read back in tests
+// were created and last updated.
+var (
+	stamped   = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	stampedBy = 1
+)
+
 // TEST SUCCESS
 
 type mockProductRepositorySuccess struct{}
 
-func (m mockProductRepositorySuccess) GetAll() ([]common.ProductResponse, error) {
-	return []common.ProductResponse{
+func (m mockProductRepositorySuccess) GetAll(ctx context.Context, opts query.Options) ([]entity.Product, query.Page, error) {
+	page := opts.NewPage()
+	page.Total = 2
+
+	return []entity.Product{
 		{
 			Id:       1,
 			Merchant: "merchant1",
@@ -33,11 +71,11 @@ func (m mockProductRepositorySuccess) GetAll() ([]common.ProductResponse, error)
 			Name:     "product2",
 			Price:    100,
 		},
-	}, nil
+	}, page, nil
 }
 
-func (m mockProductRepositorySuccess) Get(int) (common.ProductResponse, error) {
-	return common.ProductResponse{
+func (m mockProductRepositorySuccess) Get(context.Context, int) (entity.Product, error) {
+	return entity.Product{
 		Id:       1,
 		Merchant: "merchant1",
 		Name:     "product1",
@@ -45,16 +83,45 @@ func (m mockProductRepositorySuccess) Get(int) (common.ProductResponse, error) {
 	}, nil
 }
 
-func (m mockProductRepositorySuccess) Create(entity.Product) (int, string, error) {
-	return 1, "user1", nil
+func (m mockProductRepositorySuccess) GetByUser(context.Context, int) ([]entity.Product, error) {
+	return []entity.Product{}, nil
 }
 
-func (m mockProductRepositorySuccess) Update(entity.Product) (int, error) {
-	return http.StatusOK, nil
+func (m mockProductRepositorySuccess) Create(ctx context.Context, product entity.Product) (entity.Product, error) {
+	return entity.Product{
+		Id:       1,
+		Merchant: "user1",
+		Name:     product.Name,
+		Price:    product.Price,
+	}, nil
+}
+
+func (m mockProductRepositorySuccess) Update(context.Context, entity.Product) error {
+	return nil
+}
+
+func (m mockProductRepositorySuccess) Patch(ctx context.Context, product entity.Product, fields []string) (entity.Product, error) {
+	return m.Get(ctx, product.Id)
 }
 
-func (m mockProductRepositorySuccess) Delete(int, int) (int, error) {
-	return http.StatusOK, nil
+func (m mockProductRepositorySuccess) Delete(context.Context, int, int, int) error {
+	return nil
+}
+
+func (m mockProductRepositorySuccess) DeleteByUser(context.Context, int, int) error {
+	return nil
+}
+
+func (m mockProductRepositorySuccess) Restore(context.Context, int, int) error {
+	return nil
+}
+
+func (m mockProductRepositorySuccess) RestoreByUser(context.Context, int, int) error {
+	return nil
+}
+
+func (m mockProductRepositorySuccess) Purge(context.Context, time.Time) (int64, error) {
+	return 0, nil
 }
 
 func TestGetAllProductsSuccess(t *testing.T) {
@@ -64,12 +131,15 @@ func TestGetAllProductsSuccess(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/products")
 
-		productController := New(mockProductRepositorySuccess{})
-		productController.GetAll()(context)
+		productController := New(newService(mockProductRepositorySuccess{}))
+		if err := productController.GetAll()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetAllProductsResponse{}
 		body := response.Body.String()
@@ -92,6 +162,16 @@ func TestGetAllProductsSuccess(t *testing.T) {
 					Price:    100,
 				},
 			},
+			Meta: query.Page{
+				Total: 2,
+				Page:  1,
+				Limit: query.DefaultLimit,
+				Links: query.Links{
+					Self:  "/",
+					First: "/?page=1",
+					Last:  "/?page=1",
+				},
+			},
 		}
 
 		assert.Equal(t, expected, actual)
@@ -105,14 +185,17 @@ func TestGetProductSuccess(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/products/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		productController := New(mockProductRepositorySuccess{})
-		productController.Get()(context)
+		productController := New(newService(mockProductRepositorySuccess{}))
+		if err := productController.Get()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetProductResponse{}
 		body := response.Body.String()
@@ -137,7 +220,7 @@ func TestGetProductSuccess(t *testing.T) {
 
 func TestCreateProductSuccess(t *testing.T) {
 	t.Run("TestCreateProductSuccess", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleMerchant)
 
 		requestBody, _ := json.Marshal(map[string]interface{}{
 			"name":  "product1",
@@ -152,12 +235,15 @@ func TestCreateProductSuccess(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/products")
 
-		productController := New(mockProductRepositorySuccess{})
-		midware.JWTMiddleware()(productController.Create())(context)
+		productController := New(newService(mockProductRepositorySuccess{}))
+		if err := midware.JWTMiddleware()(productController.Create())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.CreateProductResponse{}
 		body := response.Body.String()
@@ -182,7 +268,7 @@ func TestCreateProductSuccess(t *testing.T) {
 
 func TestUpdateProductSuccess(t *testing.T) {
 	t.Run("TestUpdateProductSuccess", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleMerchant)
 
 		requestBody, _ := json.Marshal(map[string]interface{}{
 			"name":  "product1",
@@ -197,14 +283,17 @@ func TestUpdateProductSuccess(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/products/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		productController := New(mockProductRepositorySuccess{})
-		midware.JWTMiddleware()(productController.Update())(context)
+		productController := New(newService(mockProductRepositorySuccess{}))
+		if err := midware.JWTMiddleware()(productController.Update())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.UpdateProductResponse{}
 		body := response.Body.String()
@@ -213,12 +302,12 @@ func TestUpdateProductSuccess(t *testing.T) {
 		expected := common.UpdateProductResponse{
 			Code:    http.StatusOK,
 			Message: "update product success",
-			Data: []entity.Product{
+			Data: []common.ProductResponse{
 				{
-					Id:     1,
-					UserID: 1,
-					Name:   "product1",
-					Price:  100,
+					Id:       1,
+					Merchant: "merchant1",
+					Name:     "product1",
+					Price:    100,
 				},
 			},
 		}
@@ -229,7 +318,7 @@ func TestUpdateProductSuccess(t *testing.T) {
 
 func TestDeleteProductSuccess(t *testing.T) {
 	t.Run("TestDeleteProductSuccess", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleMerchant)
 
 		request := httptest.NewRequest(http.MethodDelete, "/", nil)
 		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
@@ -238,14 +327,17 @@ func TestDeleteProductSuccess(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/products/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		productController := New(mockProductRepositorySuccess{})
-		midware.JWTMiddleware()(productController.Delete())(context)
+		productController := New(newService(mockProductRepositorySuccess{}))
+		if err := midware.JWTMiddleware()(productController.Delete())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.DeleteProductResponse{}
 		body := response.Body.String()
@@ -265,24 +357,48 @@ func TestDeleteProductSuccess(t *testing.T) {
 
 type mockProductRepositoryFailRepo struct{}
 
-func (m mockProductRepositoryFailRepo) GetAll() ([]common.ProductResponse, error) {
+func (m mockProductRepositoryFailRepo) GetAll(ctx context.Context, opts query.Options) ([]entity.Product, query.Page, error) {
+	return nil, query.Page{}, assert.AnError
+}
+
+func (m mockProductRepositoryFailRepo) Get(context.Context, int) (entity.Product, error) {
+	return entity.Product{}, assert.AnError
+}
+
+func (m mockProductRepositoryFailRepo) GetByUser(context.Context, int) ([]entity.Product, error) {
 	return nil, assert.AnError
 }
 
-func (m mockProductRepositoryFailRepo) Get(int) (common.ProductResponse, error) {
-	return common.ProductResponse{}, assert.AnError
+func (m mockProductRepositoryFailRepo) Create(context.Context, entity.Product) (entity.Product, error) {
+	return entity.Product{}, assert.AnError
+}
+
+func (m mockProductRepositoryFailRepo) Update(context.Context, entity.Product) error {
+	return fmt.Errorf("update product failed")
 }
 
-func (m mockProductRepositoryFailRepo) Create(entity.Product) (int, string, error) {
-	return 0, "", assert.AnError
+func (m mockProductRepositoryFailRepo) Patch(ctx context.Context, product entity.Product, fields []string) (entity.Product, error) {
+	return entity.Product{}, assert.AnError
 }
 
-func (m mockProductRepositoryFailRepo) Update(entity.Product) (int, error) {
-	return http.StatusInternalServerError, fmt.Errorf("update product failed")
+func (m mockProductRepositoryFailRepo) Delete(context.Context, int, int, int) error {
+	return fmt.Errorf("delete product failed")
 }
 
-func (m mockProductRepositoryFailRepo) Delete(int, int) (int, error) {
-	return http.StatusInternalServerError, fmt.Errorf("delete product failed")
+func (m mockProductRepositoryFailRepo) DeleteByUser(context.Context, int, int) error {
+	return fmt.Errorf("delete products failed")
+}
+
+func (m mockProductRepositoryFailRepo) Restore(context.Context, int, int) error {
+	return fmt.Errorf("restore product failed")
+}
+
+func (m mockProductRepositoryFailRepo) RestoreByUser(context.Context, int, int) error {
+	return fmt.Errorf("restore product failed")
+}
+
+func (m mockProductRepositoryFailRepo) Purge(context.Context, time.Time) (int64, error) {
+	return 0, assert.AnError
 }
 
 func TestGetAllProductsFailRepo(t *testing.T) {
@@ -292,12 +408,15 @@ func TestGetAllProductsFailRepo(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/products")
 
-		productController := New(mockProductRepositoryFailRepo{})
-		productController.GetAll()(context)
+		productController := New(newService(mockProductRepositoryFailRepo{}))
+		if err := productController.GetAll()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetAllProductsResponse{}
 		body := response.Body.String()
@@ -320,14 +439,17 @@ func TestGetProductFailRepo(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/products/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		productController := New(mockProductRepositoryFailRepo{})
-		productController.Get()(context)
+		productController := New(newService(mockProductRepositoryFailRepo{}))
+		if err := productController.Get()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetProductResponse{}
 		body := response.Body.String()
@@ -345,7 +467,7 @@ func TestGetProductFailRepo(t *testing.T) {
 
 func TestCreateProductFailRepo(t *testing.T) {
 	t.Run("TestCreateProductFailRepo", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleMerchant)
 
 		requestBody, _ := json.Marshal(map[string]interface{}{
 			"name":  "product1",
@@ -360,12 +482,15 @@ func TestCreateProductFailRepo(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/products")
 
-		productController := New(mockProductRepositoryFailRepo{})
-		midware.JWTMiddleware()(productController.Create())(context)
+		productController := New(newService(mockProductRepositoryFailRepo{}))
+		if err := midware.JWTMiddleware()(productController.Create())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.CreateProductResponse{}
 		body := response.Body.String()
@@ -383,7 +508,7 @@ func TestCreateProductFailRepo(t *testing.T) {
 
 func TestUpdateProductFailRepo(t *testing.T) {
 	t.Run("TestUpdateProductFailRepo", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleMerchant)
 
 		requestBody, _ := json.Marshal(map[string]interface{}{
 			"name":  "product1",
@@ -398,14 +523,17 @@ func TestUpdateProductFailRepo(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/products/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		productController := New(mockProductRepositoryFailRepo{})
-		midware.JWTMiddleware()(productController.Update())(context)
+		productController := New(newService(mockProductRepositoryFailRepo{}))
+		if err := midware.JWTMiddleware()(productController.Update())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.UpdateProductResponse{}
 		body := response.Body.String()
@@ -423,7 +551,7 @@ func TestUpdateProductFailRepo(t *testing.T) {
 
 func TestDeleteProductFailRepo(t *testing.T) {
 	t.Run("TestDeleteProductFailRepo", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleMerchant)
 
 		request := httptest.NewRequest(http.MethodDelete, "/", nil)
 		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
@@ -432,14 +560,17 @@ func TestDeleteProductFailRepo(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/products/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		productController := New(mockProductRepositoryFailRepo{})
-		midware.JWTMiddleware()(productController.Delete())(context)
+		productController := New(newService(mockProductRepositoryFailRepo{}))
+		if err := midware.JWTMiddleware()(productController.Delete())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.DeleteProductResponse{}
 		body := response.Body.String()
@@ -459,24 +590,48 @@ func TestDeleteProductFailRepo(t *testing.T) {
 
 type mockProductRepositoryFailOther struct{}
 
-func (m mockProductRepositoryFailOther) GetAll() ([]common.ProductResponse, error) {
-	return []common.ProductResponse{}, nil
+func (m mockProductRepositoryFailOther) GetAll(ctx context.Context, opts query.Options) ([]entity.Product, query.Page, error) {
+	return []entity.Product{}, opts.NewPage(), nil
+}
+
+func (m mockProductRepositoryFailOther) Get(context.Context, int) (entity.Product, error) {
+	return entity.Product{}, domain.NotFound("product does not exist")
+}
+
+func (m mockProductRepositoryFailOther) GetByUser(context.Context, int) ([]entity.Product, error) {
+	return []entity.Product{}, nil
+}
+
+func (m mockProductRepositoryFailOther) Create(context.Context, entity.Product) (entity.Product, error) {
+	return entity.Product{}, nil
+}
+
+func (m mockProductRepositoryFailOther) Update(context.Context, entity.Product) error {
+	return nil
+}
+
+func (m mockProductRepositoryFailOther) Patch(ctx context.Context, product entity.Product, fields []string) (entity.Product, error) {
+	return entity.Product{}, nil
 }
 
-func (m mockProductRepositoryFailOther) Get(int) (common.ProductResponse, error) {
-	return common.ProductResponse{}, nil
+func (m mockProductRepositoryFailOther) Delete(context.Context, int, int, int) error {
+	return nil
 }
 
-func (m mockProductRepositoryFailOther) Create(entity.Product) (int, string, error) {
-	return 0, "", nil
+func (m mockProductRepositoryFailOther) DeleteByUser(context.Context, int, int) error {
+	return nil
 }
 
-func (m mockProductRepositoryFailOther) Update(entity.Product) (int, error) {
-	return http.StatusOK, nil
+func (m mockProductRepositoryFailOther) Restore(context.Context, int, int) error {
+	return domain.NotFound("product does not exist")
 }
 
-func (m mockProductRepositoryFailOther) Delete(int, int) (int, error) {
-	return http.StatusOK, nil
+func (m mockProductRepositoryFailOther) RestoreByUser(context.Context, int, int) error {
+	return nil
+}
+
+func (m mockProductRepositoryFailOther) Purge(context.Context, time.Time) (int64, error) {
+	return 0, nil
 }
 
 func TestGetAllProductsEmptyDirectory(t *testing.T) {
@@ -486,12 +641,15 @@ func TestGetAllProductsEmptyDirectory(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/products")
 
-		productController := New(mockProductRepositoryFailOther{})
-		productController.GetAll()(context)
+		productController := New(newService(mockProductRepositoryFailOther{}))
+		if err := productController.GetAll()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetAllProductsResponse{}
 		body := response.Body.String()
@@ -501,6 +659,16 @@ func TestGetAllProductsEmptyDirectory(t *testing.T) {
 			Code:    http.StatusOK,
 			Message: "products directory empty",
 			Data:    nil,
+			Meta: query.Page{
+				Total: 0,
+				Page:  1,
+				Limit: query.DefaultLimit,
+				Links: query.Links{
+					Self:  "/",
+					First: "/?page=1",
+					Last:  "/?page=1",
+				},
+			},
 		}
 
 		assert.Equal(t, expected, actual)
@@ -514,14 +682,17 @@ func TestGetProductFailInvalidId(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/products/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("invalid id")
 
-		productController := New(mockProductRepositoryFailOther{})
-		productController.Get()(context)
+		productController := New(newService(mockProductRepositoryFailOther{}))
+		if err := productController.Get()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetProductResponse{}
 		body := response.Body.String()
@@ -544,21 +715,24 @@ func TestGetProductDoesNotExist(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/products/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		productController := New(mockProductRepositoryFailOther{})
-		productController.Get()(context)
+		productController := New(newService(mockProductRepositoryFailOther{}))
+		if err := productController.Get()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetProductResponse{}
 		body := response.Body.String()
 		json.Unmarshal([]byte(body), &actual)
 
 		expected := common.GetProductResponse{
-			Code:    http.StatusBadRequest,
+			Code:    http.StatusNotFound,
 			Message: "product does not exist",
 			Data:    nil,
 		}
@@ -569,7 +743,7 @@ func TestGetProductDoesNotExist(t *testing.T) {
 
 func TestCreateProductFailBinding(t *testing.T) {
 	t.Run("TestCreateProductFailBinding", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleMerchant)
 
 		requestBody, _ := json.Marshal(map[string]interface{}{
 			"name":  "product1",
@@ -584,12 +758,15 @@ func TestCreateProductFailBinding(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/products")
 
-		productController := New(mockProductRepositoryFailOther{})
-		midware.JWTMiddleware()(productController.Create())(context)
+		productController := New(newService(mockProductRepositoryFailOther{}))
+		if err := midware.JWTMiddleware()(productController.Create())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.CreateProductResponse{}
 		body := response.Body.String()
@@ -607,7 +784,7 @@ func TestCreateProductFailBinding(t *testing.T) {
 
 func TestUpdateProductFailInvalidId(t *testing.T) {
 	t.Run("TestUpdateProductFailInvalidId", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleMerchant)
 
 		requestBody, _ := json.Marshal(map[string]interface{}{
 			"name":  "product1",
@@ -622,14 +799,17 @@ func TestUpdateProductFailInvalidId(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/products/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("invalid id")
 
-		productController := New(mockProductRepositoryFailRepo{})
-		midware.JWTMiddleware()(productController.Update())(context)
+		productController := New(newService(mockProductRepositoryFailRepo{}))
+		if err := midware.JWTMiddleware()(productController.Update())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.UpdateProductResponse{}
 		body := response.Body.String()
@@ -647,7 +827,7 @@ func TestUpdateProductFailInvalidId(t *testing.T) {
 
 func TestUpdateProductFailBinding(t *testing.T) {
 	t.Run("TestUpdateProductFailInvalidId", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleMerchant)
 
 		requestBody, _ := json.Marshal(map[string]interface{}{
 			"name":  "product1",
@@ -662,14 +842,17 @@ func TestUpdateProductFailBinding(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/products/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		productController := New(mockProductRepositoryFailRepo{})
-		midware.JWTMiddleware()(productController.Update())(context)
+		productController := New(newService(mockProductRepositoryFailRepo{}))
+		if err := midware.JWTMiddleware()(productController.Update())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.UpdateProductResponse{}
 		body := response.Body.String()
@@ -687,7 +870,7 @@ func TestUpdateProductFailBinding(t *testing.T) {
 
 func TestDeleteProductFailInvalidId(t *testing.T) {
 	t.Run("TestDeleteProductFailInvalidId", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleMerchant)
 
 		request := httptest.NewRequest(http.MethodDelete, "/", nil)
 		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
@@ -696,14 +879,17 @@ func TestDeleteProductFailInvalidId(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/products/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("invalid id")
 
-		productController := New(mockProductRepositoryFailOther{})
-		midware.JWTMiddleware()(productController.Delete())(context)
+		productController := New(newService(mockProductRepositoryFailOther{}))
+		if err := midware.JWTMiddleware()(productController.Delete())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.DeleteProductResponse{}
 		body := response.Body.String()
@@ -718,3 +904,642 @@ func TestDeleteProductFailInvalidId(t *testing.T) {
 		assert.Equal(t, expected, actual)
 	})
 }
+
+// TEST AUTHORIZATION
+
+func TestCreateProductFailForbidden(t *testing.T) {
+	t.Run("TestCreateProductFailForbidden", func(t *testing.T) {
+		token, _ := midware.CreateToken(1, "user1", entity.RoleCustomer)
+
+		requestBody, _ := json.Marshal(map[string]interface{}{
+			"name":  "product1",
+			"price": 100,
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/products")
+
+		productController := New(newService(mockProductRepositorySuccess{}))
+		if err := midware.JWTMiddleware()(productController.Create())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.CreateProductResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.CreateProductResponse{
+			Code:    http.StatusForbidden,
+			Message: "forbidden",
+			Data:    nil,
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+// TEST PATCH
+
+// patchProduct serves a PATCH of product 1 with a JSON Patch, by merchant 1.
+func patchProduct(controller *ProductController, body string) *httptest.ResponseRecorder {
+	token, _ := midware.CreateToken(1, "user1", entity.RoleMerchant)
+
+	request := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+	request.Header.Set(echo.HeaderContentType, common.MIMEApplicationJSONPatchJSON)
+	request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+
+	response := httptest.NewRecorder()
+
+	e := echo.New()
+	e.HTTPErrorHandler = common.HTTPErrorHandler
+
+	context := e.NewContext(request, response)
+	context.SetPath("/products/:id")
+	context.SetParamNames("id")
+	context.SetParamValues("1")
+
+	if err := midware.JWTMiddleware()(controller.Patch())(context); err != nil {
+		e.HTTPErrorHandler(err, context)
+	}
+
+	return response
+}
+
+func TestPatchProductSuccess(t *testing.T) {
+	t.Run("TestPatchProductSuccess", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.id = ? AND p.deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(productColumns).AddRow(1, 1, "user1", "product1", 100, 1, stamped, stamped, 1, 1))
+		mock.ExpectQuery("SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.id = ? AND p.deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(productColumns).AddRow(1, 1, "user1", "product1", 100, 1, stamped, stamped, 1, 1))
+		mock.ExpectBegin()
+		mock.ExpectPrepare("SELECT user_id, version FROM products WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "version"}).AddRow(1, 1))
+		mock.ExpectPrepare("UPDATE products SET price = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL").
+			ExpectExec().
+			WithArgs(120, sqlmock.AnyArg(), 1, 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectPrepare("SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.id = ? AND p.deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(productColumns).AddRow(1, 1, "user1", "product1", 120, 2, stamped, stamped, 1, 1))
+		mock.ExpectCommit()
+
+		response := patchProduct(New(newService(productRepo.New(db))), `[{"op":"replace","path":"/price","value":120}]`)
+
+		actual := common.UpdateProductResponse{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		expected := common.UpdateProductResponse{
+			Code:    http.StatusOK,
+			Message: "patch product success",
+			Data: []common.ProductResponse{
+				{
+					Id:        1,
+					Merchant:  "user1",
+					Name:      "product1",
+					Price:     120,
+					CreatedAt: stamped,
+					UpdatedAt: stamped,
+					CreatedBy: &stampedBy,
+					UpdatedBy: &stampedBy,
+				},
+			},
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPatchProductFailNotOwner(t *testing.T) {
+	t.Run("TestPatchProductFailNotOwner", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.id = ? AND p.deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(productColumns).AddRow(1, 2, "user2", "product1", 100, 1, stamped, stamped, 1, 1))
+		mock.ExpectQuery("SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.id = ? AND p.deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(productColumns).AddRow(1, 2, "user2", "product1", 100, 1, stamped, stamped, 1, 1))
+		mock.ExpectBegin()
+		mock.ExpectPrepare("SELECT user_id, version FROM products WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "version"}).AddRow(2, 1))
+		mock.ExpectRollback()
+
+		response := patchProduct(New(newService(productRepo.New(db))), `[{"op":"replace","path":"/price","value":120}]`)
+
+		actual := common.UpdateProductResponse{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		assert.Equal(t, common.UpdateProductResponse{Code: http.StatusForbidden, Message: "user does not match"}, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TEST RESTORE
+
+// restoreProduct serves a restore of product 1 by a user of role.
+func restoreProduct(controller *ProductController, role string) common.UpdateProductResponse {
+	token, _ := midware.CreateToken(1, "user1", role)
+
+	request := httptest.NewRequest(http.MethodPost, "/", nil)
+	request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+
+	response := httptest.NewRecorder()
+
+	e := echo.New()
+	e.HTTPErrorHandler = common.HTTPErrorHandler
+
+	context := e.NewContext(request, response)
+	context.SetPath("/products/:id/restore")
+	context.SetParamNames("id")
+	context.SetParamValues("1")
+
+	if err := midware.JWTMiddleware()(controller.Restore())(context); err != nil {
+		e.HTTPErrorHandler(err, context)
+	}
+
+	actual := common.UpdateProductResponse{}
+	json.Unmarshal(response.Body.Bytes(), &actual)
+
+	return actual
+}
+
+func TestRestoreProduct(t *testing.T) {
+	t.Run("TestRestoreProduct", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("UPDATE products p JOIN users u ON p.user_id = u.id SET p.deleted_at = NULL, p.updated_at = ?, p.updated_by = ?, p.version = p.version + 1 WHERE p.id = ? AND p.deleted_at IS NOT NULL AND u.deleted_at IS NULL").
+			ExpectExec().
+			WithArgs(sqlmock.AnyArg(), 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectPrepare("SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.id = ? AND p.deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(productColumns).AddRow(1, 1, "user1", "product1", 100, 3, stamped, stamped, 1, 1))
+
+		actual := restoreProduct(New(newService(productRepo.New(db))), entity.RoleAdmin)
+
+		expected := common.UpdateProductResponse{
+			Code:    http.StatusOK,
+			Message: "restore product success",
+			Data:    []common.ProductResponse{{Id: 1, Merchant: "user1", Name: "product1", Price: 100, CreatedAt: stamped, UpdatedAt: stamped, CreatedBy: &stampedBy, UpdatedBy: &stampedBy}},
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRestoreProductFail(t *testing.T) {
+	cases := []struct {
+		name                   string
+		deletedAt, userDeleted interface{}
+		code                   int
+		message                string
+	}{
+		{"TestRestoreProductFailNotDeleted", nil, nil, http.StatusConflict, "product is not deleted"},
+		{"TestRestoreProductFailUserDeleted", time.Now(), time.Now(), http.StatusConflict, "user of the product is deleted"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			defer db.Close()
+
+			mock.ExpectPrepare("UPDATE products p JOIN users u ON p.user_id = u.id SET p.deleted_at = NULL, p.updated_at = ?, p.updated_by = ?, p.version = p.version + 1 WHERE p.id = ? AND p.deleted_at IS NOT NULL AND u.deleted_at IS NULL").
+				ExpectExec().
+				WithArgs(sqlmock.AnyArg(), 1, 1).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectPrepare("SELECT p.deleted_at, u.deleted_at FROM products p JOIN users u ON p.user_id = u.id WHERE p.id = ?").
+				ExpectQuery().
+				WithArgs(1).
+				WillReturnRows(sqlmock.NewRows([]string{"deleted_at", "deleted_at"}).AddRow(tc.deletedAt, tc.userDeleted))
+
+			actual := restoreProduct(New(newService(productRepo.New(db))), entity.RoleAdmin)
+
+			assert.Equal(t, common.UpdateProductResponse{Code: tc.code, Message: tc.message}, actual)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("TestRestoreProductFailNotExist", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("UPDATE products p JOIN users u ON p.user_id = u.id SET p.deleted_at = NULL, p.updated_at = ?, p.updated_by = ?, p.version = p.version + 1 WHERE p.id = ? AND p.deleted_at IS NOT NULL AND u.deleted_at IS NULL").
+			ExpectExec().
+			WithArgs(sqlmock.AnyArg(), 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare("SELECT p.deleted_at, u.deleted_at FROM products p JOIN users u ON p.user_id = u.id WHERE p.id = ?").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"deleted_at", "deleted_at"}))
+
+		actual := restoreProduct(New(newService(productRepo.New(db))), entity.RoleAdmin)
+
+		assert.Equal(t, common.UpdateProductResponse{Code: http.StatusNotFound, Message: "product does not exist"}, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("TestRestoreProductFailForbidden", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		actual := restoreProduct(New(newService(productRepo.New(db))), entity.RoleMerchant)
+
+		assert.Equal(t, common.UpdateProductResponse{Code: http.StatusForbidden, Message: "forbidden"}, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TEST DOMAIN ERRORS
+
+func serveProduct(handler echo.HandlerFunc, method string, id string) common.UpdateProductResponse {
+	token, _ := midware.CreateToken(1, "user1", entity.RoleMerchant)
+
+	requestBody, _ := json.Marshal(map[string]interface{}{
+		"name":  "product1",
+		"price": 100,
+	})
+
+	request := httptest.NewRequest(method, "/", bytes.NewBuffer(requestBody))
+	request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+	request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	response := httptest.NewRecorder()
+
+	e := echo.New()
+	e.HTTPErrorHandler = common.HTTPErrorHandler
+
+	context := e.NewContext(request, response)
+	context.SetPath("/products/:id")
+	context.SetParamNames("id")
+	context.SetParamValues(id)
+
+	if err := midware.JWTMiddleware()(handler)(context); err != nil {
+		e.HTTPErrorHandler(err, context)
+	}
+
+	actual := common.UpdateProductResponse{}
+	json.Unmarshal(response.Body.Bytes(), &actual)
+
+	return actual
+}
+
+func TestUpdateProductNotOwner(t *testing.T) {
+	t.Run("TestUpdateProductNotOwner", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.id = ? AND p.deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(productColumns).AddRow(1, 2, "user2", "product1", 100, 1, stamped, stamped, 1, 1))
+		mock.ExpectQuery("SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.id = ? AND p.deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(productColumns).AddRow(1, 2, "user2", "product1", 100, 1, stamped, stamped, 1, 1))
+		mock.ExpectPrepare("UPDATE products SET name = ?, price = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND user_id = ? AND version = ? AND deleted_at IS NULL").
+			ExpectExec().
+			WithArgs("product1", 100, sqlmock.AnyArg(), 1, 1, 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare("SELECT user_id, version FROM products WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "version"}).AddRow(2, 1))
+
+		actual := serveProduct(New(newService(productRepo.New(db))).Update(), http.MethodPut, "1")
+
+		expected := common.UpdateProductResponse{
+			Code:    http.StatusForbidden,
+			Message: "user does not match",
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteProductNotFound(t *testing.T) {
+	t.Run("TestDeleteProductNotFound", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.id = ? AND p.deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows(productColumns))
+
+		actual := serveProduct(New(newService(productRepo.New(db))).Delete(), http.MethodDelete, "7")
+
+		expected := common.UpdateProductResponse{
+			Code:    http.StatusNotFound,
+			Message: "product does not exist",
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCreateProductUnknownUser(t *testing.T) {
+	t.Run("TestCreateProductUnknownUser", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectPrepare("INSERT INTO products (user_id, name, price, created_at, updated_at, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?)")
+		mock.ExpectPrepare("SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.id = ? AND p.deleted_at IS NULL")
+		mock.ExpectExec("INSERT INTO products (user_id, name, price, created_at, updated_at, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?)").
+			WithArgs(1, "product1", 100, sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 1).
+			WillReturnError(&mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails"})
+		mock.ExpectRollback()
+
+		actual := serveProduct(New(newService(productRepo.New(db))).Create(), http.MethodPost, "")
+
+		expected := common.UpdateProductResponse{
+			Code:    http.StatusUnprocessableEntity,
+			Message: "user does not exist",
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TEST SQL INJECTION
+
+const injection = "x', price=0 WHERE 1=1; -- "
+
+func TestCreateProductInjection(t *testing.T) {
+	t.Run("TestCreateProductInjection", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectPrepare("INSERT INTO products (user_id, name, price, created_at, updated_at, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?)")
+		mock.ExpectPrepare("SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.id = ? AND p.deleted_at IS NULL")
+		mock.ExpectExec("INSERT INTO products (user_id, name, price, created_at, updated_at, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?)").
+			WithArgs(1, injection, 100, sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.id = ? AND p.deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(productColumns).AddRow(1, 1, "user1", injection, 100, 1, stamped, stamped, 1, 1))
+		mock.ExpectCommit()
+
+		token, _ := midware.CreateToken(1, "admin", entity.RoleMerchant)
+
+		requestBody, _ := json.Marshal(map[string]interface{}{
+			"name":  injection,
+			"price": 100,
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/products")
+
+		productController := New(newService(productRepo.New(db)))
+		if err := midware.JWTMiddleware()(productController.Create())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.CreateProductResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.CreateProductResponse{
+			Code:    http.StatusOK,
+			Message: "create product success",
+			Data: []common.ProductResponse{
+				{
+					Id:        1,
+					Merchant:  "user1",
+					Name:      injection,
+					Price:     100,
+					CreatedAt: stamped,
+					UpdatedAt: stamped,
+					CreatedBy: &stampedBy,
+					UpdatedBy: &stampedBy,
+				},
+			},
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUpdateProductInjection(t *testing.T) {
+	t.Run("TestUpdateProductInjection", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.id = ? AND p.deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(productColumns).AddRow(1, 1, "user1", "product1", 100, 1, stamped, stamped, 1, 1))
+		mock.ExpectQuery("SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.id = ? AND p.deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(productColumns).AddRow(1, 1, "user1", "product1", 100, 1, stamped, stamped, 1, 1))
+		mock.ExpectPrepare("UPDATE products SET name = ?, price = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND user_id = ? AND version = ? AND deleted_at IS NULL").
+			ExpectExec().
+			WithArgs(injection, 100, sqlmock.AnyArg(), 1, 1, 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.id = ? AND p.deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(productColumns).AddRow(1, 1, "user1", injection, 100, 2, stamped, stamped, 1, 1))
+
+		token, _ := midware.CreateToken(1, "admin", entity.RoleMerchant)
+
+		requestBody, _ := json.Marshal(map[string]interface{}{
+			"name":  injection,
+			"price": 100,
+		})
+
+		request := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/products/:id")
+		context.SetParamNames("id")
+		context.SetParamValues("1")
+
+		productController := New(newService(productRepo.New(db)))
+		if err := midware.JWTMiddleware()(productController.Update())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.UpdateProductResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.UpdateProductResponse{
+			Code:    http.StatusOK,
+			Message: "update product success",
+			Data: []common.ProductResponse{
+				{
+					Id:        1,
+					Merchant:  "user1",
+					Name:      injection,
+					Price:     100,
+					CreatedAt: stamped,
+					UpdatedAt: stamped,
+					CreatedBy: &stampedBy,
+					UpdatedBy: &stampedBy,
+				},
+			},
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TEST PAGINATION
+
+func TestGetAllProductsPaginated(t *testing.T) {
+	t.Run("TestGetAllProductsPaginated", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT COUNT(*) FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.deleted_at IS NULL AND u.name = ? AND p.price <= ?").
+			WithArgs("user1", 500).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery("SELECT p.id, p.user_id, u.name, p.name, p.price, p.deleted_at, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.deleted_at IS NULL AND u.name = ? AND p.price <= ? ORDER BY p.price DESC, p.id DESC LIMIT ?").
+			WithArgs("user1", 500, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "merchant", "name", "price", "deleted_at", "created_at", "updated_at", "created_by", "updated_by"}).
+				AddRow(3, 1, "user1", "product3", 300, nil, stamped, stamped, 1, 1).
+				AddRow(1, 1, "user1", "product1", 200, nil, stamped, stamped, 1, 1).
+				AddRow(2, 1, "user1", "product2", 100, nil, stamped, stamped, 1, 1))
+
+		request := httptest.NewRequest(http.MethodGet, "/products?merchant=user1&max_price=500&sort=-price&limit=2", nil)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/products")
+
+		productController := New(newService(productRepo.New(db)))
+		if err := productController.GetAll()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.GetAllProductsResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		assert.Equal(t, http.StatusOK, actual.Code)
+		assert.Equal(t, []common.ProductResponse{
+			{Id: 3, Merchant: "user1", Name: "product3", Price: 300, CreatedAt: stamped, UpdatedAt: stamped, CreatedBy: &stampedBy, UpdatedBy: &stampedBy},
+			{Id: 1, Merchant: "user1", Name: "product1", Price: 200, CreatedAt: stamped, UpdatedAt: stamped, CreatedBy: &stampedBy, UpdatedBy: &stampedBy},
+		}, actual.Data)
+		assert.Equal(t, 3, actual.Meta.Total)
+		assert.Equal(t, 1, actual.Meta.Page)
+		assert.Equal(t, 2, actual.Meta.Limit)
+		assert.NotEmpty(t, actual.Meta.NextCursor)
+		assert.Equal(t, "/products?limit=2&max_price=500&merchant=user1&page=2&sort=-price", actual.Meta.Links.Next)
+		assert.Nil(t, mock.ExpectationsWereMet())
+
+		mock.ExpectQuery("SELECT COUNT(*) FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.deleted_at IS NULL AND u.name = ? AND p.price <= ?").
+			WithArgs("user1", 500).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery("SELECT p.id, p.user_id, u.name, p.name, p.price, p.deleted_at, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.deleted_at IS NULL AND u.name = ? AND p.price <= ? AND (p.price < ? OR (p.price = ? AND p.id < ?)) ORDER BY p.price DESC, p.id DESC LIMIT ?").
+			WithArgs("user1", 500, "200", "200", 1, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "merchant", "name", "price", "deleted_at", "created_at", "updated_at", "created_by", "updated_by"}).
+				AddRow(2, 1, "user1", "product2", 100, nil, stamped, stamped, 1, 1))
+
+		request = httptest.NewRequest(http.MethodGet, "/products?merchant=user1&max_price=500&sort=-price&limit=2&cursor="+actual.Meta.NextCursor, nil)
+
+		response = httptest.NewRecorder()
+
+		context = e.NewContext(request, response)
+		context.SetPath("/products")
+
+		if err := productController.GetAll()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual = common.GetAllProductsResponse{}
+		body = response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		assert.Equal(t, []common.ProductResponse{
+			{Id: 2, Merchant: "user1", Name: "product2", Price: 100, CreatedAt: stamped, UpdatedAt: stamped, CreatedBy: &stampedBy, UpdatedBy: &stampedBy},
+		}, actual.Data)
+		assert.Equal(t, 0, actual.Meta.Page)
+		assert.Empty(t, actual.Meta.NextCursor)
+		assert.Empty(t, actual.Meta.Links.Next)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetAllProductsFailQuery(t *testing.T) {
+	t.Run("TestGetAllProductsFailQuery", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/products?sort=user_id", nil)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/products")
+
+		productController := New(newService(mockProductRepositorySuccess{}))
+		if err := productController.GetAll()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.GetAllProductsResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.GetAllProductsResponse{
+			Code:    http.StatusBadRequest,
+			Message: "invalid sort field",
+			Data:    nil,
+			Meta:    query.Page{},
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
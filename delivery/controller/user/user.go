@@ -5,19 +5,20 @@ import (
 	"rest-api/design-pattern/delivery/common"
 	"rest-api/design-pattern/delivery/midware"
 	"rest-api/design-pattern/entity"
-	userRepo "rest-api/design-pattern/repository/user"
+	userService "rest-api/design-pattern/service/user"
+	"rest-api/design-pattern/util/query"
 	"strconv"
 
 	"github.com/labstack/echo/v4"
 )
 
 type UserController struct {
-	repository userRepo.User
+	service userService.User
 }
 
-func New(user userRepo.User) *UserController {
+func New(user userService.User) *UserController {
 	return &UserController{
-		repository: user,
+		service: user,
 	}
 }
 
@@ -25,23 +26,33 @@ func (uc UserController) GetAll() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		code := http.StatusOK
 
-		if valid := midware.ValidateToken(c); !valid {
+		actor, err := midware.ExtractActor(c)
+
+		if err != nil {
 			code = http.StatusUnauthorized
-			return c.JSON(code, common.SimpleResponse(code, "unauthorized", nil))
+			return common.Fail(c, code, "unauthorized")
+		}
+
+		opts, err := query.Parse(c.QueryParams(), userService.ListSpec)
+
+		if err != nil {
+			code = http.StatusBadRequest
+			return common.Fail(c, code, err.Error())
 		}
 
-		users, err := uc.repository.GetAll()
+		users, page, err := uc.service.GetAll(c.Request().Context(), actor, opts)
 
 		if err != nil {
-			code = http.StatusInternalServerError
-			return c.JSON(code, common.SimpleResponse(code, "get all users failed", nil))
+			return common.Error(err, "get all users failed")
 		}
 
+		page.SetLinks(c.Request().URL)
+
 		if len(users) == 0 {
-			return c.JSON(code, common.SimpleResponse(code, "users directory empty", nil))
+			return c.JSON(code, common.PagedResponse(code, "users directory empty", nil, page))
 		}
 
-		return c.JSON(code, common.SimpleResponse(code, "get all users success", users))
+		return c.JSON(code, common.PagedResponse(code, "get all users success", common.NewUserResponses(users), page))
 	}
 }
 
@@ -51,29 +62,29 @@ func (uc UserController) Get() echo.HandlerFunc {
 
 		if valid := midware.ValidateToken(c); !valid {
 			code = http.StatusUnauthorized
-			return c.JSON(code, common.SimpleResponse(code, "unauthorized", nil))
+			return common.Fail(c, code, "unauthorized")
 		}
 
 		id, err := strconv.Atoi(c.Param("id"))
 
 		if err != nil {
 			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "invalid user id", nil))
+			return common.Fail(c, code, "invalid user id")
 		}
 
-		user, err := uc.repository.Get(id)
+		user, err := uc.service.Get(c.Request().Context(), id)
 
 		if err != nil {
-			code = http.StatusInternalServerError
-			return c.JSON(code, common.SimpleResponse(code, "get user failed", nil))
+			return common.Error(err, "get user failed")
 		}
 
-		if user == (common.UserResponse{}) {
-			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "user does not exist", nil))
+		common.SetETag(c, user.Version)
+
+		if common.NotModified(c, user.Version) {
+			return c.NoContent(http.StatusNotModified)
 		}
 
-		return c.JSON(code, common.SimpleResponse(code, "get user success", []common.UserResponse{user}))
+		return c.JSON(code, common.SimpleResponse(code, "get user success", []common.UserResponse{common.NewUserResponse(user)}))
 	}
 }
 
@@ -84,19 +95,18 @@ func (uc UserController) Create() echo.HandlerFunc {
 
 		if err := c.Bind(&user); err != nil {
 			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "binding failed", nil))
+			return common.Fail(c, code, "binding failed")
 		}
 
-		id, err := uc.repository.Create(user)
+		created, err := uc.service.Register(c.Request().Context(), user)
 
 		if err != nil {
-			code = http.StatusInternalServerError
-			return c.JSON(code, common.SimpleResponse(code, "create user failed", nil))
+			return common.Error(err, "create user failed")
 		}
 
-		user.Id = id
+		common.SetETag(c, created.Version)
 
-		return c.JSON(code, common.SimpleResponse(code, "create user success", []entity.User{user}))
+		return c.JSON(code, common.SimpleResponse(code, "create user success", []common.UserResponse{common.NewUserResponse(created)}))
 	}
 }
 
@@ -104,32 +114,103 @@ func (uc UserController) Update() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		code := http.StatusOK
 
-		if valid := midware.ValidateToken(c); !valid {
+		actor, err := midware.ExtractActor(c)
+
+		if err != nil {
 			code = http.StatusUnauthorized
-			return c.JSON(code, common.SimpleResponse(code, "unauthorized", nil))
+			return common.Fail(c, code, "unauthorized")
 		}
 
 		id, err := strconv.Atoi(c.Param("id"))
 
 		if err != nil {
 			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "invalid user id", nil))
+			return common.Fail(c, code, "invalid user id")
 		}
 
 		user := entity.User{}
 
 		if err := c.Bind(&user); err != nil {
 			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "binding failed", nil))
+			return common.Fail(c, code, "binding failed")
+		}
+
+		current, err := uc.service.Get(c.Request().Context(), id)
+
+		if err != nil {
+			return common.Error(err, "update user failed")
+		}
+
+		user.Id = id
+
+		if user.Version, err = common.IfMatch(c, current.Version); err != nil {
+			return err
+		}
+
+		user, err = uc.service.Update(c.Request().Context(), actor, user)
+
+		if err != nil {
+			return common.Error(err, "update user failed")
+		}
+
+		common.SetETag(c, user.Version)
+
+		return c.JSON(code, common.SimpleResponse(code, "update user success", []common.UserResponse{common.NewUserResponse(user)}))
+	}
+}
+
+// Patch is Update for the fields the patch in the body changes alone, so that
+// a password left out is kept.
+func (uc UserController) Patch() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		code := http.StatusOK
+
+		actor, err := midware.ExtractActor(c)
+
+		if err != nil {
+			code = http.StatusUnauthorized
+			return common.Fail(c, code, "unauthorized")
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+
+		if err != nil {
+			code = http.StatusBadRequest
+			return common.Fail(c, code, "invalid user id")
+		}
+
+		current, err := uc.service.Get(c.Request().Context(), id)
+
+		if err != nil {
+			return common.Error(err, "patch user failed")
+		}
+
+		version, err := common.IfMatch(c, current.Version)
+
+		if err != nil {
+			return err
+		}
+
+		user := entity.User{}
+
+		fields, err := common.BindPatch(c, common.NewUserResponse(current), &user)
+
+		if err != nil {
+			return err
 		}
 
 		user.Id = id
+		user.Version = version
+
+		user, err = uc.service.Patch(c.Request().Context(), actor, user, fields)
 
-		if code, err := uc.repository.Update(user); err != nil {
-			return c.JSON(code, common.SimpleResponse(code, err.Error(), nil))
+		if err != nil {
+			return common.Error(err, "patch user failed")
 		}
 
-		return c.JSON(code, common.SimpleResponse(code, "update user success", []entity.User{user}))
+		common.SetETag(c, user.Version)
+
+		return c.JSON(code, common.SimpleResponse(code, "patch user success", []common.UserResponse{common.NewUserResponse(user)}))
 	}
 }
 
@@ -137,22 +218,99 @@ func (uc UserController) Delete() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		code := http.StatusOK
 
-		if valid := midware.ValidateToken(c); !valid {
+		actor, err := midware.ExtractActor(c)
+
+		if err != nil {
 			code = http.StatusUnauthorized
-			return c.JSON(code, common.SimpleResponse(code, "unauthorized", nil))
+			return common.Fail(c, code, "unauthorized")
 		}
 
 		id, err := strconv.Atoi(c.Param("id"))
 
 		if err != nil {
 			code = http.StatusBadRequest
-			return c.JSON(code, common.SimpleResponse(code, "invalid user id", nil))
+			return common.Fail(c, code, "invalid user id")
 		}
 
-		if code, err := uc.repository.Delete(id); err != nil {
-			return c.JSON(code, common.SimpleResponse(code, err.Error(), nil))
+		current, err := uc.service.Get(c.Request().Context(), id)
+
+		if err != nil {
+			return common.Error(err, "delete user failed")
+		}
+
+		version, err := common.IfMatch(c, current.Version)
+
+		if err != nil {
+			return err
+		}
+
+		if err := uc.service.Delete(c.Request().Context(), actor, id, version); err != nil {
+			return common.Error(err, "delete user failed")
 		}
 
 		return c.JSON(code, common.SimpleResponse(code, "delete user success", nil))
 	}
 }
+
+func (uc UserController) Restore() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		code := http.StatusOK
+
+		actor, err := midware.ExtractActor(c)
+
+		if err != nil {
+			code = http.StatusUnauthorized
+			return common.Fail(c, code, "unauthorized")
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+
+		if err != nil {
+			code = http.StatusBadRequest
+			return common.Fail(c, code, "invalid user id")
+		}
+
+		user, err := uc.service.Restore(c.Request().Context(), actor, id)
+
+		if err != nil {
+			return common.Error(err, "restore user failed")
+		}
+
+		common.SetETag(c, user.Version)
+
+		return c.JSON(code, common.SimpleResponse(code, "restore user success", []common.UserResponse{common.NewUserResponse(user)}))
+	}
+}
+
+func (uc UserController) SetRole() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		code := http.StatusOK
+
+		actor, err := midware.ExtractActor(c)
+
+		if err != nil {
+			code = http.StatusUnauthorized
+			return common.Fail(c, code, "unauthorized")
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+
+		if err != nil {
+			code = http.StatusBadRequest
+			return common.Fail(c, code, "invalid user id")
+		}
+
+		user := entity.User{}
+
+		if err := c.Bind(&user); err != nil {
+			code = http.StatusBadRequest
+			return common.Fail(c, code, "binding failed")
+		}
+
+		if err := uc.service.SetRole(c.Request().Context(), actor, id, user.Role); err != nil {
+			return common.Error(err, "set user role failed")
+		}
+
+		return c.JSON(code, common.SimpleResponse(code, "set user role success", []common.UserRoleResponse{{Id: id, Role: user.Role}}))
+	}
+}
@@ -2,25 +2,166 @@ package user
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"rest-api/design-pattern/delivery/common"
 	"rest-api/design-pattern/delivery/midware"
+	"rest-api/design-pattern/domain"
 	"rest-api/design-pattern/entity"
+	auditRepo "rest-api/design-pattern/repository/audit"
+	authRepo "rest-api/design-pattern/repository/auth"
+	bookRepo "rest-api/design-pattern/repository/book"
+	productRepo "rest-api/design-pattern/repository/product"
+	"rest-api/design-pattern/repository/transaction"
+	userRepo "rest-api/design-pattern/repository/user"
+	userService "rest-api/design-pattern/service/user"
+	"rest-api/design-pattern/util/password"
+	"rest-api/design-pattern/util/query"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// mockTransactions runs the function on the mocked repositories, without a
+// transaction.
+type mockTransactions struct {
+	users userRepo.User
+}
+
+func (m mockTransactions) Do(ctx context.Context, fn func(transaction.Repositories) error) error {
+	return fn(transaction.Repositories{Users: m.users, Products: mockProductRepository{}, Audits: mockAuditRepository{}, Auth: mockAuthRepository{}})
+}
+
+// mockAuthRepository revokes no tokens.
+type mockAuthRepository struct {
+	authRepo.Auth
+}
+
+func (m mockAuthRepository) RevokeUserRefresh(context.Context, int) error {
+	return nil
+}
+
+// mockAuditRepository discards the records it is asked to store.
+type mockAuditRepository struct {
+	auditRepo.Audit
+}
+
+func (m mockAuditRepository) Create(context.Context, entity.Audit) error {
+	return nil
+}
+
+type mockProductRepository struct{}
+
+func (m mockProductRepository) GetAll(context.Context, query.Options) ([]entity.Product, query.Page, error) {
+	return nil, query.Page{}, nil
+}
+
+func (m mockProductRepository) Get(context.Context, int) (entity.Product, error) {
+	return entity.Product{}, nil
+}
+
+func (m mockProductRepository) GetByUser(context.Context, int) ([]entity.Product, error) {
+	return []entity.Product{}, nil
+}
+
+func (m mockProductRepository) Create(context.Context, entity.Product) (entity.Product, error) {
+	return entity.Product{}, nil
+}
+
+func (m mockProductRepository) Update(context.Context, entity.Product) error {
+	return nil
+}
+
+func (m mockProductRepository) Patch(ctx context.Context, product entity.Product, fields []string) (entity.Product, error) {
+	return entity.Product{}, nil
+}
+
+func (m mockProductRepository) Delete(context.Context, int, int, int) error {
+	return nil
+}
+
+func (m mockProductRepository) DeleteByUser(context.Context, int, int) error {
+	return nil
+}
+
+func (m mockProductRepository) Restore(context.Context, int, int) error {
+	return nil
+}
+
+func (m mockProductRepository) RestoreByUser(context.Context, int, int) error {
+	return nil
+}
+
+func (m mockProductRepository) Purge(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+var userColumns = []string{"id", "name", "email", "role", "version", "created_at", "updated_at", "created_by", "updated_by"}
+
+// stamped and stampedBy are when and by whom the rows read back in tests
+// were created and last updated.
+var (
+	stamped   = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	stampedBy = 1
+)
+
+// expectGetUser expects user 1, named name, to be read outside a transaction.
+func expectGetUser(mock sqlmock.Sqlmock, name string) {
+	mock.ExpectPrepare("SELECT id, name, email, role, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ? AND deleted_at IS NULL").
+		ExpectQuery().
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, name, "user1@mail.com", entity.RoleCustomer, 1, stamped, stamped, 1, 1))
+}
+
+// newController returns a controller on the real repositories over db.
+func newController(db *sql.DB) *UserController {
+	users := userRepo.New(db, password.NewBcrypt(bcrypt.MinCost))
+
+	return New(userService.New(users, transaction.New(db, bookRepo.New(db, "mysql"), productRepo.New(db), users, auditRepo.New(db), authRepo.New(db))))
+}
+
+const queryAudit = "INSERT INTO audit (actor_id, action, resource, resource_id, before_snapshot, after_snapshot, request_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+
+// expectAudit expects the record of action by actor, nil when anonymous, on
+// the resource with id to be stored with the statement prepared before.
+func expectAudit(mock sqlmock.Sqlmock, actor interface{}, action string, resource string, id int) *sqlmock.ExpectedExec {
+	return mock.ExpectExec(queryAudit).
+		WithArgs(actor, action, resource, id, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+// hashOf matches a bcrypt hash of plain.
+type hashOf struct {
+	plain string
+}
+
+func (h hashOf) Match(v driver.Value) bool {
+	hash, ok := v.(string)
+
+	return ok && bcrypt.CompareHashAndPassword([]byte(hash), []byte(h.plain)) == nil
+}
+
 // TEST SUCCESS
 
 type mockUserRepositorySuccess struct{}
 
-func (m mockUserRepositorySuccess) GetAll() ([]common.UserResponse, error) {
-	return []common.UserResponse{
+func (m mockUserRepositorySuccess) GetAll(ctx context.Context, opts query.Options) ([]entity.User, query.Page, error) {
+	page := opts.NewPage()
+	page.Total = 2
+
+	return []entity.User{
 		{
 			Id:    1,
 			Name:  "user1",
@@ -31,32 +172,53 @@ func (m mockUserRepositorySuccess) GetAll() ([]common.UserResponse, error) {
 			Name:  "user2",
 			Email: "email2",
 		},
-	}, nil
+	}, page, nil
 }
 
-func (m mockUserRepositorySuccess) Get(int) (common.UserResponse, error) {
-	return common.UserResponse{
+func (m mockUserRepositorySuccess) Get(context.Context, int) (entity.User, error) {
+	return entity.User{
 		Id:    1,
 		Name:  "user",
-		Email: "email",
+		Email: "user1@mail.com",
+	}, nil
+}
+
+func (m mockUserRepositorySuccess) Create(ctx context.Context, user entity.User) (entity.User, error) {
+	return entity.User{
+		Id:    1,
+		Name:  user.Name,
+		Email: user.Email,
+		Role:  user.Role,
 	}, nil
 }
 
-func (m mockUserRepositorySuccess) Create(entity.User) (int, error) {
-	return 1, nil
+func (m mockUserRepositorySuccess) Update(context.Context, entity.User) error {
+	return nil
+}
+
+func (m mockUserRepositorySuccess) Patch(ctx context.Context, user entity.User, fields []string) (entity.User, error) {
+	return m.Get(ctx, user.Id)
+}
+
+func (m mockUserRepositorySuccess) Delete(context.Context, int, int, int) error {
+	return nil
+}
+
+func (m mockUserRepositorySuccess) Restore(context.Context, int, int) error {
+	return nil
 }
 
-func (m mockUserRepositorySuccess) Update(entity.User) (int, error) {
-	return http.StatusOK, nil
+func (m mockUserRepositorySuccess) Purge(context.Context, time.Time) (int64, error) {
+	return 0, nil
 }
 
-func (m mockUserRepositorySuccess) Delete(int) (int, error) {
-	return http.StatusOK, nil
+func (m mockUserRepositorySuccess) SetRole(context.Context, int, string, int) error {
+	return nil
 }
 
 func TestGetAllUsersSuccess(t *testing.T) {
 	t.Run("TestGetAllUsersSuccess", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleCustomer)
 
 		request := httptest.NewRequest(http.MethodGet, "/", nil)
 		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
@@ -65,12 +227,15 @@ func TestGetAllUsersSuccess(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/users")
 
-		userController := New(mockUserRepositorySuccess{})
-		midware.JWTMiddleware()(userController.GetAll())(context)
+		userController := New(userService.New(mockUserRepositorySuccess{}, mockTransactions{users: mockUserRepositorySuccess{}}))
+		if err := midware.JWTMiddleware()(userController.GetAll())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetAllUsersResponse{}
 		body := response.Body.String()
@@ -91,6 +256,16 @@ func TestGetAllUsersSuccess(t *testing.T) {
 					Email: "email2",
 				},
 			},
+			Meta: query.Page{
+				Total: 2,
+				Page:  1,
+				Limit: query.DefaultLimit,
+				Links: query.Links{
+					Self:  "/",
+					First: "/?page=1",
+					Last:  "/?page=1",
+				},
+			},
 		}
 
 		assert.Equal(t, expected, actual)
@@ -99,7 +274,7 @@ func TestGetAllUsersSuccess(t *testing.T) {
 
 func TestGetUserSuccess(t *testing.T) {
 	t.Run("TestGetUserSuccess", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleCustomer)
 
 		request := httptest.NewRequest(http.MethodGet, "/", nil)
 		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
@@ -108,14 +283,17 @@ func TestGetUserSuccess(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/users/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		userController := New(mockUserRepositorySuccess{})
-		midware.JWTMiddleware()(userController.Get())(context)
+		userController := New(userService.New(mockUserRepositorySuccess{}, mockTransactions{users: mockUserRepositorySuccess{}}))
+		if err := midware.JWTMiddleware()(userController.Get())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetUserResponse{}
 		body := response.Body.String()
@@ -128,7 +306,7 @@ func TestGetUserSuccess(t *testing.T) {
 				{
 					Id:    1,
 					Name:  "user",
-					Email: "email",
+					Email: "user1@mail.com",
 				},
 			},
 		}
@@ -141,8 +319,8 @@ func TestCreateUserSuccess(t *testing.T) {
 	t.Run("TestCreateUserSuccess", func(t *testing.T) {
 		requestBody, _ := json.Marshal(map[string]string{
 			"name":     "user",
-			"email":    "email",
-			"password": "password",
+			"email":    "user1@mail.com",
+			"password": "Passw0rd",
 		})
 
 		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
@@ -151,12 +329,15 @@ func TestCreateUserSuccess(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/users")
 
-		userController := New(mockUserRepositorySuccess{})
-		userController.Create()(context)
+		userController := New(userService.New(mockUserRepositorySuccess{}, mockTransactions{users: mockUserRepositorySuccess{}}))
+		if err := userController.Create()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.CreateUserResponse{}
 		body := response.Body.String()
@@ -165,12 +346,12 @@ func TestCreateUserSuccess(t *testing.T) {
 		expected := common.CreateUserResponse{
 			Code:    http.StatusOK,
 			Message: "create user success",
-			Data: []entity.User{
+			Data: []common.UserResponse{
 				{
-					Id:       1,
-					Name:     "user",
-					Email:    "email",
-					Password: "password",
+					Id:    1,
+					Name:  "user",
+					Email: "user1@mail.com",
+					Role:  entity.RoleCustomer,
 				},
 			},
 		}
@@ -181,12 +362,12 @@ func TestCreateUserSuccess(t *testing.T) {
 
 func TestUpdateUserSuccess(t *testing.T) {
 	t.Run("TestUpdateUserSuccess", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleCustomer)
 
 		requestBody, _ := json.Marshal(map[string]string{
 			"name":     "user",
-			"email":    "email",
-			"password": "password",
+			"email":    "user1@mail.com",
+			"password": "Passw0rd",
 		})
 
 		request := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(requestBody))
@@ -197,14 +378,17 @@ func TestUpdateUserSuccess(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/users/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		userController := New(mockUserRepositorySuccess{})
-		midware.JWTMiddleware()(userController.Update())(context)
+		userController := New(userService.New(mockUserRepositorySuccess{}, mockTransactions{users: mockUserRepositorySuccess{}}))
+		if err := midware.JWTMiddleware()(userController.Update())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.UpdateUserResponse{}
 		body := response.Body.String()
@@ -213,12 +397,11 @@ func TestUpdateUserSuccess(t *testing.T) {
 		expected := common.UpdateUserResponse{
 			Code:    http.StatusOK,
 			Message: "update user success",
-			Data: []entity.User{
+			Data: []common.UserResponse{
 				{
-					Id:       1,
-					Name:     "user",
-					Email:    "email",
-					Password: "password",
+					Id:    1,
+					Name:  "user",
+					Email: "user1@mail.com",
 				},
 			},
 		}
@@ -229,7 +412,7 @@ func TestUpdateUserSuccess(t *testing.T) {
 
 func TestDeleteUserSuccess(t *testing.T) {
 	t.Run("TestDeleteUserSuccess", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleCustomer)
 
 		request := httptest.NewRequest(http.MethodDelete, "/", nil)
 		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
@@ -238,14 +421,17 @@ func TestDeleteUserSuccess(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/users/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		userController := New(mockUserRepositorySuccess{})
-		midware.JWTMiddleware()(userController.Delete())(context)
+		userController := New(userService.New(mockUserRepositorySuccess{}, mockTransactions{users: mockUserRepositorySuccess{}}))
+		if err := midware.JWTMiddleware()(userController.Delete())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.DeleteUserResponse{}
 		body := response.Body.String()
@@ -265,29 +451,45 @@ func TestDeleteUserSuccess(t *testing.T) {
 
 type mockUserRepositoryFailRepo struct{}
 
-func (m mockUserRepositoryFailRepo) GetAll() ([]common.UserResponse, error) {
-	return nil, assert.AnError
+func (m mockUserRepositoryFailRepo) GetAll(ctx context.Context, opts query.Options) ([]entity.User, query.Page, error) {
+	return nil, query.Page{}, assert.AnError
+}
+
+func (m mockUserRepositoryFailRepo) Get(context.Context, int) (entity.User, error) {
+	return entity.User{}, assert.AnError
 }
 
-func (m mockUserRepositoryFailRepo) Get(int) (common.UserResponse, error) {
-	return common.UserResponse{}, assert.AnError
+func (m mockUserRepositoryFailRepo) Create(context.Context, entity.User) (entity.User, error) {
+	return entity.User{}, fmt.Errorf("create user failed")
 }
 
-func (m mockUserRepositoryFailRepo) Create(entity.User) (int, error) {
-	return 0, fmt.Errorf("create user failed")
+func (m mockUserRepositoryFailRepo) Update(context.Context, entity.User) error {
+	return fmt.Errorf("update user failed")
 }
 
-func (m mockUserRepositoryFailRepo) Update(entity.User) (int, error) {
-	return http.StatusInternalServerError, fmt.Errorf("update user failed")
+func (m mockUserRepositoryFailRepo) Patch(ctx context.Context, user entity.User, fields []string) (entity.User, error) {
+	return entity.User{}, assert.AnError
 }
 
-func (m mockUserRepositoryFailRepo) Delete(int) (int, error) {
-	return http.StatusInternalServerError, fmt.Errorf("delete user failed")
+func (m mockUserRepositoryFailRepo) Delete(context.Context, int, int, int) error {
+	return fmt.Errorf("delete user failed")
+}
+
+func (m mockUserRepositoryFailRepo) Restore(context.Context, int, int) error {
+	return fmt.Errorf("restore user failed")
+}
+
+func (m mockUserRepositoryFailRepo) Purge(context.Context, time.Time) (int64, error) {
+	return 0, assert.AnError
+}
+
+func (m mockUserRepositoryFailRepo) SetRole(context.Context, int, string, int) error {
+	return fmt.Errorf("set user role failed")
 }
 
 func TestGetAllUsersFailRepo(t *testing.T) {
 	t.Run("TestGetAllUsersFailRepo", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleCustomer)
 
 		request := httptest.NewRequest(http.MethodGet, "/", nil)
 		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
@@ -296,12 +498,15 @@ func TestGetAllUsersFailRepo(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/users")
 
-		userController := New(mockUserRepositoryFailRepo{})
-		midware.JWTMiddleware()(userController.GetAll())(context)
+		userController := New(userService.New(mockUserRepositoryFailRepo{}, mockTransactions{users: mockUserRepositoryFailRepo{}}))
+		if err := midware.JWTMiddleware()(userController.GetAll())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetAllUsersResponse{}
 		body := response.Body.String()
@@ -319,7 +524,7 @@ func TestGetAllUsersFailRepo(t *testing.T) {
 
 func TestGetUserFailRepo(t *testing.T) {
 	t.Run("TestGetUserFailRepo", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleCustomer)
 
 		request := httptest.NewRequest(http.MethodGet, "/", nil)
 		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
@@ -328,14 +533,17 @@ func TestGetUserFailRepo(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/users/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		userController := New(mockUserRepositoryFailRepo{})
-		midware.JWTMiddleware()(userController.Get())(context)
+		userController := New(userService.New(mockUserRepositoryFailRepo{}, mockTransactions{users: mockUserRepositoryFailRepo{}}))
+		if err := midware.JWTMiddleware()(userController.Get())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetUserResponse{}
 		body := response.Body.String()
@@ -355,8 +563,8 @@ func TestCreateUserFailRepo(t *testing.T) {
 	t.Run("TestCreateUserFailRepo", func(t *testing.T) {
 		requestBody, _ := json.Marshal(map[string]string{
 			"name":     "user",
-			"email":    "email",
-			"password": "password",
+			"email":    "user1@mail.com",
+			"password": "Passw0rd",
 		})
 
 		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
@@ -365,12 +573,15 @@ func TestCreateUserFailRepo(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/users")
 
-		userController := New(mockUserRepositoryFailRepo{})
-		userController.Create()(context)
+		userController := New(userService.New(mockUserRepositoryFailRepo{}, mockTransactions{users: mockUserRepositoryFailRepo{}}))
+		if err := userController.Create()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.CreateUserResponse{}
 		body := response.Body.String()
@@ -388,12 +599,12 @@ func TestCreateUserFailRepo(t *testing.T) {
 
 func TestUpdateUserFailRepo(t *testing.T) {
 	t.Run("TestUpdateUserFailRepo", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleCustomer)
 
 		requestBody, _ := json.Marshal(map[string]string{
 			"name":     "user",
-			"email":    "email",
-			"password": "password",
+			"email":    "user1@mail.com",
+			"password": "Passw0rd",
 		})
 
 		request := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(requestBody))
@@ -404,14 +615,17 @@ func TestUpdateUserFailRepo(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/users/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		userController := New(mockUserRepositoryFailRepo{})
-		midware.JWTMiddleware()(userController.Update())(context)
+		userController := New(userService.New(mockUserRepositoryFailRepo{}, mockTransactions{users: mockUserRepositoryFailRepo{}}))
+		if err := midware.JWTMiddleware()(userController.Update())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.UpdateUserResponse{}
 		body := response.Body.String()
@@ -429,7 +643,7 @@ func TestUpdateUserFailRepo(t *testing.T) {
 
 func TestDeleteUserFailRepo(t *testing.T) {
 	t.Run("TestDeleteUserFailRepo", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleCustomer)
 
 		request := httptest.NewRequest(http.MethodDelete, "/", nil)
 		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
@@ -438,14 +652,17 @@ func TestDeleteUserFailRepo(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/users/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		userController := New(mockUserRepositoryFailRepo{})
-		midware.JWTMiddleware()(userController.Delete())(context)
+		userController := New(userService.New(mockUserRepositoryFailRepo{}, mockTransactions{users: mockUserRepositoryFailRepo{}}))
+		if err := midware.JWTMiddleware()(userController.Delete())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.DeleteUserResponse{}
 		body := response.Body.String()
@@ -465,29 +682,45 @@ func TestDeleteUserFailRepo(t *testing.T) {
 
 type mockUserRepositoryFailOther struct{}
 
-func (m mockUserRepositoryFailOther) GetAll() ([]common.UserResponse, error) {
-	return []common.UserResponse{}, nil
+func (m mockUserRepositoryFailOther) GetAll(ctx context.Context, opts query.Options) ([]entity.User, query.Page, error) {
+	return []entity.User{}, opts.NewPage(), nil
 }
 
-func (m mockUserRepositoryFailOther) Get(int) (common.UserResponse, error) {
-	return common.UserResponse{}, nil
+func (m mockUserRepositoryFailOther) Get(context.Context, int) (entity.User, error) {
+	return entity.User{}, domain.NotFound("user does not exist")
 }
 
-func (m mockUserRepositoryFailOther) Create(entity.User) (int, error) {
-	return 0, nil
+func (m mockUserRepositoryFailOther) Create(context.Context, entity.User) (entity.User, error) {
+	return entity.User{}, nil
+}
+
+func (m mockUserRepositoryFailOther) Update(context.Context, entity.User) error {
+	return nil
+}
+
+func (m mockUserRepositoryFailOther) Patch(ctx context.Context, user entity.User, fields []string) (entity.User, error) {
+	return entity.User{}, nil
 }
 
-func (m mockUserRepositoryFailOther) Update(entity.User) (int, error) {
-	return http.StatusOK, nil
+func (m mockUserRepositoryFailOther) Delete(context.Context, int, int, int) error {
+	return nil
 }
 
-func (m mockUserRepositoryFailOther) Delete(int) (int, error) {
-	return http.StatusOK, nil
+func (m mockUserRepositoryFailOther) Restore(context.Context, int, int) error {
+	return domain.NotFound("user does not exist")
+}
+
+func (m mockUserRepositoryFailOther) Purge(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m mockUserRepositoryFailOther) SetRole(context.Context, int, string, int) error {
+	return nil
 }
 
 func TestGetAllUsersEmptyDirectory(t *testing.T) {
 	t.Run("TestGetAllUsersEmptyDirectory", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleCustomer)
 
 		request := httptest.NewRequest(http.MethodGet, "/", nil)
 		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
@@ -496,12 +729,15 @@ func TestGetAllUsersEmptyDirectory(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/users")
 
-		userController := New(mockUserRepositoryFailOther{})
-		midware.JWTMiddleware()(userController.GetAll())(context)
+		userController := New(userService.New(mockUserRepositoryFailOther{}, mockTransactions{users: mockUserRepositoryFailOther{}}))
+		if err := midware.JWTMiddleware()(userController.GetAll())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetAllUsersResponse{}
 		body := response.Body.String()
@@ -511,6 +747,16 @@ func TestGetAllUsersEmptyDirectory(t *testing.T) {
 			Code:    http.StatusOK,
 			Message: "users directory empty",
 			Data:    nil,
+			Meta: query.Page{
+				Total: 0,
+				Page:  1,
+				Limit: query.DefaultLimit,
+				Links: query.Links{
+					Self:  "/",
+					First: "/?page=1",
+					Last:  "/?page=1",
+				},
+			},
 		}
 
 		assert.Equal(t, expected, actual)
@@ -519,7 +765,7 @@ func TestGetAllUsersEmptyDirectory(t *testing.T) {
 
 func TestGetUserFailInvalidId(t *testing.T) {
 	t.Run("TestGetUserFailInvalidId", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleCustomer)
 
 		request := httptest.NewRequest(http.MethodGet, "/", nil)
 		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
@@ -528,14 +774,17 @@ func TestGetUserFailInvalidId(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/users/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("invalid id")
 
-		userController := New(mockUserRepositoryFailOther{})
-		midware.JWTMiddleware()(userController.Get())(context)
+		userController := New(userService.New(mockUserRepositoryFailOther{}, mockTransactions{users: mockUserRepositoryFailOther{}}))
+		if err := midware.JWTMiddleware()(userController.Get())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetUserResponse{}
 		body := response.Body.String()
@@ -553,7 +802,7 @@ func TestGetUserFailInvalidId(t *testing.T) {
 
 func TestGetUserDoesNotExist(t *testing.T) {
 	t.Run("TestGetUserDoesNotExist", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleCustomer)
 
 		request := httptest.NewRequest(http.MethodGet, "/", nil)
 		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
@@ -562,21 +811,24 @@ func TestGetUserDoesNotExist(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/users/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		userController := New(mockUserRepositoryFailOther{})
-		midware.JWTMiddleware()(userController.Get())(context)
+		userController := New(userService.New(mockUserRepositoryFailOther{}, mockTransactions{users: mockUserRepositoryFailOther{}}))
+		if err := midware.JWTMiddleware()(userController.Get())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.GetUserResponse{}
 		body := response.Body.String()
 		json.Unmarshal([]byte(body), &actual)
 
 		expected := common.GetUserResponse{
-			Code:    http.StatusBadRequest,
+			Code:    http.StatusNotFound,
 			Message: "user does not exist",
 			Data:    nil,
 		}
@@ -589,7 +841,7 @@ func TestCreateUserFailBinding(t *testing.T) {
 	t.Run("TestCreateUserFailBinding", func(t *testing.T) {
 		requestBody, _ := json.Marshal(map[string]interface{}{
 			"name":     "user",
-			"email":    "email",
+			"email":    "user1@mail.com",
 			"password": 1234,
 		})
 
@@ -599,12 +851,15 @@ func TestCreateUserFailBinding(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/users")
 
-		userController := New(mockUserRepositoryFailOther{})
-		userController.Create()(context)
+		userController := New(userService.New(mockUserRepositoryFailOther{}, mockTransactions{users: mockUserRepositoryFailOther{}}))
+		if err := userController.Create()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.CreateUserResponse{}
 		body := response.Body.String()
@@ -622,11 +877,11 @@ func TestCreateUserFailBinding(t *testing.T) {
 
 func TestUpdateUserFailInvalidId(t *testing.T) {
 	t.Run("TestUpdateUserFailBinding", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleCustomer)
 
 		requestBody, _ := json.Marshal(map[string]interface{}{
 			"name":     "user",
-			"email":    "email",
+			"email":    "user1@mail.com",
 			"password": "password1",
 		})
 
@@ -638,14 +893,17 @@ func TestUpdateUserFailInvalidId(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/users/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("invalid id")
 
-		userController := New(mockUserRepositoryFailOther{})
-		midware.JWTMiddleware()(userController.Update())(context)
+		userController := New(userService.New(mockUserRepositoryFailOther{}, mockTransactions{users: mockUserRepositoryFailOther{}}))
+		if err := midware.JWTMiddleware()(userController.Update())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.UpdateUserResponse{}
 		body := response.Body.String()
@@ -663,11 +921,11 @@ func TestUpdateUserFailInvalidId(t *testing.T) {
 
 func TestUpdateUserFailBinding(t *testing.T) {
 	t.Run("TestUpdateUserFailBinding", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleCustomer)
 
 		requestBody, _ := json.Marshal(map[string]interface{}{
 			"name":     "user",
-			"email":    "email",
+			"email":    "user1@mail.com",
 			"password": 1234,
 		})
 
@@ -679,14 +937,17 @@ func TestUpdateUserFailBinding(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/users/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("1")
 
-		userController := New(mockUserRepositoryFailOther{})
-		midware.JWTMiddleware()(userController.Update())(context)
+		userController := New(userService.New(mockUserRepositoryFailOther{}, mockTransactions{users: mockUserRepositoryFailOther{}}))
+		if err := midware.JWTMiddleware()(userController.Update())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.UpdateUserResponse{}
 		body := response.Body.String()
@@ -704,7 +965,7 @@ func TestUpdateUserFailBinding(t *testing.T) {
 
 func TestDeleteUserFailInvalidId(t *testing.T) {
 	t.Run("TestDeleteUserFailInvalidId", func(t *testing.T) {
-		token, _ := midware.CreateToken(1, "admin")
+		token, _ := midware.CreateToken(1, "admin", entity.RoleCustomer)
 
 		request := httptest.NewRequest(http.MethodDelete, "/", nil)
 		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
@@ -713,14 +974,17 @@ func TestDeleteUserFailInvalidId(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
 
 		context := e.NewContext(request, response)
 		context.SetPath("/users/:id")
 		context.SetParamNames("id")
 		context.SetParamValues("invalid id")
 
-		userController := New(mockUserRepositoryFailRepo{})
-		midware.JWTMiddleware()(userController.Delete())(context)
+		userController := New(userService.New(mockUserRepositoryFailRepo{}, mockTransactions{users: mockUserRepositoryFailRepo{}}))
+		if err := midware.JWTMiddleware()(userController.Delete())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
 
 		actual := common.DeleteUserResponse{}
 		body := response.Body.String()
@@ -735,3 +999,1028 @@ func TestDeleteUserFailInvalidId(t *testing.T) {
 		assert.Equal(t, expected, actual)
 	})
 }
+
+// TEST AUTHORIZATION
+
+func TestUpdateUserFailForbidden(t *testing.T) {
+	t.Run("TestUpdateUserFailForbidden", func(t *testing.T) {
+		token, _ := midware.CreateToken(2, "user2", entity.RoleCustomer)
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"name":     "user",
+			"email":    "user1@mail.com",
+			"password": "Passw0rd",
+		})
+
+		request := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/users/:id")
+		context.SetParamNames("id")
+		context.SetParamValues("1")
+
+		userController := New(userService.New(mockUserRepositorySuccess{}, mockTransactions{users: mockUserRepositorySuccess{}}))
+		if err := midware.JWTMiddleware()(userController.Update())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.UpdateUserResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.UpdateUserResponse{
+			Code:    http.StatusForbidden,
+			Message: "forbidden",
+			Data:    nil,
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+func TestDeleteUserFailForbidden(t *testing.T) {
+	t.Run("TestDeleteUserFailForbidden", func(t *testing.T) {
+		token, _ := midware.CreateToken(2, "user2", entity.RoleCustomer)
+
+		request := httptest.NewRequest(http.MethodDelete, "/", nil)
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/users/:id")
+		context.SetParamNames("id")
+		context.SetParamValues("1")
+
+		userController := New(userService.New(mockUserRepositorySuccess{}, mockTransactions{users: mockUserRepositorySuccess{}}))
+		if err := midware.JWTMiddleware()(userController.Delete())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.DeleteUserResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.DeleteUserResponse{
+			Code:    http.StatusForbidden,
+			Message: "forbidden",
+			Data:    nil,
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+func TestUpdateUserAdminOverride(t *testing.T) {
+	t.Run("TestUpdateUserAdminOverride", func(t *testing.T) {
+		token, _ := midware.TokenService().Create(jwt.MapClaims{"id": 2, "name": "admin", "role": entity.RoleAdmin})
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"name":     "user",
+			"email":    "user1@mail.com",
+			"password": "Passw0rd",
+		})
+
+		request := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/users/:id")
+		context.SetParamNames("id")
+		context.SetParamValues("1")
+
+		userController := New(userService.New(mockUserRepositorySuccess{}, mockTransactions{users: mockUserRepositorySuccess{}}))
+		if err := midware.JWTMiddleware()(userController.Update())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.UpdateUserResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.UpdateUserResponse{
+			Code:    http.StatusOK,
+			Message: "update user success",
+			Data: []common.UserResponse{
+				{
+					Id:    1,
+					Name:  "user",
+					Email: "user1@mail.com",
+				},
+			},
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+func TestDeleteUserAdminOverride(t *testing.T) {
+	t.Run("TestDeleteUserAdminOverride", func(t *testing.T) {
+		token, _ := midware.TokenService().Create(jwt.MapClaims{"id": 2, "name": "admin", "role": entity.RoleAdmin})
+
+		request := httptest.NewRequest(http.MethodDelete, "/", nil)
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/users/:id")
+		context.SetParamNames("id")
+		context.SetParamValues("1")
+
+		userController := New(userService.New(mockUserRepositorySuccess{}, mockTransactions{users: mockUserRepositorySuccess{}}))
+		if err := midware.JWTMiddleware()(userController.Delete())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.DeleteUserResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.DeleteUserResponse{
+			Code:    http.StatusOK,
+			Message: "delete user success",
+			Data:    nil,
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+func TestSetUserRoleSuccess(t *testing.T) {
+	t.Run("TestSetUserRoleSuccess", func(t *testing.T) {
+		token, _ := midware.CreateToken(2, "admin", entity.RoleAdmin)
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"role": entity.RoleMerchant,
+		})
+
+		request := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/users/:id/role")
+		context.SetParamNames("id")
+		context.SetParamValues("1")
+
+		userController := New(userService.New(mockUserRepositorySuccess{}, mockTransactions{users: mockUserRepositorySuccess{}}))
+		if err := midware.JWTMiddleware()(userController.SetRole())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.GetUserResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.GetUserResponse{
+			Code:    http.StatusOK,
+			Message: "set user role success",
+			Data: []common.UserResponse{
+				{
+					Id:   1,
+					Role: entity.RoleMerchant,
+				},
+			},
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+func TestSetUserRoleFailForbidden(t *testing.T) {
+	t.Run("TestSetUserRoleFailForbidden", func(t *testing.T) {
+		token, _ := midware.CreateToken(1, "user1", entity.RoleMerchant)
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"role": entity.RoleAdmin,
+		})
+
+		request := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/users/:id/role")
+		context.SetParamNames("id")
+		context.SetParamValues("1")
+
+		userController := New(userService.New(mockUserRepositorySuccess{}, mockTransactions{users: mockUserRepositorySuccess{}}))
+		if err := midware.JWTMiddleware()(userController.SetRole())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.GetUserResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.GetUserResponse{
+			Code:    http.StatusForbidden,
+			Message: "forbidden",
+			Data:    nil,
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+func TestSetUserRoleFailInvalidRole(t *testing.T) {
+	t.Run("TestSetUserRoleFailInvalidRole", func(t *testing.T) {
+		token, _ := midware.CreateToken(2, "admin", entity.RoleAdmin)
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"role": "superuser",
+		})
+
+		request := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/users/:id/role")
+		context.SetParamNames("id")
+		context.SetParamValues("1")
+
+		userController := New(userService.New(mockUserRepositorySuccess{}, mockTransactions{users: mockUserRepositorySuccess{}}))
+		if err := midware.JWTMiddleware()(userController.SetRole())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := struct {
+			Code    int                 `json:"code"`
+			Message string              `json:"message"`
+			Data    []domain.FieldError `json:"data"`
+		}{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, actual.Code)
+		assert.Equal(t, "validation failed", actual.Message)
+		assert.Equal(t, []domain.FieldError{
+			{Field: "role", Code: "oneof", Message: "role must be one of admin, merchant or customer"},
+		}, actual.Data)
+	})
+}
+
+func TestSetUserRoleFailRepo(t *testing.T) {
+	t.Run("TestSetUserRoleFailRepo", func(t *testing.T) {
+		token, _ := midware.CreateToken(2, "admin", entity.RoleAdmin)
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"role": entity.RoleMerchant,
+		})
+
+		request := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/users/:id/role")
+		context.SetParamNames("id")
+		context.SetParamValues("1")
+
+		userController := New(userService.New(mockUserRepositoryFailRepo{}, mockTransactions{users: mockUserRepositoryFailRepo{}}))
+		if err := midware.JWTMiddleware()(userController.SetRole())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.GetUserResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.GetUserResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "set user role failed",
+			Data:    nil,
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+// TEST PATCH
+
+// patchUser serves a PATCH of user 1 with body of contentType, by user 1.
+func patchUser(controller *UserController, contentType string, body string) *httptest.ResponseRecorder {
+	token, _ := midware.CreateToken(1, "user1", entity.RoleCustomer)
+
+	request := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+	request.Header.Set(echo.HeaderContentType, contentType)
+	request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+
+	response := httptest.NewRecorder()
+
+	e := echo.New()
+	e.HTTPErrorHandler = common.HTTPErrorHandler
+
+	context := e.NewContext(request, response)
+	context.SetPath("/users/:id")
+	context.SetParamNames("id")
+	context.SetParamValues("1")
+
+	if err := midware.JWTMiddleware()(controller.Patch())(context); err != nil {
+		e.HTTPErrorHandler(err, context)
+	}
+
+	return response
+}
+
+func TestPatchUserMergePatch(t *testing.T) {
+	t.Run("TestPatchUserMergePatch", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectGetUser(mock, "user1")
+		mock.ExpectBegin()
+		mock.ExpectPrepare("SELECT id, name, email, role, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "user1", "user1@mail.com", entity.RoleCustomer, 1, stamped, stamped, 1, 1))
+		mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare("SELECT version FROM users WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+		mock.ExpectPrepare("UPDATE users SET name = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL").
+			ExpectExec().
+			WithArgs("user2", sqlmock.AnyArg(), 1, 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT id, name, email, role, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ? AND deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "user2", "user1@mail.com", entity.RoleCustomer, 2, stamped, stamped, 1, 1))
+		mock.ExpectExec("RELEASE SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare(queryAudit)
+		expectAudit(mock, 1, entity.AuditPatch, entity.AuditUser, 1)
+		mock.ExpectCommit()
+
+		response := patchUser(newController(db), common.MIMEApplicationMergePatchJSON, `{"name":"user2","email":"user1@mail.com"}`)
+
+		actual := common.UpdateUserResponse{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		expected := common.UpdateUserResponse{
+			Code:    http.StatusOK,
+			Message: "patch user success",
+			Data: []common.UserResponse{
+				{
+					Id:        1,
+					Name:      "user2",
+					Email:     "user1@mail.com",
+					Role:      entity.RoleCustomer,
+					CreatedAt: stamped,
+					UpdatedAt: stamped,
+					CreatedBy: &stampedBy,
+					UpdatedBy: &stampedBy,
+				},
+			},
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPatchUserJSONPatch(t *testing.T) {
+	t.Run("TestPatchUserJSONPatch", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectGetUser(mock, "user1")
+		mock.ExpectBegin()
+		mock.ExpectPrepare("SELECT id, name, email, role, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "user1", "user1@mail.com", entity.RoleCustomer, 1, stamped, stamped, 1, 1))
+		mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare("SELECT version FROM users WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+		mock.ExpectPrepare("UPDATE users SET password = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL").
+			ExpectExec().
+			WithArgs(hashOf{"Passw0rd"}, sqlmock.AnyArg(), 1, 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT id, name, email, role, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ? AND deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "user1", "user1@mail.com", entity.RoleCustomer, 1, stamped, stamped, 1, 1))
+		mock.ExpectExec("RELEASE SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare(queryAudit)
+		expectAudit(mock, 1, entity.AuditPatch, entity.AuditUser, 1)
+		mock.ExpectCommit()
+
+		response := patchUser(newController(db), common.MIMEApplicationJSONPatchJSON, `[{"op":"test","path":"/name","value":"user1"},{"op":"add","path":"/password","value":"Passw0rd"}]`)
+
+		assert.Equal(t, http.StatusOK, response.Code, response.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPatchUserFailTest(t *testing.T) {
+	t.Run("TestPatchUserFailTest", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectGetUser(mock, "user2")
+
+		response := patchUser(newController(db), common.MIMEApplicationJSONPatchJSON, `[{"op":"test","path":"/name","value":"user1"},{"op":"replace","path":"/name","value":"user3"}]`)
+
+		actual := common.UpdateUserResponse{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		assert.Equal(t, common.UpdateUserResponse{Code: http.StatusConflict, Message: "patch does not apply"}, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPatchUserFailReadonly(t *testing.T) {
+	t.Run("TestPatchUserFailReadonly", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectGetUser(mock, "user1")
+
+		response := patchUser(newController(db), common.MIMEApplicationJSONPatchJSON, `[{"op":"replace","path":"/role","value":"admin"}]`)
+
+		actual := struct {
+			Code int                 `json:"code"`
+			Data []domain.FieldError `json:"data"`
+		}{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, actual.Code)
+		assert.Equal(t, []domain.FieldError{{Field: "role", Code: "readonly", Message: "role cannot be changed"}}, actual.Data)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPatchUserFailMediaType(t *testing.T) {
+	t.Run("TestPatchUserFailMediaType", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectGetUser(mock, "user1")
+
+		response := patchUser(newController(db), echo.MIMEApplicationJSON, `{"name":"user2"}`)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, response.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TEST DOMAIN ERRORS
+
+func TestCreateUserConflict(t *testing.T) {
+	t.Run("TestCreateUserConflict", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectPrepare("SELECT COUNT(*) FROM users WHERE email = ? AND id <> ?").
+			ExpectQuery().
+			WithArgs("user1@mail.com", 0).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare("INSERT INTO users (name, email, password, role, created_at, updated_at, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+		mock.ExpectPrepare("SELECT id, name, email, role, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ? AND deleted_at IS NULL")
+		mock.ExpectExec("INSERT INTO users (name, email, password, role, created_at, updated_at, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?)").
+			WithArgs("user1", "user1@mail.com", hashOf{"Passw0rd"}, entity.RoleCustomer, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, nil).
+			WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'user1' for key 'uq_users_name'"})
+		mock.ExpectExec("ROLLBACK TO SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"name":     "user1",
+			"email":    "user1@mail.com",
+			"password": "Passw0rd",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/users")
+
+		userController := newController(db)
+		if err := userController.Create()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.CreateUserResponse{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		expected := common.CreateUserResponse{
+			Code:    http.StatusConflict,
+			Message: "user name already taken",
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCreateUserEmailTaken(t *testing.T) {
+	t.Run("TestCreateUserEmailTaken", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectPrepare("SELECT COUNT(*) FROM users WHERE email = ? AND id <> ?").
+			ExpectQuery().
+			WithArgs("user1@mail.com", 0).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectRollback()
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"name":     "user1",
+			"email":    "user1@mail.com",
+			"password": "Passw0rd",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/users")
+
+		userController := newController(db)
+		if err := userController.Create()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := struct {
+			Code    int                 `json:"code"`
+			Message string              `json:"message"`
+			Data    []domain.FieldError `json:"data"`
+		}{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, actual.Code)
+		assert.Equal(t, "validation failed", actual.Message)
+		assert.Equal(t, []domain.FieldError{
+			{Field: "email", Code: "unique", Message: "email is already registered"},
+		}, actual.Data)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("TestCreateUserEmailTakenConcurrently", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		// The email was free when checked, and taken by the time of the insert.
+		mock.ExpectBegin()
+		mock.ExpectPrepare("SELECT COUNT(*) FROM users WHERE email = ? AND id <> ?").
+			ExpectQuery().
+			WithArgs("user1@mail.com", 0).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare("INSERT INTO users (name, email, password, role, created_at, updated_at, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+		mock.ExpectPrepare("SELECT id, name, email, role, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ? AND deleted_at IS NULL")
+		mock.ExpectExec("INSERT INTO users (name, email, password, role, created_at, updated_at, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?)").
+			WithArgs("user1", "user1@mail.com", hashOf{"Passw0rd"}, entity.RoleCustomer, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, nil).
+			WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'user1@mail.com' for key 'users.uq_users_email'"})
+		mock.ExpectExec("ROLLBACK TO SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"name":     "user1",
+			"email":    "user1@mail.com",
+			"password": "Passw0rd",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/users")
+
+		userController := newController(db)
+		if err := userController.Create()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := struct {
+			Code    int                 `json:"code"`
+			Message string              `json:"message"`
+			Data    []domain.FieldError `json:"data"`
+		}{}
+		json.Unmarshal(response.Body.Bytes(), &actual)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, actual.Code)
+		assert.Equal(t, []domain.FieldError{
+			{Field: "email", Code: "unique", Message: "email is already registered"},
+		}, actual.Data)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TEST SQL INJECTION
+
+const injection = "user'); DELETE FROM users; -- "
+
+func TestCreateUserInjection(t *testing.T) {
+	t.Run("TestCreateUserInjection", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectPrepare("SELECT COUNT(*) FROM users WHERE email = ? AND id <> ?").
+			ExpectQuery().
+			WithArgs("user1@mail.com", 0).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare("INSERT INTO users (name, email, password, role, created_at, updated_at, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+		mock.ExpectPrepare("SELECT id, name, email, role, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ? AND deleted_at IS NULL")
+		mock.ExpectExec("INSERT INTO users (name, email, password, role, created_at, updated_at, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?)").
+			WithArgs(injection, "user1@mail.com", hashOf{"Passw0rd"}, entity.RoleCustomer, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, nil).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT id, name, email, role, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ? AND deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, injection, "user1@mail.com", entity.RoleCustomer, 1, stamped, stamped, 1, 1))
+		mock.ExpectExec("RELEASE SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare(queryAudit)
+		expectAudit(mock, nil, entity.AuditCreate, entity.AuditUser, 1)
+		mock.ExpectCommit()
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"name":     injection,
+			"email":    "user1@mail.com",
+			"password": "Passw0rd",
+		})
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/users")
+
+		userController := newController(db)
+		if err := userController.Create()(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.CreateUserResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.CreateUserResponse{
+			Code:    http.StatusOK,
+			Message: "create user success",
+			Data: []common.UserResponse{
+				{
+					Id:        1,
+					Name:      injection,
+					Email:     "user1@mail.com",
+					Role:      entity.RoleCustomer,
+					CreatedAt: stamped,
+					UpdatedAt: stamped,
+					CreatedBy: &stampedBy,
+					UpdatedBy: &stampedBy,
+				},
+			},
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUpdateUserInjection(t *testing.T) {
+	t.Run("TestUpdateUserInjection", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT id, name, email, role, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "user1", "user1@mail.com", entity.RoleCustomer, 1, stamped, stamped, 1, 1))
+		mock.ExpectBegin()
+		mock.ExpectPrepare("SELECT id, name, email, role, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "user1", "user1@mail.com", entity.RoleCustomer, 1, stamped, stamped, 1, 1))
+		mock.ExpectPrepare("SELECT COUNT(*) FROM users WHERE email = ? AND id <> ?").
+			ExpectQuery().
+			WithArgs("user1@mail.com", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectPrepare("UPDATE users SET name = ?, email = ?, password = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL").
+			ExpectExec().
+			WithArgs(injection, "user1@mail.com", hashOf{"Passw0rd"}, sqlmock.AnyArg(), 1, 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT id, name, email, role, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ? AND deleted_at IS NULL").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, injection, "user1@mail.com", entity.RoleCustomer, 2, stamped, stamped, 1, 1))
+		mock.ExpectPrepare(queryAudit)
+		expectAudit(mock, 1, entity.AuditUpdate, entity.AuditUser, 1)
+		mock.ExpectCommit()
+
+		token, _ := midware.CreateToken(1, "admin", entity.RoleCustomer)
+
+		requestBody, _ := json.Marshal(map[string]string{
+			"name":     injection,
+			"email":    "user1@mail.com",
+			"password": "Passw0rd",
+		})
+
+		request := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(requestBody))
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+
+		e := echo.New()
+		e.HTTPErrorHandler = common.HTTPErrorHandler
+
+		context := e.NewContext(request, response)
+		context.SetPath("/users/:id")
+		context.SetParamNames("id")
+		context.SetParamValues("1")
+
+		userController := newController(db)
+		if err := midware.JWTMiddleware()(userController.Update())(context); err != nil {
+			e.HTTPErrorHandler(err, context)
+		}
+
+		actual := common.UpdateUserResponse{}
+		body := response.Body.String()
+		json.Unmarshal([]byte(body), &actual)
+
+		expected := common.UpdateUserResponse{
+			Code:    http.StatusOK,
+			Message: "update user success",
+			Data: []common.UserResponse{
+				{
+					Id:        1,
+					Name:      injection,
+					Email:     "user1@mail.com",
+					Role:      entity.RoleCustomer,
+					CreatedAt: stamped,
+					UpdatedAt: stamped,
+					CreatedBy: &stampedBy,
+					UpdatedBy: &stampedBy,
+				},
+			},
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TEST DELETE IN A TRANSACTION
+
+func deleteUser(controller *UserController) common.DeleteUserResponse {
+	token, _ := midware.CreateToken(1, "admin", entity.RoleCustomer)
+
+	request := httptest.NewRequest(http.MethodDelete, "/", nil)
+	request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+
+	response := httptest.NewRecorder()
+
+	e := echo.New()
+	e.HTTPErrorHandler = common.HTTPErrorHandler
+
+	context := e.NewContext(request, response)
+	context.SetPath("/users/:id")
+	context.SetParamNames("id")
+	context.SetParamValues("1")
+
+	if err := midware.JWTMiddleware()(controller.Delete())(context); err != nil {
+		e.HTTPErrorHandler(err, context)
+	}
+
+	actual := common.DeleteUserResponse{}
+	json.Unmarshal(response.Body.Bytes(), &actual)
+
+	return actual
+}
+
+// expectGetProducts expects the products of user 1 to be listed, products 3
+// and 4.
+func expectGetProducts(mock sqlmock.Sqlmock) {
+	mock.ExpectPrepare("SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p JOIN users u ON p.user_id = u.id WHERE p.user_id = ? AND p.deleted_at IS NULL ORDER BY p.id").
+		ExpectQuery().
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "merchant", "name", "price", "version", "created_at", "updated_at", "created_by", "updated_by"}).
+			AddRow(3, 1, "user1", "product3", 100, 1, stamped, stamped, 1, 1).
+			AddRow(4, 1, "user1", "product4", 100, 1, stamped, stamped, 1, 1))
+}
+
+func TestDeleteUserTransaction(t *testing.T) {
+	t.Run("TestDeleteUserTransaction", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectGetUser(mock, "user1")
+		mock.ExpectBegin()
+		mock.ExpectPrepare("SELECT id, name, email, role, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "user1", "user1@mail.com", entity.RoleCustomer, 1, stamped, stamped, 1, 1))
+		expectGetProducts(mock)
+		mock.ExpectPrepare("UPDATE users SET deleted_at = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL").
+			ExpectExec().
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectPrepare("UPDATE products p JOIN users u ON p.user_id = u.id SET p.deleted_at = u.deleted_at, p.updated_at = u.deleted_at, p.updated_by = ?, p.version = p.version + 1 WHERE p.user_id = ? AND p.deleted_at IS NULL").
+			ExpectExec().
+			WithArgs(1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectPrepare("UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL").
+			ExpectExec().
+			WithArgs(sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectPrepare(queryAudit)
+		expectAudit(mock, 1, entity.AuditDelete, entity.AuditUser, 1)
+		expectAudit(mock, 1, entity.AuditDelete, entity.AuditProduct, 3)
+		expectAudit(mock, 1, entity.AuditDelete, entity.AuditProduct, 4)
+		mock.ExpectCommit()
+
+		actual := deleteUser(newController(db))
+
+		expected := common.DeleteUserResponse{
+			Code:    http.StatusOK,
+			Message: "delete user success",
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteUserTransactionRollback(t *testing.T) {
+	t.Run("TestDeleteUserTransactionRollback", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectGetUser(mock, "user1")
+		mock.ExpectBegin()
+		mock.ExpectPrepare("SELECT id, name, email, role, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "user1", "user1@mail.com", entity.RoleCustomer, 1, stamped, stamped, 1, 1))
+		expectGetProducts(mock)
+		mock.ExpectPrepare("UPDATE users SET deleted_at = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL").
+			ExpectExec().
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare("SELECT version FROM users WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"version"}))
+		mock.ExpectRollback()
+
+		actual := deleteUser(newController(db))
+
+		expected := common.DeleteUserResponse{
+			Code:    http.StatusNotFound,
+			Message: "user does not exist",
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteUserTransactionCommitFail(t *testing.T) {
+	t.Run("TestDeleteUserTransactionCommitFail", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectGetUser(mock, "user1")
+		mock.ExpectBegin()
+		mock.ExpectPrepare("SELECT id, name, email, role, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "user1", "user1@mail.com", entity.RoleCustomer, 1, stamped, stamped, 1, 1))
+		expectGetProducts(mock)
+		mock.ExpectPrepare("UPDATE users SET deleted_at = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL").
+			ExpectExec().
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectPrepare("UPDATE products p JOIN users u ON p.user_id = u.id SET p.deleted_at = u.deleted_at, p.updated_at = u.deleted_at, p.updated_by = ?, p.version = p.version + 1 WHERE p.user_id = ? AND p.deleted_at IS NULL").
+			ExpectExec().
+			WithArgs(1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectPrepare("UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL").
+			ExpectExec().
+			WithArgs(sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectPrepare(queryAudit)
+		expectAudit(mock, 1, entity.AuditDelete, entity.AuditUser, 1)
+		expectAudit(mock, 1, entity.AuditDelete, entity.AuditProduct, 3)
+		expectAudit(mock, 1, entity.AuditDelete, entity.AuditProduct, 4)
+		mock.ExpectCommit().WillReturnError(assert.AnError)
+
+		actual := deleteUser(newController(db))
+
+		expected := common.DeleteUserResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "delete user failed",
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteUserTransactionAuditFail(t *testing.T) {
+	t.Run("TestDeleteUserTransactionAuditFail", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectGetUser(mock, "user1")
+		mock.ExpectBegin()
+		mock.ExpectPrepare("SELECT id, name, email, role, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "user1", "user1@mail.com", entity.RoleCustomer, 1, stamped, stamped, 1, 1))
+		expectGetProducts(mock)
+		mock.ExpectPrepare("UPDATE users SET deleted_at = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL").
+			ExpectExec().
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectPrepare("UPDATE products p JOIN users u ON p.user_id = u.id SET p.deleted_at = u.deleted_at, p.updated_at = u.deleted_at, p.updated_by = ?, p.version = p.version + 1 WHERE p.user_id = ? AND p.deleted_at IS NULL").
+			ExpectExec().
+			WithArgs(1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectPrepare("UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL").
+			ExpectExec().
+			WithArgs(sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectPrepare(queryAudit)
+		expectAudit(mock, 1, entity.AuditDelete, entity.AuditUser, 1)
+		expectAudit(mock, 1, entity.AuditDelete, entity.AuditProduct, 3).WillReturnError(assert.AnError)
+		mock.ExpectRollback()
+
+		actual := deleteUser(newController(db))
+
+		expected := common.DeleteUserResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "delete user failed",
+		}
+
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,54 @@
+package midware
+
+import (
+	"rest-api/design-pattern/domain"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Authorize lets the request through only when the role of its token grants
+// the permission. It runs before the handler reads anything, so a request
+// that is not allowed learns nothing of the resource it names, not even
+// whether it exists. It must run after JWTMiddleware. The services check the
+// permission again for callers that do not come through the router.
+func Authorize(permission domain.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			actor, err := ExtractActor(c)
+
+			if err != nil {
+				return domain.Unauthorized("unauthorized")
+			}
+
+			if !actor.Can(permission) {
+				return domain.Forbidden("forbidden")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// AuthorizeOwner is Authorize for the routes of a user, named by the id path
+// parameter, which only that user or an admin may change. An id that is not
+// a number is left for the handler to reject.
+func AuthorizeOwner() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			actor, err := ExtractActor(c)
+
+			if err != nil {
+				return domain.Unauthorized("unauthorized")
+			}
+
+			id, err := strconv.Atoi(c.Param("id"))
+
+			if err == nil && !actor.IsOwnerOrAdmin(id) {
+				return domain.Forbidden("forbidden")
+			}
+
+			return next(c)
+		}
+	}
+}
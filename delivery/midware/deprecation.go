@@ -0,0 +1,33 @@
+package midware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Deprecation tells clients of a route that it is deprecated since At and,
+// unless Sunset is zero, stops being served at Sunset.
+type Deprecation struct {
+	At     time.Time
+	Sunset time.Time
+}
+
+// Deprecated sets the Deprecation header (RFC 9745) and, when a sunset is
+// planned, the Sunset header (RFC 8594) on every response of the route.
+func Deprecated(deprecation Deprecation) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Response().Header()
+			header.Set("Deprecation", fmt.Sprintf("@%d", deprecation.At.Unix()))
+
+			if !deprecation.Sunset.IsZero() {
+				header.Set("Sunset", deprecation.Sunset.UTC().Format(http.TimeFormat))
+			}
+
+			return next(c)
+		}
+	}
+}
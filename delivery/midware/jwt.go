@@ -1,7 +1,12 @@
 package midware
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/util/token"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt"
@@ -9,25 +14,132 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 )
 
-const secret_jwt = "Xcx*3-bPr9w&USU7"
+var (
+	tokens      *token.Service
+	revocations RevocationChecker
+	tokensMu    sync.Mutex
+)
+
+// RevocationChecker reports whether the access token with the given jti,
+// issued to the user with the given id at the given time, was revoked before
+// its expiry, e.g. on logout or when the user was deleted.
+type RevocationChecker interface {
+	IsRevoked(context.Context, string, int, time.Time) (bool, error)
+}
+
+func SetRevocationChecker(r RevocationChecker) {
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+
+	revocations = r
+}
+
+// SetTokenService installs the service every token is signed and verified
+// with. It must be called before the server starts handling requests.
+func SetTokenService(s *token.Service) {
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+
+	tokens = s
+}
+
+// TokenService returns the installed token service, falling back to one with
+// a random key when none was set, which is what tests rely on.
+func TokenService() *token.Service {
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+
+	if tokens == nil {
+		key, err := token.NewRandomHMACKey("ephemeral")
+
+		if err != nil {
+			panic(err)
+		}
+
+		tokens, _ = token.New([]*token.Key{key}, key.ID, time.Hour)
+	}
+
+	return tokens
+}
 
 func JWTMiddleware() echo.MiddlewareFunc {
-	return middleware.JWTWithConfig(middleware.JWTConfig{
-		SigningMethod: "HS256",
-		SigningKey:    []byte(secret_jwt),
+	jwtMiddleware := middleware.JWTWithConfig(middleware.JWTConfig{
+		KeyFunc: func(t *jwt.Token) (interface{}, error) {
+			return TokenService().Keyfunc(t)
+		},
+		// A request without a token is unauthenticated rather than bad.
+		ErrorHandler: func(err error) error {
+			if err == middleware.ErrJWTMissing {
+				return &echo.HTTPError{Code: http.StatusUnauthorized, Message: middleware.ErrJWTMissing.Message, Internal: err}
+			}
+
+			return &echo.HTTPError{Code: middleware.ErrJWTInvalid.Code, Message: middleware.ErrJWTInvalid.Message, Internal: err}
+		},
 	})
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return jwtMiddleware(func(c echo.Context) error {
+			tokensMu.Lock()
+			checker := revocations
+			tokensMu.Unlock()
+
+			if checker == nil {
+				return next(c)
+			}
+
+			jti, _, err := ExtractJti(c)
+
+			if err != nil {
+				return middleware.ErrJWTInvalid
+			}
+
+			actor, err := ExtractActor(c)
+
+			if err != nil {
+				return middleware.ErrJWTInvalid
+			}
+
+			revoked, err := checker.IsRevoked(c.Request().Context(), jti, actor.Id, ExtractIssuedAt(c))
+
+			if err != nil {
+				return echo.ErrInternalServerError
+			}
+
+			if revoked {
+				return middleware.ErrJWTInvalid
+			}
+
+			return next(c)
+		})
+	}
 }
 
-func CreateToken(id int, name string) (string, error) {
+// OptionalJWTMiddleware authenticates the requests carrying a token as
+// JWTMiddleware does, and lets those without one through anonymously.
+func OptionalJWTMiddleware() echo.MiddlewareFunc {
+	jwtMiddleware := JWTMiddleware()
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		authenticated := jwtMiddleware(next)
+
+		return func(c echo.Context) error {
+			if c.Request().Header.Get(echo.HeaderAuthorization) == "" {
+				return next(c)
+			}
+
+			return authenticated(c)
+		}
+	}
+}
+
+func CreateToken(id int, name string, role string) (string, error) {
 	claims := jwt.MapClaims{}
 	claims["authorized"] = true
 	claims["id"] = id
 	claims["name"] = name
-	claims["exp"] = time.Now().Add(time.Hour * 1).Unix()
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	claims["role"] = role
 
-	return token.SignedString([]byte(secret_jwt))
+	return TokenService().Create(claims)
 }
 
 func ValidateToken(e echo.Context) bool {
@@ -47,3 +159,49 @@ func ExtractId(e echo.Context) (int, error) {
 
 	return 0, fmt.Errorf("unauthorized")
 }
+
+// ExtractActor returns the user the request token was issued to, for the
+// services to act on behalf of.
+func ExtractActor(e echo.Context) (domain.Actor, error) {
+	login, ok := e.Get("user").(*jwt.Token)
+
+	if !ok || !login.Valid {
+		return domain.Actor{}, fmt.Errorf("unauthorized")
+	}
+
+	claims := login.Claims.(jwt.MapClaims)
+	id, _ := claims["id"].(float64)
+	role, _ := claims["role"].(string)
+
+	return domain.Actor{Id: int(id), Role: role}, nil
+}
+
+// ExtractJti returns the id and expiry of the access token of the request.
+func ExtractJti(e echo.Context) (string, time.Time, error) {
+	login := e.Get("user").(*jwt.Token)
+
+	if login.Valid {
+		claims := login.Claims.(jwt.MapClaims)
+		jti, _ := claims["jti"].(string)
+		exp, _ := claims["exp"].(float64)
+
+		if jti != "" {
+			return jti, time.Unix(int64(exp), 0), nil
+		}
+	}
+
+	return "", time.Time{}, fmt.Errorf("unauthorized")
+}
+
+// ExtractIssuedAt returns when the access token of the request was issued,
+// or the zero time when it carries no iat claim.
+func ExtractIssuedAt(e echo.Context) time.Time {
+	login := e.Get("user").(*jwt.Token)
+	claims := login.Claims.(jwt.MapClaims)
+
+	if iat, ok := claims["iat"].(float64); ok {
+		return time.Unix(int64(iat), 0)
+	}
+
+	return time.Time{}
+}
@@ -1,12 +1,46 @@
 package midware
 
 import (
+	"regexp"
+	"rest-api/design-pattern/domain"
+
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
+// requestIDPattern is what an X-Request-Id sent with a request must look
+// like to be kept: short enough for the audit log to record it, and only of
+// characters common to trace ids.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// RequestID gives every request an id, the one of its X-Request-Id header if
+// it has one matching requestIDPattern and a new one otherwise, answered in
+// the same header and carried by the context of the request for the services
+// to record changes with.
+func RequestID() echo.MiddlewareFunc {
+	requestID := middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+		RequestIDHandler: func(c echo.Context, id string) {
+			c.SetRequest(c.Request().WithContext(domain.WithRequestID(c.Request().Context(), id)))
+		},
+	})
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		handler := requestID(next)
+
+		return func(c echo.Context) error {
+			if !requestIDPattern.MatchString(c.Request().Header.Get(echo.HeaderXRequestID)) {
+				c.Request().Header.Del(echo.HeaderXRequestID)
+			}
+
+			return handler(c)
+		}
+	}
+}
+
+// CustomLogger logs every request with the id RequestID gave it, which the
+// audit log records changes with.
 func CustomLogger() echo.MiddlewareFunc {
 	return middleware.LoggerWithConfig(middleware.LoggerConfig{
-		Format: `[${time_rfc3339}] ${status} ${method} ${host}${path} ${latency_human}` + "\n",
+		Format: `[${time_rfc3339}] ${id} ${status} ${method} ${host}${path} ${latency_human}` + "\n",
 	})
 }
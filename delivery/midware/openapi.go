@@ -0,0 +1,198 @@
+package midware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"rest-api/design-pattern/delivery/common"
+	"rest-api/design-pattern/domain"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/labstack/echo/v4"
+)
+
+// Merge patches are JSON documents, and decoded as such.
+func init() {
+	openapi3filter.RegisterBodyDecoder(common.MIMEApplicationMergePatchJSON, openapi3filter.RegisteredBodyDecoder(echo.MIMEApplicationJSON))
+}
+
+// ValidateRequests rejects the requests the operation they match in router
+// does not accept. An invalid parameter is a bad request, and so is a body
+// that cannot be decoded, while one of a media type the operation does not
+// take is unsupported; a body breaking the schema is a validation error
+// listing the offending fields. Security requirements are left to
+// JWTMiddleware, which must run first so that unauthenticated requests are
+// rejected as such; the router installs this after it on every route.
+// Requests matching no operation pass through.
+func ValidateRequests(router routers.Router) echo.MiddlewareFunc {
+	options := &openapi3filter.Options{
+		MultiError:          true,
+		AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+		SkipSettingDefaults: true,
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			route, params, err := router.FindRoute(c.Request())
+
+			if err != nil {
+				return next(c)
+			}
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    c.Request(),
+				PathParams: params,
+				Route:      route,
+				Options:    options,
+			}
+
+			if err := openapi3filter.ValidateRequest(c.Request().Context(), input); err != nil {
+				return requestError(err)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func requestError(err error) error {
+	var fields []domain.FieldError
+
+	for _, err := range unwrapMulti(err) {
+		var requestErr *openapi3filter.RequestError
+
+		if !errors.As(err, &requestErr) {
+			return echo.NewHTTPError(http.StatusBadRequest, "bad request").SetInternal(err)
+		}
+
+		if requestErr.Parameter != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid "+requestErr.Parameter.Name).SetInternal(err)
+		}
+
+		if requestErr.RequestBody != nil && strings.HasPrefix(requestErr.Reason, "header Content-Type has unexpected value") {
+			return echo.NewHTTPError(http.StatusUnsupportedMediaType, "unsupported media type").SetInternal(err)
+		}
+
+		var schemaErr *openapi3.SchemaError
+
+		if !errors.As(requestErr.Err, &schemaErr) {
+			return echo.NewHTTPError(http.StatusBadRequest, "binding failed").SetInternal(err)
+		}
+
+		for _, err := range unwrapMulti(requestErr.Err) {
+			if errors.As(err, &schemaErr) {
+				fields = append(fields, fieldError(schemaErr))
+			}
+		}
+	}
+
+	return domain.Invalid(fields...)
+}
+
+func unwrapMulti(err error) []error {
+	var multi openapi3.MultiError
+
+	if errors.As(err, &multi) {
+		return multi
+	}
+
+	return []error{err}
+}
+
+func fieldError(err *openapi3.SchemaError) domain.FieldError {
+	field := strings.Join(err.JSONPointer(), ".")
+
+	if err.SchemaField == "required" {
+		return domain.FieldError{Field: field, Code: "required", Message: field + " is required"}
+	}
+
+	return domain.FieldError{Field: field, Code: err.SchemaField, Message: field + ": " + err.Reason}
+}
+
+// ValidateResponses reports every response the operation its request
+// matches in router does not describe: an undocumented status, content type
+// or body. Problem details are checked against the Problem schema instead.
+// Errors returned by the handler are written here, so that their responses
+// are checked too. It is meant for tests, which report to t.Error.
+func ValidateResponses(router routers.Router, report func(error)) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			route, params, err := router.FindRoute(c.Request())
+
+			if err != nil {
+				return next(c)
+			}
+
+			recorder := &bodyRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+
+			request := c.Request()
+			header := c.Response().Header()
+			problem := strings.HasPrefix(header.Get(echo.HeaderContentType), common.MIMEApplicationProblemJSON)
+
+			input := &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: &openapi3filter.RequestValidationInput{
+					Request:    request,
+					PathParams: params,
+					Route:      route,
+				},
+				Status: c.Response().Status,
+				Header: header,
+				Options: &openapi3filter.Options{
+					IncludeResponseStatus: true,
+					ExcludeResponseBody:   problem,
+				},
+			}
+			input.SetBodyBytes(recorder.body.Bytes())
+
+			err = openapi3filter.ValidateResponse(request.Context(), input)
+
+			if err == nil && problem {
+				err = validateProblem(route.Spec, recorder.body.Bytes())
+			}
+
+			if err != nil {
+				report(fmt.Errorf("%s %s: %d response does not match the spec: %w", request.Method, request.URL.Path, c.Response().Status, err))
+			}
+
+			return nil
+		}
+	}
+}
+
+func validateProblem(doc *openapi3.T, body []byte) error {
+	schema := doc.Components.Schemas["Problem"]
+
+	if schema == nil {
+		return fmt.Errorf("the spec has no Problem schema")
+	}
+
+	var value interface{}
+
+	if err := json.Unmarshal(body, &value); err != nil {
+		return err
+	}
+
+	return schema.Value.VisitJSON(value)
+}
+
+// bodyRecorder keeps a copy of the body written through it.
+type bodyRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+
+	return r.ResponseWriter.Write(b)
+}
@@ -1,43 +1,158 @@
 package router
 
 import (
+	"fmt"
+	"rest-api/design-pattern/delivery/controller/audit"
 	"rest-api/design-pattern/delivery/controller/auth"
 	"rest-api/design-pattern/delivery/controller/book"
+	"rest-api/design-pattern/delivery/controller/docs"
 	"rest-api/design-pattern/delivery/controller/product"
 	"rest-api/design-pattern/delivery/controller/user"
 	"rest-api/design-pattern/delivery/midware"
+	"rest-api/design-pattern/domain"
+	"sort"
 
 	"github.com/labstack/echo/v4"
 )
 
+// DefaultVersion is the API version served at unversioned paths too, so
+// that /books is an alias of /v1/books.
+const DefaultVersion = "v1"
+
+// Deprecations maps routes, written as "GET /v1/books", to their
+// deprecation.
+type Deprecations map[string]midware.Deprecation
+
+// RegisterPath mounts every version of the API under its own prefix and the
+// default version at the root as well. Well-known URIs and the docs are
+// served at the root only. Routes changing resources check the permission of
+// the caller before their handler runs, and validate, when not nil, checks
+// every request of the API after that, so that requests not allowed are
+// turned away as such whatever their body. It fails if deprecations names a
+// route that does not exist.
 func RegisterPath(e *echo.Echo,
 	authController *auth.AuthController,
 	bookController *book.BookController,
 	userController *user.UserController,
 	productController *product.ProductController,
-) {
+	auditController *audit.AuditController,
+	docsController *docs.DocsController,
+	deprecations Deprecations,
+	validate echo.MiddlewareFunc,
+) error {
+
+	// Docs
+	e.GET("/openapi.yaml", docsController.Spec())
+	e.GET("/docs", docsController.UI())
+	e.GET("/docs/swagger-ui/*", docsController.Assets())
+
+	e.GET("/.well-known/jwks.json", authController.JWKS())
+
+	v1 := newVersion(e, "v1", deprecations, validate)
 
 	// Login
-	e.POST("/login", authController.Login())
+	v1.add(echo.POST, "/login", authController.Login())
+	v1.add(echo.POST, "/auth/refresh", authController.Refresh())
+	v1.add(echo.POST, "/auth/logout", authController.Logout(), midware.JWTMiddleware())
+	v1.add(echo.POST, "/auth/logout-all", authController.LogoutAll(), midware.JWTMiddleware())
 
 	// User
-	e.GET("/users", userController.GetAll(), midware.JWTMiddleware())
-	e.GET("/users/:id", userController.Get(), midware.JWTMiddleware())
-	e.POST("/users", userController.Create())
-	e.PUT("/users/:id", userController.Update(), midware.JWTMiddleware())
-	e.DELETE("/users/:id", userController.Delete(), midware.JWTMiddleware())
+	v1.add(echo.GET, "/users", userController.GetAll(), midware.JWTMiddleware())
+	v1.add(echo.GET, "/users/:id", userController.Get(), midware.JWTMiddleware())
+	v1.add(echo.POST, "/users", userController.Create())
+	v1.add(echo.PUT, "/users/:id", userController.Update(), midware.JWTMiddleware(), midware.AuthorizeOwner())
+	v1.add(echo.PATCH, "/users/:id", userController.Patch(), midware.JWTMiddleware(), midware.AuthorizeOwner())
+	v1.add(echo.DELETE, "/users/:id", userController.Delete(), midware.JWTMiddleware(), midware.AuthorizeOwner())
+	v1.add(echo.POST, "/users/:id/restore", userController.Restore(), midware.JWTMiddleware(), midware.Authorize(domain.RestoreDeleted))
+	v1.add(echo.PUT, "/users/:id/role", userController.SetRole(), midware.JWTMiddleware(), midware.Authorize(domain.AssignUserRole))
 
 	// Book
-	e.GET("/books", bookController.GetAll())
-	e.GET("/books/:id", bookController.Get())
-	e.POST("/books", bookController.Create(), midware.JWTMiddleware())
-	e.PUT("/books/:id", bookController.Update(), midware.JWTMiddleware())
-	e.DELETE("/books/:id", bookController.Delete(), midware.JWTMiddleware())
+	v1.add(echo.GET, "/books", bookController.GetAll(), midware.OptionalJWTMiddleware())
+	v1.add(echo.GET, "/books/search", bookController.Search())
+	v1.add(echo.GET, "/books/:id", bookController.Get())
+	v1.add(echo.POST, "/books", bookController.Create(), midware.JWTMiddleware(), midware.Authorize(domain.CreateBook))
+	v1.add(echo.PUT, "/books/:id", bookController.Update(), midware.JWTMiddleware(), midware.Authorize(domain.UpdateBook))
+	v1.add(echo.PATCH, "/books/:id", bookController.Patch(), midware.JWTMiddleware(), midware.Authorize(domain.UpdateBook))
+	v1.add(echo.DELETE, "/books/:id", bookController.Delete(), midware.JWTMiddleware(), midware.Authorize(domain.DeleteBook))
+	v1.add(echo.POST, "/books/:id/restore", bookController.Restore(), midware.JWTMiddleware(), midware.Authorize(domain.RestoreDeleted))
 
 	// Product
-	e.GET("/products", productController.GetAll())
-	e.GET("/products/:id", productController.Get())
-	e.POST("/products", productController.Create(), midware.JWTMiddleware())
-	e.PUT("/products/:id", productController.Update(), midware.JWTMiddleware())
-	e.DELETE("/products/:id", productController.Delete(), midware.JWTMiddleware())
+	v1.add(echo.GET, "/products", productController.GetAll(), midware.OptionalJWTMiddleware())
+	v1.add(echo.GET, "/products/:id", productController.Get())
+	v1.add(echo.POST, "/products", productController.Create(), midware.JWTMiddleware(), midware.Authorize(domain.CreateProduct))
+	v1.add(echo.PUT, "/products/:id", productController.Update(), midware.JWTMiddleware(), midware.Authorize(domain.UpdateProduct))
+	v1.add(echo.PATCH, "/products/:id", productController.Patch(), midware.JWTMiddleware(), midware.Authorize(domain.UpdateProduct))
+	v1.add(echo.DELETE, "/products/:id", productController.Delete(), midware.JWTMiddleware(), midware.Authorize(domain.DeleteProduct))
+	v1.add(echo.POST, "/products/:id/restore", productController.Restore(), midware.JWTMiddleware(), midware.Authorize(domain.RestoreDeleted))
+
+	// Audit
+	v1.add(echo.GET, "/audit", auditController.GetAll(), midware.JWTMiddleware(), midware.Authorize(domain.ReadAudit))
+
+	return unknownRoutes(deprecations, v1)
+}
+
+// version registers the routes of an API version under its prefix and, for
+// the default version, at the root.
+type version struct {
+	name         string
+	groups       []*echo.Group
+	deprecations Deprecations
+	validate     echo.MiddlewareFunc
+	routes       map[string]bool
+}
+
+func newVersion(e *echo.Echo, name string, deprecations Deprecations, validate echo.MiddlewareFunc) *version {
+	v := &version{
+		name:         name,
+		groups:       []*echo.Group{e.Group("/" + name)},
+		deprecations: deprecations,
+		validate:     validate,
+		routes:       map[string]bool{},
+	}
+
+	if name == DefaultVersion {
+		v.groups = append(v.groups, e.Group(""))
+	}
+
+	return v
+}
+
+func (v *version) add(method string, path string, handler echo.HandlerFunc, middleware ...echo.MiddlewareFunc) {
+	route := fmt.Sprintf("%s /%s%s", method, v.name, path)
+	v.routes[route] = true
+
+	if deprecation, ok := v.deprecations[route]; ok {
+		middleware = append([]echo.MiddlewareFunc{midware.Deprecated(deprecation)}, middleware...)
+	}
+
+	if v.validate != nil {
+		middleware = append(middleware, v.validate)
+	}
+
+	for _, group := range v.groups {
+		group.Add(method, path, handler, middleware...)
+	}
+}
+
+func unknownRoutes(deprecations Deprecations, versions ...*version) error {
+	var unknown []string
+
+	for route := range deprecations {
+		found := false
+
+		for _, v := range versions {
+			found = found || v.routes[route]
+		}
+
+		if !found {
+			unknown = append(unknown, route)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("deprecation of unknown routes %v", unknown)
+	}
+
+	return nil
 }
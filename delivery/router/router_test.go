@@ -0,0 +1,587 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"rest-api/design-pattern/api"
+	"rest-api/design-pattern/delivery/common"
+	"rest-api/design-pattern/delivery/controller/audit"
+	"rest-api/design-pattern/delivery/controller/auth"
+	"rest-api/design-pattern/delivery/controller/book"
+	"rest-api/design-pattern/delivery/controller/docs"
+	"rest-api/design-pattern/delivery/controller/product"
+	"rest-api/design-pattern/delivery/controller/user"
+	"rest-api/design-pattern/delivery/midware"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/entity"
+	"rest-api/design-pattern/util/query"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockAuthService struct{}
+
+func (m mockAuthService) Login(context.Context, string, string) (entity.Tokens, error) {
+	return entity.Tokens{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer", ExpiresIn: 3600}, nil
+}
+
+func (m mockAuthService) Refresh(context.Context, string) (entity.Tokens, error) {
+	return entity.Tokens{}, domain.Unauthorized("invalid refresh token")
+}
+
+func (m mockAuthService) Logout(context.Context, int, string, time.Time, string) error {
+	return nil
+}
+
+func (m mockAuthService) LogoutAll(context.Context, int, string, time.Time) error {
+	return nil
+}
+
+func (m mockAuthService) IsRevoked(context.Context, string, int, time.Time) (bool, error) {
+	return false, nil
+}
+
+var (
+	book1    = entity.Book{Id: 1, Title: "title1", Author: "author1", Publisher: "publisher1", Language: "language1", Pages: 100, ISBN13: "9780134190440", Version: 1}
+	product1 = entity.Product{Id: 1, UserID: 1, Name: "product1", Price: 100, Merchant: "user1", Version: 1}
+	user1    = entity.User{Id: 1, Name: "user1", Email: "user1@mail.com", Password: "Passw0rd", Role: entity.RoleCustomer, Version: 1}
+	audit1   = entity.Audit{Id: 1, ActorID: &user1.Id, Action: entity.AuditDelete, Resource: entity.AuditProduct, ResourceID: 1, Before: []byte(`{"id":1,"merchant":"user1","name":"product1","price":100}`), RequestID: "request1"}
+)
+
+type mockBookService struct{}
+
+func (m mockBookService) GetAll(ctx context.Context, actor domain.Actor, opts query.Options) ([]entity.Book, query.Page, error) {
+	page := opts.NewPage()
+	page.Total = 1
+
+	return []entity.Book{book1}, page, nil
+}
+
+func (m mockBookService) Get(ctx context.Context, id int) (entity.Book, error) {
+	if id != book1.Id {
+		return entity.Book{}, domain.NotFound("book does not exist")
+	}
+
+	return book1, nil
+}
+
+func (m mockBookService) Create(ctx context.Context, actor domain.Actor, book entity.Book) (entity.Book, error) {
+	book.Id = book1.Id
+
+	return book, nil
+}
+
+func (m mockBookService) Update(ctx context.Context, actor domain.Actor, book entity.Book) (entity.Book, error) {
+	return book, nil
+}
+
+func (m mockBookService) Patch(ctx context.Context, actor domain.Actor, book entity.Book, fields []string) (entity.Book, error) {
+	return book1, nil
+}
+
+func (m mockBookService) Delete(context.Context, domain.Actor, int, int) error {
+	return domain.Forbidden("forbidden")
+}
+
+func (m mockBookService) Restore(context.Context, domain.Actor, int) (entity.Book, error) {
+	return book1, nil
+}
+
+func (m mockBookService) Search(ctx context.Context, q string, opts query.Options) ([]entity.BookMatch, query.Page, error) {
+	page := opts.NewPage()
+	page.Total = 1
+
+	return []entity.BookMatch{{Book: book1, Score: 1, Highlights: map[string]string{"title": "<em>title</em>1"}}}, page, nil
+}
+
+type mockProductService struct{}
+
+func (m mockProductService) GetAll(ctx context.Context, actor domain.Actor, opts query.Options) ([]entity.Product, query.Page, error) {
+	return nil, opts.NewPage(), nil
+}
+
+func (m mockProductService) Get(context.Context, int) (entity.Product, error) {
+	return product1, nil
+}
+
+func (m mockProductService) Create(ctx context.Context, actor domain.Actor, product entity.Product) (entity.Product, error) {
+	return product1, nil
+}
+
+func (m mockProductService) Update(ctx context.Context, actor domain.Actor, product entity.Product) (entity.Product, error) {
+	return product1, nil
+}
+
+func (m mockProductService) Patch(ctx context.Context, actor domain.Actor, product entity.Product, fields []string) (entity.Product, error) {
+	return product1, nil
+}
+
+func (m mockProductService) Delete(context.Context, domain.Actor, int, int) error {
+	return nil
+}
+
+func (m mockProductService) Restore(context.Context, domain.Actor, int) (entity.Product, error) {
+	return product1, nil
+}
+
+type mockUserService struct{}
+
+func (m mockUserService) GetAll(ctx context.Context, actor domain.Actor, opts query.Options) ([]entity.User, query.Page, error) {
+	page := opts.NewPage()
+	page.Total = 1
+
+	return []entity.User{user1}, page, nil
+}
+
+func (m mockUserService) Get(context.Context, int) (entity.User, error) {
+	return user1, nil
+}
+
+func (m mockUserService) Register(ctx context.Context, user entity.User) (entity.User, error) {
+	return user1, nil
+}
+
+func (m mockUserService) Update(ctx context.Context, actor domain.Actor, user entity.User) (entity.User, error) {
+	return user1, nil
+}
+
+func (m mockUserService) Patch(ctx context.Context, actor domain.Actor, user entity.User, fields []string) (entity.User, error) {
+	return user1, nil
+}
+
+func (m mockUserService) Delete(context.Context, domain.Actor, int, int) error {
+	return nil
+}
+
+func (m mockUserService) Restore(context.Context, domain.Actor, int) (entity.User, error) {
+	return user1, nil
+}
+
+func (m mockUserService) SetRole(context.Context, domain.Actor, int, string) error {
+	return nil
+}
+
+type mockAuditService struct{}
+
+func (m mockAuditService) GetAll(ctx context.Context, actor domain.Actor, opts query.Options) ([]entity.Audit, query.Page, error) {
+	page := opts.NewPage()
+	page.Total = 1
+
+	return []entity.Audit{audit1}, page, nil
+}
+
+func registerPath(t *testing.T, e *echo.Echo, deprecations Deprecations, validate echo.MiddlewareFunc) {
+	err := RegisterPath(e,
+		auth.New(mockAuthService{}),
+		book.New(mockBookService{}),
+		user.New(mockUserService{}),
+		product.New(mockProductService{}),
+		audit.New(mockAuditService{}),
+		docs.New(api.Spec),
+		deprecations,
+		validate,
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func serve(e *echo.Echo, method string, path string) *httptest.ResponseRecorder {
+	response := httptest.NewRecorder()
+	e.ServeHTTP(response, httptest.NewRequest(method, path, nil))
+
+	return response
+}
+
+// TEST SPEC
+
+// TestRoutesMatchSpec serves requests to every route, under /v1 and at its
+// unversioned alias, through the request validator and fails on any
+// response api.yaml does not describe. Bodies are sent as JSON, except those
+// of PATCH requests: JSON Patches when they are arrays, merge patches
+// otherwise.
+func TestRoutesMatchSpec(t *testing.T) {
+	spec, err := api.NewRouter()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, _ := midware.CreateToken(1, "user1", entity.RoleAdmin)
+
+	e := echo.New()
+	e.HTTPErrorHandler = common.HTTPErrorHandler
+	e.Use(midware.ValidateResponses(spec, func(err error) { t.Error(err) }))
+
+	registerPath(t, e, nil, midware.ValidateRequests(spec))
+
+	book := `{"title":"title1","author":"author1","publisher":"publisher1","language":"language1","pages":100,"isbn13":"9780134190440"}`
+
+	cases := []struct {
+		method, path, body string
+		auth               bool
+		accept             string
+		code               int
+	}{
+		{http.MethodPost, "/login", `{"name":"user1","password":"Passw0rd"}`, false, "", http.StatusOK},
+		{http.MethodPost, "/login", `{"name":"user1"}`, false, "", http.StatusUnprocessableEntity},
+		{http.MethodPost, "/auth/refresh", `{"refresh_token":"refresh"}`, false, "", http.StatusUnauthorized},
+		{http.MethodPost, "/auth/logout", `{"refresh_token":"refresh"}`, true, "", http.StatusOK},
+		{http.MethodPost, "/auth/logout-all", "", true, "", http.StatusOK},
+
+		{http.MethodGet, "/users", "", true, "", http.StatusOK},
+		{http.MethodGet, "/users", "", false, "", http.StatusUnauthorized},
+		{http.MethodPost, "/users", `{"name":"user1","email":"user1@mail.com","password":"Passw0rd"}`, false, "", http.StatusOK},
+		{http.MethodGet, "/users/1", "", true, "", http.StatusOK},
+		{http.MethodPut, "/users/1", `{"name":"user1","email":"user1@mail.com","password":"Passw0rd"}`, true, "", http.StatusOK},
+		{http.MethodDelete, "/users/1", "", true, "", http.StatusOK},
+		{http.MethodPatch, "/users/1", `{"email":"user1@mail.com"}`, true, "", http.StatusOK},
+		{http.MethodPatch, "/users/1", `{"role":"admin"}`, true, "", http.StatusUnprocessableEntity},
+		{http.MethodPatch, "/users/1", `[{"op":"add","path":"/password","value":"Passw0rd"}]`, true, "", http.StatusOK},
+		{http.MethodPatch, "/users/1", `[{"op":"replace","path":"/password","value":"Passw0rd"}]`, true, "", http.StatusConflict},
+		{http.MethodPut, "/users/1/role", `{"role":"merchant"}`, true, "", http.StatusOK},
+		{http.MethodPost, "/users/1/restore", "", true, "", http.StatusOK},
+
+		{http.MethodGet, "/books", "", false, "", http.StatusOK},
+		{http.MethodGet, "/books?limit=0", "", false, "", http.StatusBadRequest},
+		{http.MethodGet, "/books?include_deleted=true", "", true, "", http.StatusOK},
+		{http.MethodGet, "/books?include_deleted=true", "", false, "", http.StatusUnauthorized},
+		{http.MethodGet, "/books?include_deleted=maybe", "", false, "", http.StatusBadRequest},
+		{http.MethodGet, "/books?updated_since=2022-06-01T00:00:00Z&updated_until=2022-07-01T00:00:00Z", "", false, "", http.StatusOK},
+		{http.MethodGet, "/books?updated_since=yesterday", "", false, "", http.StatusBadRequest},
+		{http.MethodGet, "/books/search?q=title", "", false, "", http.StatusOK},
+		{http.MethodGet, "/books/1", "", false, "", http.StatusOK},
+		{http.MethodGet, "/books/2", "", false, "", http.StatusNotFound},
+		{http.MethodGet, "/books/abc", "", false, "", http.StatusBadRequest},
+		{http.MethodGet, "/books/abc", "", false, common.MIMEApplicationProblemJSON, http.StatusBadRequest},
+		{http.MethodPost, "/books", book, true, "", http.StatusOK},
+		{http.MethodPost, "/books", `{"title":"title1"}`, true, "", http.StatusUnprocessableEntity},
+		{http.MethodPost, "/books", `{"title":"title1"}`, true, common.MIMEApplicationProblemJSON, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/books", `{`, true, "", http.StatusBadRequest},
+		{http.MethodPost, "/books", `{"title":"title1"}`, false, "", http.StatusUnauthorized},
+		{http.MethodPost, "/books", `{`, false, "", http.StatusUnauthorized},
+		{http.MethodPatch, "/products/1", `{"price":"free"}`, false, "", http.StatusUnauthorized},
+		{http.MethodPut, "/books/1", book, true, "", http.StatusOK},
+		{http.MethodPatch, "/books/1", `{"pages":120}`, true, "", http.StatusOK},
+		{http.MethodPatch, "/books/1", `{"pages":null}`, true, "", http.StatusUnprocessableEntity},
+		{http.MethodPatch, "/books/1", `[{"op":"test","path":"/pages","value":100},{"op":"replace","path":"/pages","value":120}]`, true, "", http.StatusOK},
+		{http.MethodPatch, "/books/1", `[{"op":"test","path":"/pages","value":99}]`, true, "", http.StatusConflict},
+		{http.MethodPatch, "/books/1", `[{"op":"jump","path":"/pages"}]`, true, "", http.StatusUnprocessableEntity},
+		{http.MethodPatch, "/books/2", `{"pages":120}`, true, "", http.StatusNotFound},
+		{http.MethodPatch, "/books/1", `{"pages":120}`, false, "", http.StatusUnauthorized},
+		{http.MethodDelete, "/books/1", "", true, "", http.StatusForbidden},
+		{http.MethodPost, "/books/1/restore", "", true, "", http.StatusOK},
+		{http.MethodPost, "/books/1/restore", "", false, "", http.StatusUnauthorized},
+
+		{http.MethodGet, "/products", "", false, "", http.StatusOK},
+		{http.MethodGet, "/products/1", "", false, "", http.StatusOK},
+		{http.MethodPost, "/products", `{"name":"product1","price":100}`, true, "", http.StatusOK},
+		{http.MethodPut, "/products/1", `{"name":"product1","price":100}`, true, "", http.StatusOK},
+		{http.MethodPatch, "/products/1", `{"price":120}`, true, "", http.StatusOK},
+		{http.MethodDelete, "/products/1", "", true, "", http.StatusOK},
+		{http.MethodDelete, "/products/1", "", false, "", http.StatusUnauthorized},
+		{http.MethodGet, "/products?include_deleted=true", "", true, "", http.StatusOK},
+		{http.MethodGet, "/products?updated_until=2022-07-01T00:00:00Z", "", false, "", http.StatusOK},
+		{http.MethodPost, "/products/1/restore", "", true, "", http.StatusOK},
+
+		{http.MethodGet, "/audit", "", true, "", http.StatusOK},
+		{http.MethodGet, "/audit?resource=product&resource_id=1&since=2022-06-01T00:00:00Z", "", true, "", http.StatusOK},
+		{http.MethodGet, "/audit?resource_id=abc", "", true, "", http.StatusBadRequest},
+		{http.MethodGet, "/audit", "", false, "", http.StatusUnauthorized},
+	}
+
+	for _, prefix := range []string{"/v1", ""} {
+		for _, tc := range cases {
+			path := prefix + tc.path
+
+			t.Run(fmt.Sprintf("%s %s %d", tc.method, path, tc.code), func(t *testing.T) {
+				var request *http.Request
+
+				if tc.body == "" {
+					request = httptest.NewRequest(tc.method, path, nil)
+				} else {
+					request = httptest.NewRequest(tc.method, path, strings.NewReader(tc.body))
+					request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+				}
+
+				if tc.method == http.MethodPatch && strings.HasPrefix(tc.body, "[") {
+					request.Header.Set(echo.HeaderContentType, common.MIMEApplicationJSONPatchJSON)
+				} else if tc.method == http.MethodPatch {
+					request.Header.Set(echo.HeaderContentType, common.MIMEApplicationMergePatchJSON)
+				}
+
+				if tc.auth {
+					request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+				}
+
+				if tc.accept != "" {
+					request.Header.Set(echo.HeaderAccept, tc.accept)
+				}
+
+				response := httptest.NewRecorder()
+				e.ServeHTTP(response, request)
+
+				assert.Equal(t, tc.code, response.Code, response.Body.String())
+			})
+		}
+	}
+
+	t.Run("PATCH /books/1 415", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPatch, "/books/1", strings.NewReader(`{"pages":120}`))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+
+		response := httptest.NewRecorder()
+		e.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, response.Code, response.Body.String())
+	})
+
+	t.Run("GET /.well-known/jwks.json 200", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/.well-known/jwks.json").Code)
+	})
+}
+
+// TEST CONDITIONAL REQUESTS
+
+// TestConditionalRequests serves requests carrying If-None-Match or If-Match
+// through the validators, against resources at version 1.
+func TestConditionalRequests(t *testing.T) {
+	spec, err := api.NewRouter()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, _ := midware.CreateToken(1, "user1", entity.RoleAdmin)
+
+	e := echo.New()
+	e.HTTPErrorHandler = common.HTTPErrorHandler
+	e.Use(midware.ValidateResponses(spec, func(err error) { t.Error(err) }))
+
+	registerPath(t, e, nil, midware.ValidateRequests(spec))
+
+	book := `{"title":"title1","author":"author1","publisher":"publisher1","language":"language1","pages":100,"isbn13":"9780134190440"}`
+
+	cases := []struct {
+		method, path, body string
+		header, value      string
+		required           bool
+		code               int
+		etag               string
+	}{
+		{http.MethodGet, "/books/1", "", "", "", false, http.StatusOK, `"1"`},
+		{http.MethodGet, "/books/1", "", common.HeaderIfNoneMatch, `"1"`, false, http.StatusNotModified, `"1"`},
+		{http.MethodGet, "/users/1", "", common.HeaderIfNoneMatch, `W/"0", W/"1"`, false, http.StatusNotModified, `"1"`},
+		{http.MethodGet, "/products/1", "", common.HeaderIfNoneMatch, `"0"`, false, http.StatusOK, `"1"`},
+		{http.MethodPut, "/books/1", book, common.HeaderIfMatch, `"1"`, false, http.StatusOK, `"1"`},
+		{http.MethodPut, "/books/1", book, common.HeaderIfMatch, `"0"`, false, http.StatusPreconditionFailed, ""},
+		{http.MethodPut, "/books/1", book, common.HeaderIfMatch, `W/"1"`, false, http.StatusPreconditionFailed, ""},
+		{http.MethodPut, "/books/1", book, "", "", true, http.StatusPreconditionRequired, ""},
+		{http.MethodPatch, "/products/1", `{"price":120}`, common.HeaderIfMatch, "*", true, http.StatusOK, `"1"`},
+		{http.MethodPatch, "/products/1", `{"price":120}`, common.HeaderIfMatch, `"0"`, false, http.StatusPreconditionFailed, ""},
+		{http.MethodDelete, "/users/1", "", common.HeaderIfMatch, `"0"`, false, http.StatusPreconditionFailed, ""},
+		{http.MethodDelete, "/users/1", "", "", "", true, http.StatusPreconditionRequired, ""},
+		{http.MethodDelete, "/users/1", "", common.HeaderIfMatch, `"1"`, true, http.StatusOK, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("%s %s %s %s %d", tc.method, tc.path, tc.header, tc.value, tc.code), func(t *testing.T) {
+			common.SetRequireIfMatch(tc.required)
+			defer common.SetRequireIfMatch(false)
+
+			request := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+
+			if tc.method == http.MethodPatch {
+				request.Header.Set(echo.HeaderContentType, common.MIMEApplicationMergePatchJSON)
+			} else if tc.body != "" {
+				request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			}
+
+			if tc.header != "" {
+				request.Header.Set(tc.header, tc.value)
+			}
+
+			request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", token))
+
+			response := httptest.NewRecorder()
+			e.ServeHTTP(response, request)
+
+			assert.Equal(t, tc.code, response.Code, response.Body.String())
+			assert.Equal(t, tc.etag, response.Header().Get(common.HeaderETag))
+		})
+	}
+}
+
+// TEST PERMISSIONS
+
+// TestPermissions serves requests the role of their token does not allow,
+// each with an If-Match that would fail, and expects them turned away before
+// the resource is looked up.
+func TestPermissions(t *testing.T) {
+	spec, err := api.NewRouter()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	customer, _ := midware.CreateToken(2, "user2", entity.RoleCustomer)
+	merchant, _ := midware.CreateToken(2, "user2", entity.RoleMerchant)
+
+	e := echo.New()
+	e.HTTPErrorHandler = common.HTTPErrorHandler
+	e.Use(midware.ValidateResponses(spec, func(err error) { t.Error(err) }))
+
+	registerPath(t, e, nil, midware.ValidateRequests(spec))
+
+	book := `{"title":"title1","author":"author1","publisher":"publisher1","language":"language1","pages":100,"isbn13":"9780134190440"}`
+	product := `{"name":"product1","price":100}`
+
+	cases := []struct {
+		method, path, body, token string
+		code                      int
+	}{
+		{http.MethodPost, "/books", book, merchant, http.StatusForbidden},
+		{http.MethodPost, "/books", `{"title":"title1"}`, merchant, http.StatusForbidden},
+		{http.MethodPut, "/books/1", book, customer, http.StatusForbidden},
+		{http.MethodPatch, "/books/1", `{"pages":120}`, merchant, http.StatusForbidden},
+		{http.MethodDelete, "/books/1", "", customer, http.StatusForbidden},
+		{http.MethodPost, "/books/1/restore", "", merchant, http.StatusForbidden},
+		{http.MethodPost, "/products", product, customer, http.StatusForbidden},
+		{http.MethodPut, "/products/1", product, customer, http.StatusForbidden},
+		{http.MethodPatch, "/products/1", `{"price":120}`, customer, http.StatusForbidden},
+		{http.MethodDelete, "/products/1", "", customer, http.StatusForbidden},
+		{http.MethodPost, "/products/1/restore", "", merchant, http.StatusForbidden},
+		{http.MethodPut, "/users/1", `{"name":"user1","email":"user1@mail.com"}`, customer, http.StatusForbidden},
+		{http.MethodPatch, "/users/1", `{"name":"user1"}`, customer, http.StatusForbidden},
+		{http.MethodDelete, "/users/1", "", merchant, http.StatusForbidden},
+		{http.MethodPost, "/users/1/restore", "", customer, http.StatusForbidden},
+		{http.MethodPut, "/users/1/role", `{"role":"admin"}`, merchant, http.StatusForbidden},
+		{http.MethodGet, "/audit", "", merchant, http.StatusForbidden},
+		{http.MethodPatch, "/products/1", `{"price":120}`, merchant, http.StatusPreconditionFailed},
+		{http.MethodDelete, "/users/2", "", customer, http.StatusPreconditionFailed},
+	}
+
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("%s %s %d", tc.method, tc.path, tc.code), func(t *testing.T) {
+			request := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+
+			if tc.method == http.MethodPatch {
+				request.Header.Set(echo.HeaderContentType, common.MIMEApplicationMergePatchJSON)
+			} else if tc.body != "" {
+				request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			}
+
+			request.Header.Set(common.HeaderIfMatch, `"0"`)
+			request.Header.Set(echo.HeaderAuthorization, fmt.Sprintf("Bearer %v", tc.token))
+
+			response := httptest.NewRecorder()
+			e.ServeHTTP(response, request)
+
+			assert.Equal(t, tc.code, response.Code, response.Body.String())
+		})
+	}
+}
+
+// TEST VERSIONING
+
+func TestVersioning(t *testing.T) {
+	deprecations := Deprecations{
+		"GET /v1/books/:id": {
+			At:     time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+			Sunset: time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC),
+		},
+		"GET /v1/products": {
+			At: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	e := echo.New()
+	e.HTTPErrorHandler = common.HTTPErrorHandler
+	registerPath(t, e, deprecations, nil)
+
+	t.Run("TestDeprecatedRoute", func(t *testing.T) {
+		for _, path := range []string{"/v1/books/1", "/books/1", "/v1/books/2"} {
+			response := serve(e, http.MethodGet, path)
+
+			assert.Equal(t, "@1654041600", response.Header().Get("Deprecation"), path)
+			assert.Equal(t, "Thu, 01 Dec 2022 00:00:00 GMT", response.Header().Get("Sunset"), path)
+		}
+	})
+
+	t.Run("TestDeprecatedRouteWithoutSunset", func(t *testing.T) {
+		response := serve(e, http.MethodGet, "/v1/products")
+
+		assert.Equal(t, "@1654041600", response.Header().Get("Deprecation"))
+		assert.Empty(t, response.Header().Get("Sunset"))
+	})
+
+	t.Run("TestRouteNotDeprecated", func(t *testing.T) {
+		response := serve(e, http.MethodGet, "/v1/books")
+
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.Empty(t, response.Header().Get("Deprecation"))
+	})
+
+	t.Run("TestWellKnownNotVersioned", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/v1/.well-known/jwks.json").Code)
+	})
+
+	t.Run("TestUnknownVersion", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/v2/books").Code)
+	})
+
+	t.Run("TestDeprecationOfUnknownRoute", func(t *testing.T) {
+		err := RegisterPath(echo.New(),
+			auth.New(mockAuthService{}),
+			book.New(mockBookService{}),
+			user.New(mockUserService{}),
+			product.New(mockProductService{}),
+			audit.New(mockAuditService{}),
+			docs.New(api.Spec),
+			Deprecations{"GET /books": {At: time.Now()}},
+			nil,
+		)
+
+		assert.EqualError(t, err, "deprecation of unknown routes [GET /books]")
+	})
+}
+
+func TestDocs(t *testing.T) {
+	e := echo.New()
+
+	registerPath(t, e, nil, nil)
+
+	t.Run("TestSpec", func(t *testing.T) {
+		response := serve(e, http.MethodGet, "/openapi.yaml")
+
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.Equal(t, docs.MIMEApplicationYAML, response.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, api.Spec, response.Body.Bytes())
+	})
+
+	t.Run("TestUI", func(t *testing.T) {
+		response := serve(e, http.MethodGet, "/docs")
+
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.Contains(t, response.Body.String(), `url: "/openapi.yaml"`)
+		assert.NotContains(t, response.Body.String(), "https://")
+	})
+
+	t.Run("TestUIAssets", func(t *testing.T) {
+		response := serve(e, http.MethodGet, "/docs/swagger-ui/README.md")
+
+		assert.Equal(t, http.StatusOK, response.Code)
+
+		response = serve(e, http.MethodGet, "/docs/swagger-ui/../index.html")
+
+		assert.Equal(t, http.StatusNotFound, response.Code)
+	})
+}
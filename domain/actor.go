@@ -0,0 +1,60 @@
+package domain
+
+import "rest-api/design-pattern/entity"
+
+type Permission string
+
+const (
+	CreateBook     Permission = "books:create"
+	UpdateBook     Permission = "books:update"
+	DeleteBook     Permission = "books:delete"
+	CreateProduct  Permission = "products:create"
+	UpdateProduct  Permission = "products:update"
+	DeleteProduct  Permission = "products:delete"
+	AssignUserRole Permission = "users:assign-role"
+	ListDeleted    Permission = "deleted:list"
+	RestoreDeleted Permission = "deleted:restore"
+	ReadAudit      Permission = "audit:read"
+)
+
+// rolePermissions grants permissions to roles. Admins hold every permission
+// and are not listed; customers hold none beyond being authenticated.
+var rolePermissions = map[string][]Permission{
+	entity.RoleMerchant: {CreateProduct, UpdateProduct, DeleteProduct},
+}
+
+// Actor is the authenticated user a service acts on behalf of.
+type Actor struct {
+	Id   int
+	Role string
+}
+
+// System is the actor of changes made from the command line rather than
+// through the API, such as making the first admin. It is no user, so holds
+// every permission and is recorded as no one.
+var System = Actor{Role: entity.RoleAdmin}
+
+func (a Actor) IsAdmin() bool {
+	return a.Role == entity.RoleAdmin
+}
+
+// Can reports whether the role of the actor grants the permission.
+func (a Actor) Can(permission Permission) bool {
+	if a.IsAdmin() {
+		return true
+	}
+
+	for _, granted := range rolePermissions[a.Role] {
+		if granted == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsOwnerOrAdmin reports whether the actor is the user with the given id or
+// an administrator acting on their behalf.
+func (a Actor) IsOwnerOrAdmin(id int) bool {
+	return a.IsAdmin() || a.Id == id
+}
@@ -0,0 +1,71 @@
+// Package domain holds the errors repositories report, independent of the
+// transport serving them. The delivery layer maps each kind to a status.
+package domain
+
+import "errors"
+
+// The kinds of domain errors, matched with errors.Is.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrForbidden    = errors.New("forbidden")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrPreconditionFailed reports a change made to another version of a
+	// resource than its current one.
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// Error is a domain error of a kind with a message meant for the client.
+// Validation errors may list the offending fields.
+type Error struct {
+	Kind    error
+	Message string
+	Fields  []FieldError
+}
+
+// FieldError describes why the value of one field was rejected, by a
+// machine-readable code and a message meant for the client.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
+
+func NotFound(message string) error {
+	return &Error{Kind: ErrNotFound, Message: message}
+}
+
+func Conflict(message string) error {
+	return &Error{Kind: ErrConflict, Message: message}
+}
+
+func Forbidden(message string) error {
+	return &Error{Kind: ErrForbidden, Message: message}
+}
+
+func Validation(message string) error {
+	return &Error{Kind: ErrValidation, Message: message}
+}
+
+// Invalid returns a validation error listing every rejected field.
+func Invalid(fields ...FieldError) error {
+	return &Error{Kind: ErrValidation, Message: "validation failed", Fields: fields}
+}
+
+func Unauthorized(message string) error {
+	return &Error{Kind: ErrUnauthorized, Message: message}
+}
+
+func PreconditionFailed(message string) error {
+	return &Error{Kind: ErrPreconditionFailed, Message: message}
+}
@@ -0,0 +1,39 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The actions an audit record can be of.
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditPatch   = "patch"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditSetRole = "set_role"
+)
+
+// The resources changes to which are audited.
+const (
+	AuditBook    = "book"
+	AuditProduct = "product"
+	AuditUser    = "user"
+)
+
+// Audit is the record of a change made to a resource through the API. Before
+// and After are the resource as served before and after the change, nil when
+// it did not exist or was deleted. ActorID is nil for anonymous requests,
+// such as users registering themselves.
+type Audit struct {
+	Id         int
+	ActorID    *int
+	Action     string
+	Resource   string
+	ResourceID int
+	Before     json.RawMessage
+	After      json.RawMessage
+	RequestID  string
+	CreatedAt  time.Time
+}
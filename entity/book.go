@@ -1,11 +1,36 @@
 package entity
 
+import "time"
+
 type Book struct {
 	Id        int    //`json:"id" form:"id"`
-	Title     string `json:"title" form:"title"`
-	Author    string `json:"author" form:"author"`
-	Publisher string `json:"publisher" form:"publisher"`
-	Language  string `json:"language" form:"language"`
-	Pages     int    `json:"pages" form:"pages"`
-	ISBN13    string `json:"isbn13" form:"isbn13"`
+	Title     string `json:"title" form:"title" validate:"required,max=255"`
+	Author    string `json:"author" form:"author" validate:"required,max=255"`
+	Publisher string `json:"publisher" form:"publisher" validate:"required,max=255"`
+	Language  string `json:"language" form:"language" validate:"required,max=64"`
+	Pages     int    `json:"pages" form:"pages" validate:"gt=0"`
+	ISBN13    string `json:"isbn13" form:"isbn13" validate:"required,isbn13"`
+
+	// Version counts the changes to the book; a change applies only to the
+	// version it was made to.
+	Version int `json:"-" form:"-"`
+
+	// DeletedAt is when the book was deleted, nil while it is not. Deleted
+	// books are kept until purged and can be restored meanwhile.
+	DeletedAt *time.Time `json:"-" form:"-"`
+
+	// CreatedAt and UpdatedAt are when the book was created and last changed,
+	// CreatedBy and UpdatedBy the ids of the users who did, nil when unknown.
+	CreatedAt time.Time `json:"-" form:"-"`
+	UpdatedAt time.Time `json:"-" form:"-"`
+	CreatedBy *int      `json:"-" form:"-"`
+	UpdatedBy *int      `json:"-" form:"-"`
+}
+
+// BookMatch is a book matching a search, with its relevance and the matched
+// fragments of its title, author and publisher.
+type BookMatch struct {
+	Book
+	Score      float64
+	Highlights map[string]string
 }
@@ -1,8 +1,30 @@
 package entity
 
+import "time"
+
 type Product struct {
 	Id     int    //`json:"id" form:"id"`
 	UserID int    //`json:"userid" form:"userid"`
-	Name   string `json:"name" form:"name"`
-	Price  int    `json:"price" form:"price"`
+	Name   string `json:"name" form:"name" validate:"required,max=255"`
+	Price  int    `json:"price" form:"price" validate:"gt=0"`
+
+	// Merchant is the name of the user selling the product, read along with
+	// it and ignored when it is stored.
+	Merchant string `json:"-" form:"-"`
+
+	// Version counts the changes to the product; a change applies only to
+	// the version it was made to.
+	Version int `json:"-" form:"-"`
+
+	// DeletedAt is when the product was deleted, nil while it is not. Deleted
+	// products are kept until purged and can be restored meanwhile.
+	DeletedAt *time.Time `json:"-" form:"-"`
+
+	// CreatedAt and UpdatedAt are when the product was created and last
+	// changed, CreatedBy and UpdatedBy the ids of the users who did, nil when
+	// unknown.
+	CreatedAt time.Time `json:"-" form:"-"`
+	UpdatedAt time.Time `json:"-" form:"-"`
+	CreatedBy *int      `json:"-" form:"-"`
+	UpdatedBy *int      `json:"-" form:"-"`
 }
@@ -0,0 +1,23 @@
+package entity
+
+import "time"
+
+// Tokens are the credentials issued to a user on login, and replaced on
+// every refresh.
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	TokenType    string
+	ExpiresIn    int
+}
+
+// RefreshToken is a stored refresh token, kept by digest only. Tokens issued
+// from the same login share a family, revoked together on reuse.
+type RefreshToken struct {
+	Id        int
+	UserId    int
+	Family    string
+	Hash      string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
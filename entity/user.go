@@ -1,8 +1,41 @@
 package entity
 
+import "time"
+
+const (
+	RoleAdmin    = "admin"
+	RoleMerchant = "merchant"
+	RoleCustomer = "customer"
+)
+
 type User struct {
 	Id       int    //`json:"id" form:"id"`
-	Name     string `json:"name" form:"name"`
-	Email    string `json:"email" form:"email"`
-	Password string `json:"password" form:"password"`
+	Name     string `json:"name" form:"name" validate:"required,max=255"`
+	Email    string `json:"email" form:"email" validate:"required,email,max=255"`
+	Password string `json:"password" form:"password" validate:"required,password"`
+	Role     string `json:"role,omitempty" form:"role"`
+
+	// Version counts the changes to the user, its role included; a change
+	// applies only to the version it was made to.
+	Version int `json:"-" form:"-"`
+
+	// DeletedAt is when the user was deleted, nil while it is not. Deleted
+	// users are kept until purged and can be restored meanwhile.
+	DeletedAt *time.Time `json:"-" form:"-"`
+
+	// CreatedAt and UpdatedAt are when the user was created and last changed,
+	// CreatedBy and UpdatedBy the ids of the users who did, nil when unknown.
+	CreatedAt time.Time `json:"-" form:"-"`
+	UpdatedAt time.Time `json:"-" form:"-"`
+	CreatedBy *int      `json:"-" form:"-"`
+	UpdatedBy *int      `json:"-" form:"-"`
+}
+
+func ValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleMerchant, RoleCustomer:
+		return true
+	default:
+		return false
+	}
 }
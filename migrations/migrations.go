@@ -0,0 +1,327 @@
+// Package migrations versions the database schema. Every change is a pair of
+// SQL files, <version>_<name>.up.sql and <version>_<name>.down.sql, embedded
+// in the binary; applied versions are recorded in schema_migrations.
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+const (
+	queryCreateTable = "CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at DATETIME NOT NULL)"
+	queryApplied     = "SELECT version, applied_at FROM schema_migrations ORDER BY version"
+	queryInsert      = "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"
+	queryDelete      = "DELETE FROM schema_migrations WHERE version = ?"
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a known migration and when it was applied, nil if pending.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New returns a migrator applying the migrations embedded in the binary.
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := Load(files)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Load reads the migrations of the sql directory of fsys, sorted by version.
+// Each version must have both an up and a down script.
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "sql/*.sql")
+
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, name := range names {
+		base := path.Base(name)
+		parts := strings.SplitN(strings.TrimSuffix(base, ".sql"), "_", 2)
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("migration %v: expected <version>_<name>.up.sql or .down.sql", base)
+		}
+
+		version, err := strconv.Atoi(parts[0])
+
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %v: invalid version", base)
+		}
+
+		content, err := fs.ReadFile(fsys, name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		title, direction := parts[1], path.Ext(parts[1])
+		title = strings.TrimSuffix(title, direction)
+
+		m, ok := byVersion[version]
+
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+
+		if m.Name != title {
+			return nil, fmt.Errorf("migration %v: version %v is already used by %v", base, version, m.Name)
+		}
+
+		switch direction {
+		case ".up":
+			m.Up = string(content)
+		case ".down":
+			m.Down = string(content)
+		default:
+			return nil, fmt.Errorf("migration %v: expected <version>_<name>.up.sql or .down.sql", base)
+		}
+	}
+
+	migrations := []Migration{}
+
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%v: both up and down scripts are required", m.Version, m.Name)
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Latest returns the highest known version, 0 if there is none.
+func (mg *Migrator) Latest() int {
+	if len(mg.migrations) == 0 {
+		return 0
+	}
+
+	return mg.migrations[len(mg.migrations)-1].Version
+}
+
+// Status lists every known migration, oldest first.
+func (mg *Migrator) Status() ([]Status, error) {
+	applied, err := mg.applied()
+
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []Status{}
+
+	for _, m := range mg.migrations {
+		status := Status{Migration: m}
+
+		if at, ok := applied[m.Version]; ok {
+			at := at
+			status.AppliedAt = &at
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Up applies every pending migration and returns them.
+func (mg *Migrator) Up() ([]Migration, error) {
+	return mg.To(mg.Latest())
+}
+
+// Down reverts the most recently applied migration, if any.
+func (mg *Migrator) Down() ([]Migration, error) {
+	applied, err := mg.applied()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(mg.migrations) - 1; i >= 0; i-- {
+		m := mg.migrations[i]
+
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+
+		if err := mg.run(m, m.Down, queryDelete, m.Version); err != nil {
+			return nil, err
+		}
+
+		return []Migration{m}, nil
+	}
+
+	return []Migration{}, nil
+}
+
+// To applies the pending migrations up to and including version and reverts,
+// newest first, the applied ones above it. Version 0 reverts everything.
+func (mg *Migrator) To(version int) ([]Migration, error) {
+	if version != 0 && !mg.known(version) {
+		return nil, fmt.Errorf("unknown migration version %v", version)
+	}
+
+	applied, err := mg.applied()
+
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+
+	for i := len(mg.migrations) - 1; i >= 0; i-- {
+		m := mg.migrations[i]
+
+		if _, ok := applied[m.Version]; !ok || m.Version <= version {
+			continue
+		}
+
+		if err := mg.run(m, m.Down, queryDelete, m.Version); err != nil {
+			return done, err
+		}
+
+		done = append(done, m)
+	}
+
+	for _, m := range mg.migrations {
+		if _, ok := applied[m.Version]; ok || m.Version > version {
+			continue
+		}
+
+		if err := mg.run(m, m.Up, queryInsert, m.Version, m.Name, time.Now()); err != nil {
+			return done, err
+		}
+
+		done = append(done, m)
+	}
+
+	return done, nil
+}
+
+func (mg *Migrator) known(version int) bool {
+	for _, m := range mg.migrations {
+		if m.Version == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (mg *Migrator) applied() (map[int]time.Time, error) {
+	if _, err := mg.db.Exec(queryCreateTable); err != nil {
+		return nil, err
+	}
+
+	result, err := mg.db.Query(queryApplied)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer result.Close()
+
+	applied := map[int]time.Time{}
+
+	for result.Next() {
+		version, at := 0, time.Time{}
+
+		if err := result.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+
+		applied[version] = at
+	}
+
+	return applied, result.Err()
+}
+
+// run executes the statements of script and records the change in a single
+// transaction. MySQL commits DDL statements implicitly, so a script failing
+// halfway may leave the statements before the failing one applied; keeping
+// one change per migration keeps that recoverable by hand.
+func (mg *Migrator) run(m Migration, script string, record string, args ...interface{}) error {
+	tx, err := mg.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range statements(script) {
+		if _, err := tx.Exec(statement); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %04d_%v: %v", m.Version, m.Name, err)
+		}
+	}
+
+	if _, err := tx.Exec(record, args...); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("migration %04d_%v: %v", m.Version, m.Name, err)
+	}
+
+	return tx.Commit()
+}
+
+// statements splits a script on the semicolons ending its lines, as the
+// driver runs a single statement per call.
+func statements(script string) []string {
+	result := []string{}
+	current := []string{}
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current = append(current, line)
+
+		if strings.HasSuffix(trimmed, ";") {
+			statement := strings.TrimSuffix(strings.TrimSpace(strings.Join(current, "\n")), ";")
+			result = append(result, statement)
+			current = []string{}
+		}
+	}
+
+	if len(current) > 0 {
+		result = append(result, strings.TrimSpace(strings.Join(current, "\n")))
+	}
+
+	return result
+}
@@ -0,0 +1,184 @@
+package migrations
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	t.Run("TestLoadEmbedded", func(t *testing.T) {
+		migrations, err := Load(files)
+
+		assert.NoError(t, err)
+
+		names := []string{}
+
+		for i, m := range migrations {
+			assert.Equal(t, i+1, m.Version)
+			assert.NotEmpty(t, statements(m.Up))
+			assert.NotEmpty(t, statements(m.Down))
+			names = append(names, m.Name)
+		}
+
+		assert.Equal(t, []string{"create_users", "create_books", "create_products", "create_tokens", "add_versions", "add_deleted_at", "add_timestamps", "create_audit", "index_refresh_token_expiry", "unique_user_email", "add_user_tokens_revoked_at"}, names)
+	})
+}
+
+func TestLoadInvalid(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"migration 0001_users: both up and down scripts are required": {
+			"sql/0001_users.up.sql": {Data: []byte("CREATE TABLE users (id INT);")},
+		},
+		"migration x_users.up.sql: invalid version": {
+			"sql/x_users.up.sql": {Data: []byte("CREATE TABLE users (id INT);")},
+		},
+		"migration 0001_users.up.sql: version 1 is already used by books": {
+			"sql/0001_users.up.sql": {Data: []byte("CREATE TABLE users (id INT);")},
+			"sql/0001_books.up.sql": {Data: []byte("CREATE TABLE books (id INT);")},
+		},
+	}
+
+	for message, fsys := range cases {
+		t.Run(message, func(t *testing.T) {
+			_, err := Load(fsys)
+
+			assert.EqualError(t, err, message)
+		})
+	}
+}
+
+func TestStatements(t *testing.T) {
+	t.Run("TestStatements", func(t *testing.T) {
+		script := "-- two tables\nCREATE TABLE a (\n    id INT\n);\n\nCREATE TABLE b (id INT);\n"
+
+		assert.Equal(t, []string{"CREATE TABLE a (\n    id INT\n)", "CREATE TABLE b (id INT)"}, statements(script))
+	})
+}
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "create_a", Up: "CREATE TABLE a (id INT);", Down: "DROP TABLE a;"},
+	{Version: 2, Name: "create_b", Up: "CREATE TABLE b (id INT);", Down: "DROP TABLE b;"},
+	{Version: 3, Name: "create_c", Up: "CREATE TABLE c (id INT);", Down: "DROP TABLE c;"},
+}
+
+func expectApplied(mock sqlmock.Sqlmock, versions ...int) {
+	rows := sqlmock.NewRows([]string{"version", "applied_at"})
+
+	for _, version := range versions {
+		rows.AddRow(version, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	}
+
+	mock.ExpectExec(queryCreateTable).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(queryApplied).WillReturnRows(rows)
+}
+
+func TestUp(t *testing.T) {
+	t.Run("TestUp", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectApplied(mock, 1)
+
+		for _, m := range testMigrations[1:] {
+			mock.ExpectBegin()
+			mock.ExpectExec(statements(m.Up)[0]).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(queryInsert).WithArgs(m.Version, m.Name, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+		}
+
+		done, err := (&Migrator{db: db, migrations: testMigrations}).Up()
+
+		assert.NoError(t, err)
+		assert.Equal(t, testMigrations[1:], done)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("TestUpFail", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectApplied(mock, 1)
+
+		mock.ExpectBegin()
+		mock.ExpectExec("CREATE TABLE b (id INT)").WillReturnError(errors.New("table b exists"))
+		mock.ExpectRollback()
+
+		done, err := (&Migrator{db: db, migrations: testMigrations}).Up()
+
+		assert.EqualError(t, err, "migration 0002_create_b: table b exists")
+		assert.Empty(t, done)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDown(t *testing.T) {
+	t.Run("TestDown", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectApplied(mock, 1, 2)
+
+		mock.ExpectBegin()
+		mock.ExpectExec("DROP TABLE b").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(queryDelete).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		done, err := (&Migrator{db: db, migrations: testMigrations}).Down()
+
+		assert.NoError(t, err)
+		assert.Equal(t, testMigrations[1:2], done)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestTo(t *testing.T) {
+	t.Run("TestToZero", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectApplied(mock, 1, 2, 3)
+
+		for _, version := range []int{3, 2, 1} {
+			m := testMigrations[version-1]
+
+			mock.ExpectBegin()
+			mock.ExpectExec(statements(m.Down)[0]).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(queryDelete).WithArgs(version).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+		}
+
+		done, err := (&Migrator{db: db, migrations: testMigrations}).To(0)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Migration{testMigrations[2], testMigrations[1], testMigrations[0]}, done)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("TestToUnknown", func(t *testing.T) {
+		_, err := (&Migrator{migrations: testMigrations}).To(7)
+
+		assert.EqualError(t, err, "unknown migration version 7")
+	})
+}
+
+func TestStatus(t *testing.T) {
+	t.Run("TestStatus", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectApplied(mock, 1)
+
+		statuses, err := (&Migrator{db: db, migrations: testMigrations}).Status()
+
+		assert.NoError(t, err)
+		assert.Len(t, statuses, 3)
+		assert.NotNil(t, statuses[0].AppliedAt)
+		assert.Nil(t, statuses[1].AppliedAt)
+		assert.Nil(t, statuses[2].AppliedAt)
+	})
+}
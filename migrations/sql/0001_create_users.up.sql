@@ -0,0 +1,9 @@
+CREATE TABLE users (
+    id INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    role VARCHAR(16) NOT NULL DEFAULT 'customer',
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_users_name (name)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,13 @@
+CREATE TABLE books (
+    id INT NOT NULL AUTO_INCREMENT,
+    title VARCHAR(255) NOT NULL,
+    author VARCHAR(255) NOT NULL,
+    publisher VARCHAR(255) NOT NULL,
+    language VARCHAR(64) NOT NULL,
+    pages INT NOT NULL,
+    isbn13 VARCHAR(17) NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_books_author (author),
+    KEY idx_books_language (language),
+    FULLTEXT KEY ft_books (title, author, publisher)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,9 @@
+CREATE TABLE products (
+    id INT NOT NULL AUTO_INCREMENT,
+    user_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    price INT NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_products_price (price),
+    CONSTRAINT fk_products_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,5 @@
+ALTER TABLE products DROP COLUMN version;
+
+ALTER TABLE books DROP COLUMN version;
+
+ALTER TABLE users DROP COLUMN version;
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+ALTER TABLE books ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+ALTER TABLE products ADD COLUMN version INT NOT NULL DEFAULT 1;
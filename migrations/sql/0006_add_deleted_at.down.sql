@@ -0,0 +1,5 @@
+ALTER TABLE products DROP KEY idx_products_deleted_at, DROP COLUMN deleted_at;
+
+ALTER TABLE books DROP KEY idx_books_deleted_at, DROP COLUMN deleted_at;
+
+ALTER TABLE users DROP KEY idx_users_deleted_at, DROP COLUMN deleted_at;
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN deleted_at DATETIME NULL, ADD KEY idx_users_deleted_at (deleted_at);
+
+ALTER TABLE books ADD COLUMN deleted_at DATETIME NULL, ADD KEY idx_books_deleted_at (deleted_at);
+
+ALTER TABLE products ADD COLUMN deleted_at DATETIME NULL, ADD KEY idx_products_deleted_at (deleted_at);
@@ -0,0 +1,26 @@
+ALTER TABLE products
+    DROP FOREIGN KEY fk_products_created_by,
+    DROP FOREIGN KEY fk_products_updated_by,
+    DROP KEY idx_products_updated_at,
+    DROP COLUMN created_at,
+    DROP COLUMN updated_at,
+    DROP COLUMN created_by,
+    DROP COLUMN updated_by;
+
+ALTER TABLE books
+    DROP FOREIGN KEY fk_books_created_by,
+    DROP FOREIGN KEY fk_books_updated_by,
+    DROP KEY idx_books_updated_at,
+    DROP COLUMN created_at,
+    DROP COLUMN updated_at,
+    DROP COLUMN created_by,
+    DROP COLUMN updated_by;
+
+ALTER TABLE users
+    DROP FOREIGN KEY fk_users_created_by,
+    DROP FOREIGN KEY fk_users_updated_by,
+    DROP KEY idx_users_updated_at,
+    DROP COLUMN created_at,
+    DROP COLUMN updated_at,
+    DROP COLUMN created_by,
+    DROP COLUMN updated_by;
@@ -0,0 +1,26 @@
+ALTER TABLE users
+    ADD COLUMN created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN created_by INT NULL,
+    ADD COLUMN updated_by INT NULL,
+    ADD KEY idx_users_updated_at (updated_at),
+    ADD CONSTRAINT fk_users_created_by FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL,
+    ADD CONSTRAINT fk_users_updated_by FOREIGN KEY (updated_by) REFERENCES users (id) ON DELETE SET NULL;
+
+ALTER TABLE books
+    ADD COLUMN created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN created_by INT NULL,
+    ADD COLUMN updated_by INT NULL,
+    ADD KEY idx_books_updated_at (updated_at),
+    ADD CONSTRAINT fk_books_created_by FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL,
+    ADD CONSTRAINT fk_books_updated_by FOREIGN KEY (updated_by) REFERENCES users (id) ON DELETE SET NULL;
+
+ALTER TABLE products
+    ADD COLUMN created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN created_by INT NULL,
+    ADD COLUMN updated_by INT NULL,
+    ADD KEY idx_products_updated_at (updated_at),
+    ADD CONSTRAINT fk_products_created_by FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL,
+    ADD CONSTRAINT fk_products_updated_by FOREIGN KEY (updated_by) REFERENCES users (id) ON DELETE SET NULL;
@@ -0,0 +1,16 @@
+CREATE TABLE audit (
+    id INT NOT NULL AUTO_INCREMENT,
+    actor_id INT NULL,
+    action VARCHAR(16) NOT NULL,
+    resource VARCHAR(16) NOT NULL,
+    resource_id INT NOT NULL,
+    before_snapshot JSON NULL,
+    after_snapshot JSON NULL,
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    KEY idx_audit_actor_id (actor_id),
+    KEY idx_audit_resource (resource, resource_id),
+    KEY idx_audit_request_id (request_id),
+    KEY idx_audit_created_at (created_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
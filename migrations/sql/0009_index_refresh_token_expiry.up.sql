@@ -0,0 +1 @@
+ALTER TABLE refresh_tokens ADD KEY idx_refresh_tokens_expires (expires_at);
@@ -0,0 +1,10 @@
+-- Adding the key fails with a "Duplicate entry" error naming one email while
+-- two users, deleted ones included, share an email, and the migration is then
+-- not recorded. List the conflicting users with
+--
+--   SELECT email, GROUP_CONCAT(id ORDER BY id) AS ids
+--   FROM users GROUP BY email HAVING COUNT(*) > 1;
+--
+-- then give all but one user of each email another address, or purge the
+-- deleted ones, and migrate again.
+ALTER TABLE users ADD UNIQUE KEY uq_users_email (email);
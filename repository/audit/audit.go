@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"rest-api/design-pattern/entity"
+	"rest-api/design-pattern/util"
+	"rest-api/design-pattern/util/query"
+	"time"
+)
+
+const (
+	queryCount  = "SELECT COUNT(*) FROM audit"
+	queryGetAll = "SELECT id, actor_id, action, resource, resource_id, before_snapshot, after_snapshot, request_id, created_at FROM audit"
+	queryCreate = "INSERT INTO audit (actor_id, action, resource, resource_id, before_snapshot, after_snapshot, request_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+)
+
+type AuditRepository struct {
+	db    *sql.DB
+	stmts *util.StmtCache
+}
+
+func New(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db, stmts: util.NewStmtCache(db)}
+}
+
+// WithTx returns a copy of the repository running its queries within tx.
+func (ar *AuditRepository) WithTx(tx *util.Tx) *AuditRepository {
+	scoped := *ar
+	scoped.stmts = ar.stmts.WithTx(tx)
+
+	return &scoped
+}
+
+// ListSpec lists the fields audit records can be sorted and filtered by.
+// Records are never updated, so their ids follow the order they were made
+// in.
+var ListSpec = query.Spec{
+	Sorts: map[string]string{
+		"id": "id",
+	},
+	Filters: []query.Filter{
+		{Param: "actor_id", Column: "actor_id", Operator: query.Equal, Numeric: true},
+		{Param: "action", Column: "action", Operator: query.Equal},
+		{Param: "resource", Column: "resource", Operator: query.Equal},
+		{Param: "resource_id", Column: "resource_id", Operator: query.Equal, Numeric: true},
+		{Param: "request_id", Column: "request_id", Operator: query.Equal},
+		{Param: "since", Column: "created_at", Operator: query.GreaterOrEqual, Time: true},
+		{Param: "until", Column: "created_at", Operator: query.LessOrEqual, Time: true},
+	},
+}
+
+func (ar *AuditRepository) GetAll(ctx context.Context, opts query.Options) ([]entity.Audit, query.Page, error) {
+	page := opts.NewPage()
+
+	q, args := opts.Count(queryCount)
+
+	if err := ar.stmts.QueryRow(ctx, q, args...).Scan(&page.Total); err != nil {
+		return nil, page, err
+	}
+
+	q, args = opts.Select(queryGetAll)
+
+	result, err := ar.stmts.Query(ctx, q, args...)
+
+	if err != nil {
+		return nil, page, err
+	}
+
+	defer result.Close()
+
+	records := []entity.Audit{}
+
+	for result.Next() {
+		record := entity.Audit{}
+		var before, after []byte
+
+		if err := result.Scan(&record.Id, &record.ActorID, &record.Action, &record.Resource, &record.ResourceID, &before, &after, &record.RequestID, &record.CreatedAt); err != nil {
+			return nil, page, err
+		}
+
+		record.Before, record.After = before, after
+		records = append(records, record)
+	}
+
+	if len(records) > page.Limit {
+		records = records[:page.Limit]
+		last := records[len(records)-1]
+		page.More(last.Id, last.Id)
+	}
+
+	return records, page, nil
+}
+
+// Create stores record, stamped with the current time.
+func (ar *AuditRepository) Create(ctx context.Context, record entity.Audit) error {
+	stmt, err := ar.stmts.Prepare(ctx, queryCreate)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.ExecContext(ctx, record.ActorID, record.Action, record.Resource, record.ResourceID, snapshot(record.Before), snapshot(record.After), record.RequestID, time.Now())
+
+	return err
+}
+
+// snapshot returns raw as a query argument, NULL when it is empty.
+func snapshot(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	return []byte(raw)
+}
@@ -0,0 +1,12 @@
+package audit
+
+import (
+	"context"
+	"rest-api/design-pattern/entity"
+	"rest-api/design-pattern/util/query"
+)
+
+type Audit interface {
+	GetAll(context.Context, query.Options) ([]entity.Audit, query.Page, error)
+	Create(context.Context, entity.Audit) error
+}
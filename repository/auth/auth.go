@@ -1,65 +1,259 @@
 package auth
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"net/http"
-	"rest-api/design-pattern/delivery/midware"
+	"rest-api/design-pattern/domain"
 	"rest-api/design-pattern/entity"
+	"rest-api/design-pattern/util"
+	"time"
+)
+
+const (
+	queryLogin             = "SELECT id, password, role FROM users WHERE name = ? AND deleted_at IS NULL"
+	queryUpdatePassword    = "UPDATE users SET password = ? WHERE id = ?"
+	queryUserName          = "SELECT name, role FROM users WHERE id = ? AND deleted_at IS NULL"
+	queryCreateRefresh     = "INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?)"
+	queryGetRefresh        = "SELECT id, user_id, family_id, expires_at, revoked_at FROM refresh_tokens WHERE token_hash = ?"
+	queryRevokeRefresh     = "UPDATE refresh_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL"
+	queryRevokeFamily      = "UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL"
+	queryRevokeOwnRefresh  = "UPDATE refresh_tokens SET revoked_at = ? WHERE token_hash = ? AND user_id = ? AND revoked_at IS NULL"
+	queryRevokeUserRefresh = "UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL"
+	queryRevokeAccess      = "INSERT IGNORE INTO revoked_tokens (jti, expires_at) VALUES (?, ?)"
+	queryRevokeUserAccess  = "UPDATE users SET tokens_revoked_at = ? WHERE id = ?"
+	queryIsRevoked         = "SELECT COUNT(*) FROM users WHERE id = ? AND deleted_at IS NULL AND (tokens_revoked_at IS NULL OR tokens_revoked_at < ?) AND NOT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?)"
+	queryPurgeRefresh      = "DELETE FROM refresh_tokens WHERE expires_at < ?"
+	queryPurgeRevoked      = "DELETE FROM revoked_tokens WHERE expires_at < ?"
 )
 
 type AuthRepository struct {
-	db *sql.DB
+	db    *sql.DB
+	stmts *util.StmtCache
 }
 
 func New(db *sql.DB) *AuthRepository {
-	return &AuthRepository{db: db}
+	return &AuthRepository{db: db, stmts: util.NewStmtCache(db)}
+}
+
+// WithTx returns a copy of the repository running its queries within tx.
+func (ar *AuthRepository) WithTx(tx *util.Tx) *AuthRepository {
+	scoped := *ar
+	scoped.stmts = ar.stmts.WithTx(tx)
+
+	return &scoped
 }
 
-func (ar *AuthRepository) Login(username string, password string) (string, int) {
-	query := fmt.Sprintf("SELECT id, password FROM users WHERE name='%v'", username)
+// GetByName returns the id, password hash and role of every live user going
+// by the name, which is not unique.
+func (ar *AuthRepository) GetByName(ctx context.Context, name string) ([]entity.User, error) {
+	stmt, err := ar.stmts.Prepare(ctx, queryLogin)
+
+	if err != nil {
+		return nil, err
+	}
 
-	result, err := ar.db.Query(query)
+	result, err := stmt.QueryContext(ctx, name)
 
 	if err != nil {
-		return "get user failed", http.StatusInternalServerError
+		return nil, err
 	}
 
 	defer result.Close()
 
-	eligibles := []entity.User{}
-	user := entity.User{}
+	users := []entity.User{}
 
 	for result.Next() {
-		if err := result.Scan(&user.Id, &user.Password); err != nil {
-			return "geat user failed", http.StatusInternalServerError
+		user := entity.User{Name: name}
+
+		if err := result.Scan(&user.Id, &user.Password, &user.Role); err != nil {
+			return nil, err
 		}
-		eligibles = append(eligibles, user)
+
+		users = append(users, user)
+	}
+
+	return users, result.Err()
+}
+
+// Get returns the name and role of a live user.
+func (ar *AuthRepository) Get(ctx context.Context, id int) (entity.User, error) {
+	user := entity.User{Id: id}
+
+	stmt, err := ar.stmts.Prepare(ctx, queryUserName)
+
+	if err != nil {
+		return user, err
 	}
 
-	if len(eligibles) == 0 {
-		return "user does not exist", http.StatusUnauthorized
+	err = stmt.QueryRowContext(ctx, id).Scan(&user.Name, &user.Role)
+
+	if err == sql.ErrNoRows {
+		return user, domain.NotFound("user does not exist")
 	}
 
-	notMatched := true
+	return user, err
+}
 
-	for i := 0; i < len(eligibles) && notMatched; i++ {
-		if eligibles[i].Password == password {
-			notMatched = false
-			user.Id = eligibles[i].Id
-			user.Name = eligibles[i].Name
-		}
+func (ar *AuthRepository) UpdatePassword(ctx context.Context, id int, hash string) error {
+	stmt, err := ar.stmts.Prepare(ctx, queryUpdatePassword)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.ExecContext(ctx, hash, id)
+
+	return err
+}
+
+func (ar *AuthRepository) CreateRefresh(ctx context.Context, refresh entity.RefreshToken) error {
+	stmt, err := ar.stmts.Prepare(ctx, queryCreateRefresh)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.ExecContext(ctx, refresh.UserId, refresh.Family, refresh.Hash, refresh.ExpiresAt)
+
+	return err
+}
+
+// GetRefresh looks a refresh token up by its digest, whether or not it was
+// revoked or has expired.
+func (ar *AuthRepository) GetRefresh(ctx context.Context, hash string) (entity.RefreshToken, error) {
+	refresh := entity.RefreshToken{Hash: hash}
+
+	stmt, err := ar.stmts.Prepare(ctx, queryGetRefresh)
+
+	if err != nil {
+		return refresh, err
+	}
+
+	revokedAt := sql.NullTime{}
+
+	err = stmt.QueryRowContext(ctx, hash).Scan(&refresh.Id, &refresh.UserId, &refresh.Family, &refresh.ExpiresAt, &revokedAt)
+
+	if err == sql.ErrNoRows {
+		return refresh, domain.NotFound("refresh token does not exist")
+	}
+
+	if err != nil {
+		return refresh, err
+	}
+
+	if revokedAt.Valid {
+		refresh.RevokedAt = &revokedAt.Time
+	}
+
+	return refresh, nil
+}
+
+// RevokeRefresh revokes a refresh token and reports whether it was still
+// live, which it is not when a concurrent request revoked it first.
+func (ar *AuthRepository) RevokeRefresh(ctx context.Context, id int) (bool, error) {
+	stmt, err := ar.stmts.Prepare(ctx, queryRevokeRefresh)
+
+	if err != nil {
+		return false, err
+	}
+
+	result, err := stmt.ExecContext(ctx, time.Now(), id)
+
+	if err != nil {
+		return false, err
 	}
 
-	if notMatched {
-		return "password incorrect", http.StatusUnauthorized
+	count, err := result.RowsAffected()
+
+	if err != nil {
+		return false, err
 	}
 
-	token, err := midware.CreateToken(user.Id, user.Name)
+	return count > 0, nil
+}
+
+func (ar *AuthRepository) RevokeFamily(ctx context.Context, family string) error {
+	return ar.exec(ctx, queryRevokeFamily, time.Now(), family)
+}
+
+// RevokeOwnRefresh revokes a refresh token only if it belongs to the user.
+func (ar *AuthRepository) RevokeOwnRefresh(ctx context.Context, hash string, userId int) error {
+	return ar.exec(ctx, queryRevokeOwnRefresh, time.Now(), hash, userId)
+}
+
+func (ar *AuthRepository) RevokeUserRefresh(ctx context.Context, userId int) error {
+	return ar.exec(ctx, queryRevokeUserRefresh, time.Now(), userId)
+}
+
+// RevokeAccess records an access token as revoked until it expires.
+func (ar *AuthRepository) RevokeAccess(ctx context.Context, jti string, expiresAt time.Time) error {
+	return ar.exec(ctx, queryRevokeAccess, jti, expiresAt)
+}
+
+// RevokeUserAccess revokes every access token of the user issued at or before
+// the given time.
+func (ar *AuthRepository) RevokeUserAccess(ctx context.Context, userId int, at time.Time) error {
+	return ar.exec(ctx, queryRevokeUserAccess, at, userId)
+}
+
+// IsRevoked reports whether the access token with jti, issued to the user
+// with userId at issuedAt, no longer holds: it was revoked on its own or
+// along with every token of the user, or the user has been deleted since.
+func (ar *AuthRepository) IsRevoked(ctx context.Context, jti string, userId int, issuedAt time.Time) (bool, error) {
+	stmt, err := ar.stmts.Prepare(ctx, queryIsRevoked)
 
 	if err != nil {
-		return "token creation failed", http.StatusInternalServerError
+		return false, err
 	}
 
-	return token, http.StatusOK
+	live := 0
+
+	if err := stmt.QueryRowContext(ctx, userId, issuedAt, jti).Scan(&live); err != nil {
+		return false, err
+	}
+
+	return live == 0, nil
+}
+
+// Purge deletes the refresh tokens and access token revocations that expired
+// before the given time, which no request can present anymore, and returns
+// how many it deleted.
+func (ar *AuthRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	purged := int64(0)
+
+	for _, q := range []string{queryPurgeRefresh, queryPurgeRevoked} {
+		stmt, err := ar.stmts.Prepare(ctx, q)
+
+		if err != nil {
+			return purged, err
+		}
+
+		result, err := stmt.ExecContext(ctx, before)
+
+		if err != nil {
+			return purged, err
+		}
+
+		count, err := result.RowsAffected()
+
+		if err != nil {
+			return purged, err
+		}
+
+		purged += count
+	}
+
+	return purged, nil
+}
+
+func (ar *AuthRepository) exec(ctx context.Context, q string, args ...interface{}) error {
+	stmt, err := ar.stmts.Prepare(ctx, q)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.ExecContext(ctx, args...)
+
+	return err
 }
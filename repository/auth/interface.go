@@ -1,5 +1,23 @@
 package auth
 
+import (
+	"context"
+	"rest-api/design-pattern/entity"
+	"time"
+)
+
 type Auth interface {
-	Login(string, string) (string, int)
+	GetByName(context.Context, string) ([]entity.User, error)
+	Get(context.Context, int) (entity.User, error)
+	UpdatePassword(context.Context, int, string) error
+	CreateRefresh(context.Context, entity.RefreshToken) error
+	GetRefresh(context.Context, string) (entity.RefreshToken, error)
+	RevokeRefresh(context.Context, int) (bool, error)
+	RevokeFamily(context.Context, string) error
+	RevokeOwnRefresh(context.Context, string, int) error
+	RevokeUserRefresh(context.Context, int) error
+	RevokeUserAccess(context.Context, int, time.Time) error
+	RevokeAccess(context.Context, string, time.Time) error
+	IsRevoked(context.Context, string, int, time.Time) (bool, error)
+	Purge(context.Context, time.Time) (int64, error)
 }
@@ -1,51 +1,156 @@
 package book
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"net/http"
-	"rest-api/design-pattern/delivery/common"
+	"rest-api/design-pattern/domain"
 	"rest-api/design-pattern/entity"
+	"rest-api/design-pattern/util"
+	"rest-api/design-pattern/util/query"
+	"time"
+)
+
+const (
+	queryCount   = "SELECT COUNT(*) FROM books"
+	queryGetAll  = "SELECT id, title, author, publisher, language, pages, isbn13, deleted_at, created_at, updated_at, created_by, updated_by FROM books"
+	queryGet     = "SELECT id, title, author, publisher, language, pages, isbn13, version, created_at, updated_at, created_by, updated_by FROM books WHERE id = ? AND deleted_at IS NULL"
+	queryCreate  = "INSERT INTO books (title, author, publisher, language, pages, isbn13, created_at, updated_at, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	queryUpdate  = "UPDATE books SET title = ?, author = ?, publisher = ?, language = ?, pages = ?, isbn13 = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL"
+	queryDelete  = "UPDATE books SET deleted_at = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL"
+	queryVersion = "SELECT version FROM books WHERE id = ? AND deleted_at IS NULL"
+	queryRestore = "UPDATE books SET deleted_at = NULL, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL"
+	queryDeleted = "SELECT deleted_at FROM books WHERE id = ?"
+	queryPurge   = "DELETE FROM books WHERE deleted_at < ?"
 )
 
 type BookRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	stmts    *util.StmtCache
+	fulltext bool
+}
+
+// New returns a book repository on db, opened with driver. Search uses
+// MySQL full-text search when driver is mysql.
+func New(db *sql.DB, driver string) *BookRepository {
+	return &BookRepository{db: db, stmts: util.NewStmtCache(db), fulltext: driver == "mysql"}
+}
+
+// WithTx returns a copy of the repository running its queries within tx.
+func (br *BookRepository) WithTx(tx *util.Tx) *BookRepository {
+	scoped := *br
+	scoped.stmts = br.stmts.WithTx(tx)
+
+	return &scoped
 }
 
-func New(db *sql.DB) *BookRepository {
-	return &BookRepository{db: db}
+// ListSpec lists the fields books can be sorted and filtered by.
+var ListSpec = query.Spec{
+	Sorts: map[string]string{
+		"id":     "id",
+		"title":  "title",
+		"author": "author",
+		"pages":  "pages",
+	},
+	Filters: []query.Filter{
+		{Param: "author", Column: "author", Operator: query.Equal},
+		{Param: "publisher", Column: "publisher", Operator: query.Equal},
+		{Param: "language", Column: "language", Operator: query.Equal},
+		{Param: "updated_since", Column: "updated_at", Operator: query.GreaterOrEqual, Time: true},
+		{Param: "updated_until", Column: "updated_at", Operator: query.LessOrEqual, Time: true},
+	},
+	DeletedAt: "deleted_at",
 }
 
-func (br *BookRepository) GetAll() ([]common.BookResponse, error) {
-	query := "SELECT id, title, author, publisher, language, pages, isbn13 FROM books"
+func (br *BookRepository) GetAll(ctx context.Context, opts query.Options) ([]entity.Book, query.Page, error) {
+	page := opts.NewPage()
+
+	q, args := opts.Count(queryCount)
+
+	if err := br.stmts.QueryRow(ctx, q, args...).Scan(&page.Total); err != nil {
+		return nil, page, err
+	}
+
+	q, args = opts.Select(queryGetAll)
 
-	result, err := br.db.Query(query)
+	result, err := br.stmts.Query(ctx, q, args...)
 
 	if err != nil {
-		return nil, err
+		return nil, page, err
 	}
 
 	defer result.Close()
 
-	books := []common.BookResponse{}
-	book := common.BookResponse{}
+	books := []entity.Book{}
+	book := entity.Book{}
 
 	for result.Next() {
-		if err := result.Scan(&book.Id, &book.Title, &book.Author, &book.Publisher, &book.Language, &book.Pages, &book.ISBN13); err != nil {
-			return nil, err
+		if err := result.Scan(&book.Id, &book.Title, &book.Author, &book.Publisher, &book.Language, &book.Pages, &book.ISBN13, &book.DeletedAt, &book.CreatedAt, &book.UpdatedAt, &book.CreatedBy, &book.UpdatedBy); err != nil {
+			return nil, page, err
 		}
 
 		books = append(books, book)
 	}
 
-	return books, nil
+	if len(books) > page.Limit {
+		books = books[:page.Limit]
+		last := books[len(books)-1]
+		page.More(sortValue(last, opts.Sort), last.Id)
+	}
+
+	return books, page, nil
+}
+
+func sortValue(book entity.Book, field string) interface{} {
+	switch field {
+	case "title":
+		return book.Title
+	case "author":
+		return book.Author
+	case "pages":
+		return book.Pages
+	default:
+		return book.Id
+	}
+}
+
+// Patchable maps the fields of a book that can be patched, by the names
+// clients send them under, to their columns.
+var Patchable = map[string]string{
+	"title":     "title",
+	"author":    "author",
+	"publisher": "publisher",
+	"language":  "language",
+	"pages":     "pages",
+	"isbn13":    "isbn13",
 }
 
-func (br *BookRepository) Get(id int) (common.BookResponse, error) {
-	book := common.BookResponse{}
-	query := fmt.Sprintf("SELECT id, title, author, publisher, language, pages, isbn13 FROM books WHERE id=%v", id)
+func patchValue(book entity.Book, field string) interface{} {
+	switch field {
+	case "title":
+		return book.Title
+	case "author":
+		return book.Author
+	case "publisher":
+		return book.Publisher
+	case "language":
+		return book.Language
+	case "pages":
+		return book.Pages
+	default:
+		return book.ISBN13
+	}
+}
 
-	result, err := br.db.Query(query)
+func (br *BookRepository) Get(ctx context.Context, id int) (entity.Book, error) {
+	book := entity.Book{}
+
+	stmt, err := br.stmts.Prepare(ctx, queryGet)
+
+	if err != nil {
+		return book, err
+	}
+
+	result, err := stmt.QueryContext(ctx, id)
 
 	if err != nil {
 		return book, err
@@ -54,76 +159,292 @@ func (br *BookRepository) Get(id int) (common.BookResponse, error) {
 	defer result.Close()
 
 	if !result.Next() {
-		return book, err
+		return book, domain.NotFound("book does not exist")
 	}
 
-	if err := result.Scan(&book.Id, &book.Title, &book.Author, &book.Publisher, &book.Language, &book.Pages, &book.ISBN13); err != nil {
+	if err := result.Scan(&book.Id, &book.Title, &book.Author, &book.Publisher, &book.Language, &book.Pages, &book.ISBN13, &book.Version, &book.CreatedAt, &book.UpdatedAt, &book.CreatedBy, &book.UpdatedBy); err != nil {
 		return book, err
 	}
 
 	return book, nil
 }
 
-func (br *BookRepository) Create(book entity.Book) (int, error) {
-	query := fmt.Sprintf("INSERT INTO books (title, author, publisher, language, pages, isbn13) VALUES ('%v','%v','%v','%v','%v','%v')", book.Title, book.Author, book.Publisher, book.Language, book.Pages, book.ISBN13)
-	id := 0
+// Create inserts book and returns it as persisted, read back by the id
+// the insert generated within the same transaction.
+func (br *BookRepository) Create(ctx context.Context, book entity.Book) (entity.Book, error) {
+	created := entity.Book{}
 
-	if _, err := br.db.Exec(query); err != nil {
-		return 0, err
+	tx, err := br.stmts.Begin(ctx)
+
+	if err != nil {
+		return created, err
 	}
 
-	query = fmt.Sprintf("SELECT id FROM books WHERE title='%v' AND author='%v' AND publisher='%v' AND language='%v' AND pages=%v AND isbn13='%v' ORDER BY id DESC LIMIT 1", book.Title, book.Author, book.Publisher, book.Language, book.Pages, book.ISBN13)
+	defer tx.Rollback()
 
-	result, _ := br.db.Query(query)
-	defer result.Close()
+	insert, err := tx.Prepare(ctx, queryCreate)
 
-	if result.Next() {
-		result.Scan(&id)
+	if err != nil {
+		return created, err
 	}
 
-	return id, nil
+	get, err := tx.Prepare(ctx, queryGet)
+
+	if err != nil {
+		return created, err
+	}
+
+	now := time.Now()
+
+	result, err := insert.ExecContext(ctx, book.Title, book.Author, book.Publisher, book.Language, book.Pages, book.ISBN13, now, now, book.CreatedBy, book.CreatedBy)
+
+	if err != nil {
+		return created, err
+	}
+
+	id, err := result.LastInsertId()
+
+	if err != nil {
+		return created, err
+	}
+
+	if err := get.QueryRowContext(ctx, id).Scan(&created.Id, &created.Title, &created.Author, &created.Publisher, &created.Language, &created.Pages, &created.ISBN13, &created.Version, &created.CreatedAt, &created.UpdatedAt, &created.CreatedBy, &created.UpdatedBy); err != nil {
+		return created, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return entity.Book{}, err
+	}
+
+	return created, nil
 }
 
-func (br *BookRepository) Update(book entity.Book) (int, error) {
-	query := fmt.Sprintf("UPDATE books SET title='%v', author='%v', publisher='%v', language='%v', pages=%v, isbn13='%v' WHERE id=%v", book.Title, book.Author, book.Publisher, book.Language, book.Pages, book.ISBN13, book.Id)
+// Update replaces the book with the id of book, provided it is still at the
+// version of book, and moves it to the next version.
+func (br *BookRepository) Update(ctx context.Context, book entity.Book) error {
+	stmt, err := br.stmts.Prepare(ctx, queryUpdate)
 
-	result, err := br.db.Exec(query)
+	if err != nil {
+		return err
+	}
+
+	result, err := stmt.ExecContext(ctx, book.Title, book.Author, book.Publisher, book.Language, book.Pages, book.ISBN13, time.Now(), book.UpdatedBy, book.Id, book.Version)
 
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("udate book failed")
+		return err
 	}
 
 	count, err := result.RowsAffected()
 
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("udate book failed")
+		return err
 	}
 
 	if count == 0 {
-		return http.StatusBadRequest, fmt.Errorf("book does not exist")
+		return br.unmatched(ctx, book.Id)
 	}
 
-	return http.StatusOK, nil
+	return nil
 }
 
-func (br *BookRepository) Delete(id int) (int, error) {
-	query := fmt.Sprintf("DELETE FROM books WHERE id=%v", id)
+// Patch sets the fields of the book with the id of book named in fields to
+// their values in book, leaving the others as they are, provided it is still
+// at the version of book, and returns it as persisted, read back within the
+// same transaction.
+func (br *BookRepository) Patch(ctx context.Context, book entity.Book, fields []string) (entity.Book, error) {
+	patched := entity.Book{}
+
+	tx, err := br.stmts.Begin(ctx)
+
+	if err != nil {
+		return patched, err
+	}
+
+	defer tx.Rollback()
+
+	current, err := tx.Prepare(ctx, queryVersion)
 
-	result, err := br.db.Exec(query)
+	if err != nil {
+		return patched, err
+	}
+
+	version := 0
+
+	err = current.QueryRowContext(ctx, book.Id).Scan(&version)
+
+	if err == sql.ErrNoRows {
+		return patched, domain.NotFound("book does not exist")
+	}
 
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("delete book failed")
+		return patched, err
+	}
+
+	if version != book.Version {
+		return patched, domain.PreconditionFailed("book has been changed")
+	}
+
+	if len(fields) > 0 {
+		update, err := tx.Prepare(ctx, util.PatchQuery("books", Patchable, fields))
+
+		if err != nil {
+			return patched, err
+		}
+
+		args := make([]interface{}, 0, len(fields)+4)
+
+		for _, field := range fields {
+			args = append(args, patchValue(book, field))
+		}
+
+		result, err := update.ExecContext(ctx, append(args, time.Now(), book.UpdatedBy, book.Id, book.Version)...)
+
+		if err != nil {
+			return patched, err
+		}
+
+		count, err := result.RowsAffected()
+
+		if err != nil {
+			return patched, err
+		}
+
+		if count == 0 {
+			return patched, domain.PreconditionFailed("book has been changed")
+		}
+	}
+
+	get, err := tx.Prepare(ctx, queryGet)
+
+	if err != nil {
+		return patched, err
+	}
+
+	if err := get.QueryRowContext(ctx, book.Id).Scan(&patched.Id, &patched.Title, &patched.Author, &patched.Publisher, &patched.Language, &patched.Pages, &patched.ISBN13, &patched.Version, &patched.CreatedAt, &patched.UpdatedAt, &patched.CreatedBy, &patched.UpdatedBy); err != nil {
+		return patched, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return entity.Book{}, err
+	}
+
+	return patched, nil
+}
+
+// Delete deletes the book with id, provided it is still at version. The
+// book is only marked deleted, and kept until purged.
+func (br *BookRepository) Delete(ctx context.Context, id int, version int, by int) error {
+	stmt, err := br.stmts.Prepare(ctx, queryDelete)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	result, err := stmt.ExecContext(ctx, now, now, by, id, version)
+
+	if err != nil {
+		return err
 	}
 
 	count, err := result.RowsAffected()
 
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("delete book failed")
+		return err
 	}
 
 	if count == 0 {
-		return http.StatusBadRequest, fmt.Errorf("book does not exist")
+		return br.unmatched(ctx, id)
+	}
+
+	return nil
+}
+
+// unmatched tells why a change to the book with id at some version matched
+// no row: the book does not exist, or it has been changed since.
+func (br *BookRepository) unmatched(ctx context.Context, id int) error {
+	stmt, err := br.stmts.Prepare(ctx, queryVersion)
+
+	if err != nil {
+		return err
+	}
+
+	version := 0
+
+	err = stmt.QueryRowContext(ctx, id).Scan(&version)
+
+	if err == sql.ErrNoRows {
+		return domain.NotFound("book does not exist")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return domain.PreconditionFailed("book has been changed")
+}
+
+// Restore undoes the deletion of the book with id, which must be deleted
+// and not purged yet.
+func (br *BookRepository) Restore(ctx context.Context, id int, by int) error {
+	stmt, err := br.stmts.Prepare(ctx, queryRestore)
+
+	if err != nil {
+		return err
+	}
+
+	result, err := stmt.ExecContext(ctx, time.Now(), by, id)
+
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	stmt, err = br.stmts.Prepare(ctx, queryDeleted)
+
+	if err != nil {
+		return err
+	}
+
+	deletedAt := sql.NullTime{}
+
+	err = stmt.QueryRowContext(ctx, id).Scan(&deletedAt)
+
+	if err == sql.ErrNoRows {
+		return domain.NotFound("book does not exist")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return domain.Conflict("book is not deleted")
+}
+
+// Purge deletes for good the books deleted before the given time and
+// returns how many there were.
+func (br *BookRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	stmt, err := br.stmts.Prepare(ctx, queryPurge)
+
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := stmt.ExecContext(ctx, before)
+
+	if err != nil {
+		return 0, err
 	}
 
-	return http.StatusOK, nil
+	return result.RowsAffected()
 }
@@ -1,14 +1,20 @@
 package book
 
 import (
-	"rest-api/design-pattern/delivery/common"
+	"context"
 	"rest-api/design-pattern/entity"
+	"rest-api/design-pattern/util/query"
+	"time"
 )
 
 type Book interface {
-	GetAll() ([]common.BookResponse, error)
-	Get(int) (common.BookResponse, error)
-	Create(entity.Book) (int, error)
-	Update(entity.Book) (int, error)
-	Delete(int) (int, error)
+	GetAll(context.Context, query.Options) ([]entity.Book, query.Page, error)
+	Get(context.Context, int) (entity.Book, error)
+	Create(context.Context, entity.Book) (entity.Book, error)
+	Update(context.Context, entity.Book) error
+	Patch(context.Context, entity.Book, []string) (entity.Book, error)
+	Delete(context.Context, int, int, int) error
+	Restore(context.Context, int, int) error
+	Purge(context.Context, time.Time) (int64, error)
+	Search(context.Context, string, query.Options) ([]entity.BookMatch, query.Page, error)
 }
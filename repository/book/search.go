@@ -0,0 +1,185 @@
+package book
+
+import (
+	"context"
+	"html"
+	"rest-api/design-pattern/entity"
+	"rest-api/design-pattern/util"
+	"rest-api/design-pattern/util/query"
+	"strings"
+	"unicode"
+)
+
+// Searching on MySQL uses the ft_books FULLTEXT index created by migration
+// 0002_create_books, and falls back to LIKE when the index is missing or on
+// other drivers.
+const (
+	queryMatch = "MATCH (title, author, publisher) AGAINST (? IN BOOLEAN MODE)"
+
+	querySearchCount = "SELECT COUNT(*) FROM books WHERE deleted_at IS NULL AND " + queryMatch
+	querySearch      = "SELECT id, title, author, publisher, language, pages, isbn13, created_at, updated_at, created_by, updated_by, " + queryMatch + " AS score FROM books WHERE deleted_at IS NULL AND " + queryMatch + " ORDER BY score DESC, id ASC"
+
+	queryLikeCount = "SELECT COUNT(*) FROM books WHERE deleted_at IS NULL AND (title LIKE ? ESCAPE '!' OR author LIKE ? ESCAPE '!' OR publisher LIKE ? ESCAPE '!')"
+	queryLike      = "SELECT id, title, author, publisher, language, pages, isbn13, created_at, updated_at, created_by, updated_by, " +
+		"(CASE WHEN title LIKE ? ESCAPE '!' THEN 3 ELSE 0 END + CASE WHEN author LIKE ? ESCAPE '!' THEN 2 ELSE 0 END + CASE WHEN publisher LIKE ? ESCAPE '!' THEN 1 ELSE 0 END) AS score " +
+		"FROM books WHERE deleted_at IS NULL AND (title LIKE ? ESCAPE '!' OR author LIKE ? ESCAPE '!' OR publisher LIKE ? ESCAPE '!') ORDER BY score DESC, id ASC"
+)
+
+// errFullTextIndex is the MySQL error raised by MATCH without a matching
+// FULLTEXT index.
+const errFullTextIndex = 1191
+
+// Search returns the books whose title, author or publisher contain every
+// word of q as a prefix, most relevant first, with the matched fragments
+// highlighted.
+func (br *BookRepository) Search(ctx context.Context, q string, opts query.Options) ([]entity.BookMatch, query.Page, error) {
+	terms := searchTerms(q)
+
+	if br.fulltext {
+		match := booleanQuery(terms)
+		books, page, err := br.search(ctx, querySearchCount, []interface{}{match}, querySearch, []interface{}{match, match}, terms, opts)
+
+		if !util.IsMySQLError(err, errFullTextIndex) {
+			return books, page, err
+		}
+	}
+
+	pattern := likePattern(q)
+	countArgs := []interface{}{pattern, pattern, pattern}
+
+	return br.search(ctx, queryLikeCount, countArgs, queryLike, append(countArgs, countArgs...), terms, opts)
+}
+
+func (br *BookRepository) search(ctx context.Context, countQuery string, countArgs []interface{}, selectQuery string, selectArgs []interface{}, terms []string, opts query.Options) ([]entity.BookMatch, query.Page, error) {
+	page := opts.NewPage()
+
+	stmt, err := br.stmts.Prepare(ctx, countQuery)
+
+	if err != nil {
+		return nil, page, err
+	}
+
+	if err := stmt.QueryRowContext(ctx, countArgs...).Scan(&page.Total); err != nil {
+		return nil, page, err
+	}
+
+	q, args := opts.Paginate(selectQuery, selectArgs)
+
+	result, err := br.stmts.Query(ctx, q, args...)
+
+	if err != nil {
+		return nil, page, err
+	}
+
+	defer result.Close()
+
+	books := []entity.BookMatch{}
+	book := entity.BookMatch{}
+
+	for result.Next() {
+		if err := result.Scan(&book.Id, &book.Title, &book.Author, &book.Publisher, &book.Language, &book.Pages, &book.ISBN13, &book.CreatedAt, &book.UpdatedAt, &book.CreatedBy, &book.UpdatedBy, &book.Score); err != nil {
+			return nil, page, err
+		}
+
+		book.Highlights = highlights(book.Book, terms)
+		books = append(books, book)
+	}
+
+	if len(books) > page.Limit {
+		books = books[:page.Limit]
+	}
+
+	return books, page, nil
+}
+
+func searchTerms(q string) []string {
+	return strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// booleanQuery requires every term, as a prefix. Terms only hold letters and
+// digits, so no boolean operator of the request reaches MySQL.
+func booleanQuery(terms []string) string {
+	words := make([]string, len(terms))
+
+	for i, term := range terms {
+		words[i] = "+" + term + "*"
+	}
+
+	return strings.Join(words, " ")
+}
+
+func likePattern(q string) string {
+	escaped := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(strings.TrimSpace(q))
+
+	return "%" + escaped + "%"
+}
+
+// highlights wraps the terms found in the title, author and publisher of
+// book in <em> tags. The text around them is HTML escaped, so fragments are
+// safe to render as is.
+func highlights(book entity.Book, terms []string) map[string]string {
+	fields := map[string]string{
+		"title":     book.Title,
+		"author":    book.Author,
+		"publisher": book.Publisher,
+	}
+
+	result := map[string]string{}
+
+	for name, value := range fields {
+		if fragment, ok := highlight(value, terms); ok {
+			result[name] = fragment
+		}
+	}
+
+	return result
+}
+
+func highlight(value string, terms []string) (string, bool) {
+	lower := strings.ToLower(value)
+	marked := make([]bool, len(value))
+	found := false
+
+	for _, term := range terms {
+		for offset := 0; offset < len(lower); {
+			i := strings.Index(lower[offset:], term)
+
+			if i < 0 {
+				break
+			}
+
+			for j := offset + i; j < offset+i+len(term) && j < len(marked); j++ {
+				marked[j] = true
+			}
+
+			found = true
+			offset += i + len(term)
+		}
+	}
+
+	if !found || len(lower) != len(value) {
+		return html.EscapeString(value), found
+	}
+
+	var builder strings.Builder
+
+	for i := 0; i < len(value); {
+		j := i
+
+		for j < len(value) && marked[j] == marked[i] {
+			j++
+		}
+
+		if marked[i] {
+			builder.WriteString("<em>" + html.EscapeString(value[i:j]) + "</em>")
+		} else {
+			builder.WriteString(html.EscapeString(value[i:j]))
+		}
+
+		i = j
+	}
+
+	return builder.String(), true
+}
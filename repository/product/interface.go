@@ -1,14 +1,22 @@
 package product
 
 import (
-	"rest-api/design-pattern/delivery/common"
+	"context"
 	"rest-api/design-pattern/entity"
+	"rest-api/design-pattern/util/query"
+	"time"
 )
 
 type Product interface {
-	GetAll() ([]common.ProductResponse, error)
-	Get(int) (common.ProductResponse, error)
-	Create(entity.Product) (int, string, error)
-	Update(entity.Product) (int, error)
-	Delete(int, int) (int, error)
+	GetAll(context.Context, query.Options) ([]entity.Product, query.Page, error)
+	Get(context.Context, int) (entity.Product, error)
+	Create(context.Context, entity.Product) (entity.Product, error)
+	Update(context.Context, entity.Product) error
+	Patch(context.Context, entity.Product, []string) (entity.Product, error)
+	Delete(context.Context, int, int, int) error
+	GetByUser(context.Context, int) ([]entity.Product, error)
+	DeleteByUser(context.Context, int, int) error
+	Restore(context.Context, int, int) error
+	RestoreByUser(context.Context, int, int) error
+	Purge(context.Context, time.Time) (int64, error)
 }
@@ -1,51 +1,144 @@
 package product
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"net/http"
-	"rest-api/design-pattern/delivery/common"
+	"rest-api/design-pattern/domain"
 	"rest-api/design-pattern/entity"
+	"rest-api/design-pattern/util"
+	"rest-api/design-pattern/util/query"
+	"time"
+)
+
+const (
+	queryCount   = "SELECT COUNT(*) FROM products p LEFT JOIN users u ON p.user_id = u.id"
+	queryGetAll  = "SELECT p.id, p.user_id, u.name, p.name, p.price, p.deleted_at, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id"
+	queryGet     = "SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.id = ? AND p.deleted_at IS NULL"
+	queryCreate  = "INSERT INTO products (user_id, name, price, created_at, updated_at, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	queryUpdate  = "UPDATE products SET name = ?, price = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND user_id = ? AND version = ? AND deleted_at IS NULL"
+	queryDelete  = "UPDATE products SET deleted_at = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND user_id = ? AND version = ? AND deleted_at IS NULL"
+	queryRestore = "UPDATE products p JOIN users u ON p.user_id = u.id SET p.deleted_at = NULL, p.updated_at = ?, p.updated_by = ?, p.version = p.version + 1 WHERE p.id = ? AND p.deleted_at IS NOT NULL AND u.deleted_at IS NULL"
+	queryDeleted = "SELECT p.deleted_at, u.deleted_at FROM products p JOIN users u ON p.user_id = u.id WHERE p.id = ?"
+	queryPurge   = "DELETE FROM products WHERE deleted_at < ?"
+
+	// The products of a user are deleted as of the deletion of the user,
+	// which tells them apart from those deleted before when it is restored.
+	queryGetByUser     = "SELECT p.id, p.user_id, u.name, p.name, p.price, p.version, p.created_at, p.updated_at, p.created_by, p.updated_by FROM products p JOIN users u ON p.user_id = u.id WHERE p.user_id = ? AND p.deleted_at IS NULL ORDER BY p.id"
+	queryDeleteByUser  = "UPDATE products p JOIN users u ON p.user_id = u.id SET p.deleted_at = u.deleted_at, p.updated_at = u.deleted_at, p.updated_by = ?, p.version = p.version + 1 WHERE p.user_id = ? AND p.deleted_at IS NULL"
+	queryRestoreByUser = "UPDATE products p JOIN users u ON p.user_id = u.id SET p.deleted_at = NULL, p.updated_at = ?, p.updated_by = ?, p.version = p.version + 1 WHERE p.user_id = ? AND p.deleted_at = u.deleted_at"
+	queryOwner         = "SELECT user_id, version FROM products WHERE id = ? AND deleted_at IS NULL"
 )
 
 type ProductRepository struct {
-	db *sql.DB
+	db    *sql.DB
+	stmts *util.StmtCache
 }
 
 func New(db *sql.DB) *ProductRepository {
-	return &ProductRepository{db: db}
+	return &ProductRepository{db: db, stmts: util.NewStmtCache(db)}
+}
+
+// WithTx returns a copy of the repository running its queries within tx.
+func (pr *ProductRepository) WithTx(tx *util.Tx) *ProductRepository {
+	scoped := *pr
+	scoped.stmts = pr.stmts.WithTx(tx)
+
+	return &scoped
+}
+
+// ListSpec lists the fields products can be sorted and filtered by.
+var ListSpec = query.Spec{
+	Sorts: map[string]string{
+		"id":    "p.id",
+		"name":  "p.name",
+		"price": "p.price",
+	},
+	Filters: []query.Filter{
+		{Param: "merchant", Column: "u.name", Operator: query.Equal},
+		{Param: "min_price", Column: "p.price", Operator: query.GreaterOrEqual, Numeric: true},
+		{Param: "max_price", Column: "p.price", Operator: query.LessOrEqual, Numeric: true},
+		{Param: "updated_since", Column: "p.updated_at", Operator: query.GreaterOrEqual, Time: true},
+		{Param: "updated_until", Column: "p.updated_at", Operator: query.LessOrEqual, Time: true},
+	},
+	DeletedAt: "p.deleted_at",
 }
 
-func (pr *ProductRepository) GetAll() ([]common.ProductResponse, error) {
-	query := "SELECT p.id, u.name, p.name, p.price FROM products p LEFT JOIN users u ON p.user_id = u.id"
+func (pr *ProductRepository) GetAll(ctx context.Context, opts query.Options) ([]entity.Product, query.Page, error) {
+	page := opts.NewPage()
 
-	result, err := pr.db.Query(query)
+	q, args := opts.Count(queryCount)
+
+	if err := pr.stmts.QueryRow(ctx, q, args...).Scan(&page.Total); err != nil {
+		return nil, page, err
+	}
+
+	q, args = opts.Select(queryGetAll)
+
+	result, err := pr.stmts.Query(ctx, q, args...)
 
 	if err != nil {
-		return nil, err
+		return nil, page, err
 	}
 
 	defer result.Close()
 
-	products := []common.ProductResponse{}
-	product := common.ProductResponse{}
+	products := []entity.Product{}
+	product := entity.Product{}
 
 	for result.Next() {
-		if err := result.Scan(&product.Id, &product.Merchant, &product.Name, &product.Price); err != nil {
-			return nil, err
+		if err := result.Scan(&product.Id, &product.UserID, &product.Merchant, &product.Name, &product.Price, &product.DeletedAt, &product.CreatedAt, &product.UpdatedAt, &product.CreatedBy, &product.UpdatedBy); err != nil {
+			return nil, page, err
 		}
 
 		products = append(products, product)
 	}
 
-	return products, nil
+	if len(products) > page.Limit {
+		products = products[:page.Limit]
+		last := products[len(products)-1]
+		page.More(sortValue(last, opts.Sort), last.Id)
+	}
+
+	return products, page, nil
+}
+
+func sortValue(product entity.Product, field string) interface{} {
+	switch field {
+	case "name":
+		return product.Name
+	case "price":
+		return product.Price
+	default:
+		return product.Id
+	}
+}
+
+// Patchable maps the fields of a product that can be patched, by the names
+// clients send them under, to their columns.
+var Patchable = map[string]string{
+	"name":  "name",
+	"price": "price",
+}
+
+func patchValue(product entity.Product, field string) interface{} {
+	switch field {
+	case "name":
+		return product.Name
+	default:
+		return product.Price
+	}
 }
 
-func (pr *ProductRepository) Get(id int) (common.ProductResponse, error) {
-	product := common.ProductResponse{}
-	query := fmt.Sprintf("SELECT p.id, u.name, p.name, p.price FROM products p LEFT JOIN users u ON p.user_id = u.id WHERE p.id=%v", id)
+func (pr *ProductRepository) Get(ctx context.Context, id int) (entity.Product, error) {
+	product := entity.Product{}
 
-	result, err := pr.db.Query(query)
+	stmt, err := pr.stmts.Prepare(ctx, queryGet)
+
+	if err != nil {
+		return product, err
+	}
+
+	result, err := stmt.QueryContext(ctx, id)
 
 	if err != nil {
 		return product, err
@@ -54,87 +147,372 @@ func (pr *ProductRepository) Get(id int) (common.ProductResponse, error) {
 	defer result.Close()
 
 	if !result.Next() {
-		return product, err
+		return product, domain.NotFound("product does not exist")
 	}
 
-	if err := result.Scan(&product.Id, &product.Merchant, &product.Name, &product.Price); err != nil {
+	if err := result.Scan(&product.Id, &product.UserID, &product.Merchant, &product.Name, &product.Price, &product.Version, &product.CreatedAt, &product.UpdatedAt, &product.CreatedBy, &product.UpdatedBy); err != nil {
 		return product, err
 	}
 
 	return product, nil
 }
 
-func (pr *ProductRepository) Create(product entity.Product) (int, string, error) {
-	query := fmt.Sprintf("INSERT INTO products (user_id, name, price) VALUES ('%v', '%v', '%v')", product.UserID, product.Name, product.Price)
-	id := 0
+// Create inserts product and returns it as persisted, with its merchant name, read back by the id
+// the insert generated within the same transaction.
+func (pr *ProductRepository) Create(ctx context.Context, product entity.Product) (entity.Product, error) {
+	created := entity.Product{}
+
+	tx, err := pr.stmts.Begin(ctx)
 
-	if _, err := pr.db.Exec(query); err != nil {
-		return id, "", err
+	if err != nil {
+		return created, err
 	}
 
-	query = fmt.Sprintf("SELECT id FROM products WHERE name='%v' AND user_id=%v AND price='%v' ORDER BY id DESC LIMIT 1", product.Name, product.UserID, product.Price)
+	defer tx.Rollback()
 
-	result, _ := pr.db.Query(query)
-	defer result.Close()
+	insert, err := tx.Prepare(ctx, queryCreate)
 
-	if result.Next() {
-		result.Scan(&id)
+	if err != nil {
+		return created, err
 	}
 
-	query = fmt.Sprintf("SELECT name FROM users WHERE id=%v", product.UserID)
+	get, err := tx.Prepare(ctx, queryGet)
 
-	result, _ = pr.db.Query(query)
-	defer result.Close()
+	if err != nil {
+		return created, err
+	}
+
+	now := time.Now()
 
-	name := ""
+	result, err := insert.ExecContext(ctx, product.UserID, product.Name, product.Price, now, now, product.CreatedBy, product.CreatedBy)
 
-	if result.Next() {
-		result.Scan(&name)
+	if util.IsMySQLError(err, util.ErrNoReferencedRow) {
+		return created, domain.Validation("user does not exist")
 	}
 
-	return id, name, nil
+	if err != nil {
+		return created, err
+	}
+
+	id, err := result.LastInsertId()
+
+	if err != nil {
+		return created, err
+	}
+
+	if err := get.QueryRowContext(ctx, id).Scan(&created.Id, &created.UserID, &created.Merchant, &created.Name, &created.Price, &created.Version, &created.CreatedAt, &created.UpdatedAt, &created.CreatedBy, &created.UpdatedBy); err != nil {
+		return created, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return entity.Product{}, err
+	}
+
+	return created, nil
 }
 
-func (pr *ProductRepository) Update(product entity.Product) (int, error) {
-	query := fmt.Sprintf("UPDATE products SET name='%v', price='%v' WHERE id=%v AND user_id=%v", product.Name, product.Price, product.Id, product.UserID)
+// Update replaces the product with the id of product, provided it belongs to
+// the user of product and is still at the version of product, and moves it
+// to the next version.
+func (pr *ProductRepository) Update(ctx context.Context, product entity.Product) error {
+	stmt, err := pr.stmts.Prepare(ctx, queryUpdate)
 
-	result, err := pr.db.Exec(query)
+	if err != nil {
+		return err
+	}
+
+	result, err := stmt.ExecContext(ctx, product.Name, product.Price, time.Now(), product.UpdatedBy, product.Id, product.UserID, product.Version)
 
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("update product failed")
+		return err
 	}
 
 	count, err := result.RowsAffected()
 
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("update product failed")
+		return err
 	}
 
 	if count == 0 {
-		return http.StatusBadRequest, fmt.Errorf("product does not exist")
+		return pr.unmatched(ctx, product.Id, product.UserID)
 	}
 
-	return http.StatusOK, nil
+	return nil
 }
 
-func (pr *ProductRepository) Delete(id int, userid int) (int, error) {
-	query := fmt.Sprintf("DELETE FROM products WHERE id=%v AND user_id=%v", id, userid)
+// Patch sets the fields of the product with the id of product named in
+// fields to their values in product, leaving the others as they are, and
+// returns it as persisted, with its merchant name, read back within the same
+// transaction. The product must belong to the user of product and still be
+// at its version.
+func (pr *ProductRepository) Patch(ctx context.Context, product entity.Product, fields []string) (entity.Product, error) {
+	patched := entity.Product{}
+
+	tx, err := pr.stmts.Begin(ctx)
+
+	if err != nil {
+		return patched, err
+	}
+
+	defer tx.Rollback()
+
+	owner, err := tx.Prepare(ctx, queryOwner)
+
+	if err != nil {
+		return patched, err
+	}
+
+	userid, version := 0, 0
+
+	err = owner.QueryRowContext(ctx, product.Id).Scan(&userid, &version)
+
+	if err == sql.ErrNoRows {
+		return patched, domain.NotFound("product does not exist")
+	}
+
+	if err != nil {
+		return patched, err
+	}
+
+	if userid != product.UserID {
+		return patched, domain.Forbidden("user does not match")
+	}
+
+	if version != product.Version {
+		return patched, domain.PreconditionFailed("product has been changed")
+	}
+
+	if len(fields) > 0 {
+		update, err := tx.Prepare(ctx, util.PatchQuery("products", Patchable, fields))
+
+		if err != nil {
+			return patched, err
+		}
+
+		args := make([]interface{}, 0, len(fields)+4)
+
+		for _, field := range fields {
+			args = append(args, patchValue(product, field))
+		}
+
+		result, err := update.ExecContext(ctx, append(args, time.Now(), product.UpdatedBy, product.Id, product.Version)...)
+
+		if err != nil {
+			return patched, err
+		}
+
+		count, err := result.RowsAffected()
+
+		if err != nil {
+			return patched, err
+		}
+
+		if count == 0 {
+			return patched, domain.PreconditionFailed("product has been changed")
+		}
+	}
+
+	get, err := tx.Prepare(ctx, queryGet)
+
+	if err != nil {
+		return patched, err
+	}
+
+	if err := get.QueryRowContext(ctx, product.Id).Scan(&patched.Id, &patched.UserID, &patched.Merchant, &patched.Name, &patched.Price, &patched.Version, &patched.CreatedAt, &patched.UpdatedAt, &patched.CreatedBy, &patched.UpdatedBy); err != nil {
+		return patched, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return entity.Product{}, err
+	}
 
-	result, err := pr.db.Exec(query)
+	return patched, nil
+}
+
+// Delete deletes the product with id, provided it belongs to userid and is
+// still at version. The product is only marked deleted, and kept until
+// purged.
+func (pr *ProductRepository) Delete(ctx context.Context, id int, userid int, version int) error {
+	stmt, err := pr.stmts.Prepare(ctx, queryDelete)
 
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("delete product failed")
+		return err
+	}
+
+	now := time.Now()
+
+	result, err := stmt.ExecContext(ctx, now, now, userid, id, userid, version)
+
+	if err != nil {
+		return err
 	}
 
 	count, err := result.RowsAffected()
 
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("delete product failed")
+		return err
 	}
 
 	if count == 0 {
-		return http.StatusBadRequest, fmt.Errorf("user does not match")
+		return pr.unmatched(ctx, id, userid)
+	}
+
+	return nil
+}
+
+// GetByUser returns the products of the user with userid that are not
+// deleted, in the order they were created in.
+func (pr *ProductRepository) GetByUser(ctx context.Context, userid int) ([]entity.Product, error) {
+	stmt, err := pr.stmts.Prepare(ctx, queryGetByUser)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := stmt.QueryContext(ctx, userid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer result.Close()
+
+	products := []entity.Product{}
+
+	for result.Next() {
+		product := entity.Product{}
+
+		if err := result.Scan(&product.Id, &product.UserID, &product.Merchant, &product.Name, &product.Price, &product.Version, &product.CreatedAt, &product.UpdatedAt, &product.CreatedBy, &product.UpdatedBy); err != nil {
+			return nil, err
+		}
+
+		products = append(products, product)
+	}
+
+	return products, result.Err()
+}
+
+// DeleteByUser deletes every product of the user with userid, if any, as of
+// the deletion of the user, which must come first.
+func (pr *ProductRepository) DeleteByUser(ctx context.Context, userid int, by int) error {
+	stmt, err := pr.stmts.Prepare(ctx, queryDeleteByUser)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.ExecContext(ctx, by, userid)
+
+	return err
+}
+
+// Restore undoes the deletion of the product with id, which must be deleted
+// and not purged yet, while its user is not deleted.
+func (pr *ProductRepository) Restore(ctx context.Context, id int, by int) error {
+	stmt, err := pr.stmts.Prepare(ctx, queryRestore)
+
+	if err != nil {
+		return err
+	}
+
+	result, err := stmt.ExecContext(ctx, time.Now(), by, id)
+
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	stmt, err = pr.stmts.Prepare(ctx, queryDeleted)
+
+	if err != nil {
+		return err
+	}
+
+	deletedAt, userDeletedAt := sql.NullTime{}, sql.NullTime{}
+
+	err = stmt.QueryRowContext(ctx, id).Scan(&deletedAt, &userDeletedAt)
+
+	if err == sql.ErrNoRows {
+		return domain.NotFound("product does not exist")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !deletedAt.Valid {
+		return domain.Conflict("product is not deleted")
+	}
+
+	return domain.Conflict("user of the product is deleted")
+}
+
+// RestoreByUser undoes the deletion of the products of the user with userid
+// deleted along with it, which must be restored next.
+func (pr *ProductRepository) RestoreByUser(ctx context.Context, userid int, by int) error {
+	stmt, err := pr.stmts.Prepare(ctx, queryRestoreByUser)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.ExecContext(ctx, time.Now(), by, userid)
+
+	return err
+}
+
+// Purge deletes for good the products deleted before the given time and
+// returns how many there were.
+func (pr *ProductRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	stmt, err := pr.stmts.Prepare(ctx, queryPurge)
+
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := stmt.ExecContext(ctx, before)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// unmatched tells why a change to the product with id by userid at some
+// version matched no row: the product does not exist, it belongs to someone
+// else, or it has been changed since.
+func (pr *ProductRepository) unmatched(ctx context.Context, id int, userid int) error {
+	stmt, err := pr.stmts.Prepare(ctx, queryOwner)
+
+	if err != nil {
+		return err
+	}
+
+	owner, version := 0, 0
+
+	err = stmt.QueryRowContext(ctx, id).Scan(&owner, &version)
+
+	if err == sql.ErrNoRows {
+		return domain.NotFound("product does not exist")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if owner != userid {
+		return domain.Forbidden("user does not match")
 	}
 
-	return http.StatusOK, nil
+	return domain.PreconditionFailed("product has been changed")
 }
@@ -0,0 +1,103 @@
+// Package transaction runs several repository operations as one unit of
+// work, on repositories scoped to a single database transaction.
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"rest-api/design-pattern/repository/audit"
+	"rest-api/design-pattern/repository/auth"
+	"rest-api/design-pattern/repository/book"
+	"rest-api/design-pattern/repository/product"
+	"rest-api/design-pattern/repository/user"
+	"rest-api/design-pattern/util"
+)
+
+// Runner runs fn within a transaction.
+type Runner interface {
+	Do(ctx context.Context, fn func(Repositories) error) error
+}
+
+// Repositories are the repositories of a transaction. They must not be used
+// once the function they were passed to returns.
+type Repositories struct {
+	Books    book.Book
+	Products product.Product
+	Users    user.User
+	Audits   audit.Audit
+	Auth     auth.Auth
+
+	manager *Manager
+	tx      *util.Tx
+}
+
+type Manager struct {
+	db       *sql.DB
+	books    *book.BookRepository
+	products *product.ProductRepository
+	users    *user.UserRepository
+	audits   *audit.AuditRepository
+	auth     *auth.AuthRepository
+}
+
+func New(db *sql.DB, books *book.BookRepository, products *product.ProductRepository, users *user.UserRepository, audits *audit.AuditRepository, auth *auth.AuthRepository) *Manager {
+	return &Manager{db: db, books: books, products: products, users: users, audits: audits, auth: auth}
+}
+
+// Do runs fn in a new transaction, committed when fn returns nil and rolled
+// back when it returns an error or panics. A panic is passed on after the
+// rollback. The transaction is also rolled back if ctx is done first.
+func (m *Manager) Do(ctx context.Context, fn func(Repositories) error) error {
+	tx, err := util.Begin(ctx, m.db)
+
+	if err != nil {
+		return err
+	}
+
+	return m.run(tx, fn)
+}
+
+// Do runs fn in a savepoint of the transaction, so an error or panic in fn
+// only undoes what fn did and leaves the transaction open. Repositories not
+// made by a Manager, as in tests, pass themselves to fn as they are.
+func (r Repositories) Do(ctx context.Context, fn func(Repositories) error) error {
+	if r.tx == nil {
+		return fn(r)
+	}
+
+	savepoint, err := r.tx.Begin(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return r.manager.run(savepoint, fn)
+}
+
+func (m *Manager) run(tx *util.Tx, fn func(Repositories) error) error {
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(m.scoped(tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *Manager) scoped(tx *util.Tx) Repositories {
+	return Repositories{
+		Books:    m.books.WithTx(tx),
+		Products: m.products.WithTx(tx),
+		Users:    m.users.WithTx(tx),
+		Audits:   m.audits.WithTx(tx),
+		Auth:     m.auth.WithTx(tx),
+		manager:  m,
+		tx:       tx,
+	}
+}
@@ -0,0 +1,193 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"rest-api/design-pattern/repository/audit"
+	"rest-api/design-pattern/repository/auth"
+	"rest-api/design-pattern/repository/book"
+	"rest-api/design-pattern/repository/product"
+	"rest-api/design-pattern/repository/user"
+	"rest-api/design-pattern/util/password"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newManager(db *sql.DB) *Manager {
+	return New(db, book.New(db, "mysql"), product.New(db), user.New(db, password.NewBcrypt(bcrypt.MinCost)), audit.New(db), auth.New(db))
+}
+
+func TestDo(t *testing.T) {
+	t.Run("TestDoCommit", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectPrepare("UPDATE products p JOIN users u ON p.user_id = u.id SET p.deleted_at = u.deleted_at, p.updated_at = u.deleted_at, p.updated_by = ?, p.version = p.version + 1 WHERE p.user_id = ? AND p.deleted_at IS NULL").
+			ExpectExec().
+			WithArgs(1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		err := newManager(db).Do(context.Background(), func(repos Repositories) error {
+			return repos.Products.DeleteByUser(context.Background(), 1, 1)
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("TestDoRollbackOnError", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		failed := errors.New("failed")
+
+		err := newManager(db).Do(context.Background(), func(repos Repositories) error {
+			return failed
+		})
+
+		assert.Equal(t, failed, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("TestDoRollbackOnPanic", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		assert.PanicsWithValue(t, "boom", func() {
+			newManager(db).Do(context.Background(), func(repos Repositories) error {
+				panic("boom")
+			})
+		})
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("TestDoBeginFail", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin().WillReturnError(assert.AnError)
+
+		called := false
+
+		err := newManager(db).Do(context.Background(), func(repos Repositories) error {
+			called = true
+			return nil
+		})
+
+		assert.Equal(t, assert.AnError, err)
+		assert.False(t, called)
+	})
+
+	t.Run("TestDoCancelled", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		called := false
+
+		err := newManager(db).Do(ctx, func(repos Repositories) error {
+			called = true
+			return nil
+		})
+
+		assert.Equal(t, context.Canceled, err)
+		assert.False(t, called)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSavepoint(t *testing.T) {
+	t.Run("TestSavepointRelease", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("RELEASE SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := newManager(db).Do(context.Background(), func(repos Repositories) error {
+			return repos.Do(context.Background(), func(Repositories) error {
+				return nil
+			})
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("TestSavepointRollback", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare("UPDATE users SET deleted_at = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL").
+			ExpectExec().
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare("SELECT version FROM users WHERE id = ? AND deleted_at IS NULL").
+			ExpectQuery().
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"version"}))
+		mock.ExpectExec("ROLLBACK TO SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("SAVEPOINT sp_2").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("RELEASE SAVEPOINT sp_2").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		nested := 0
+
+		err := newManager(db).Do(context.Background(), func(repos Repositories) error {
+			err := repos.Do(context.Background(), func(repos Repositories) error {
+				return repos.Users.Delete(context.Background(), 1, 1, 1)
+			})
+
+			assert.EqualError(t, err, "user does not exist")
+
+			// The transaction stays usable after the savepoint is rolled back.
+			return repos.Do(context.Background(), func(Repositories) error {
+				nested++
+				return nil
+			})
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, nested)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("TestSavepointPanic", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("ROLLBACK TO SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		assert.Panics(t, func() {
+			newManager(db).Do(context.Background(), func(repos Repositories) error {
+				return repos.Do(context.Background(), func(Repositories) error {
+					panic("boom")
+				})
+			})
+		})
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
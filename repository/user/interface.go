@@ -1,14 +1,20 @@
 package user
 
 import (
-	"rest-api/design-pattern/delivery/common"
+	"context"
 	"rest-api/design-pattern/entity"
+	"rest-api/design-pattern/util/query"
+	"time"
 )
 
 type User interface {
-	GetAll() ([]common.UserResponse, error)
-	Get(int) (common.UserResponse, error)
-	Create(entity.User) (int, error)
-	Update(entity.User) (int, error)
-	Delete(int) (int, error)
+	GetAll(context.Context, query.Options) ([]entity.User, query.Page, error)
+	Get(context.Context, int) (entity.User, error)
+	Create(context.Context, entity.User) (entity.User, error)
+	Update(context.Context, entity.User) error
+	Patch(context.Context, entity.User, []string) (entity.User, error)
+	Delete(context.Context, int, int, int) error
+	Restore(context.Context, int, int) error
+	Purge(context.Context, time.Time) (int64, error)
+	SetRole(context.Context, int, string, int) error
 }
@@ -1,51 +1,132 @@
 package user
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"net/http"
-	"rest-api/design-pattern/delivery/common"
+	"rest-api/design-pattern/domain"
 	"rest-api/design-pattern/entity"
+	"rest-api/design-pattern/util"
+	"rest-api/design-pattern/util/password"
+	"rest-api/design-pattern/util/query"
+	"time"
+)
+
+const (
+	queryCount   = "SELECT COUNT(*) FROM users"
+	queryGetAll  = "SELECT id, name, email, role, deleted_at, created_at, updated_at, created_by, updated_by FROM users"
+	queryGet     = "SELECT id, name, email, role, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ? AND deleted_at IS NULL"
+	queryCreate  = "INSERT INTO users (name, email, password, role, created_at, updated_at, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	queryUpdate  = "UPDATE users SET name = ?, email = ?, password = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL"
+	queryDelete  = "UPDATE users SET deleted_at = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL"
+	querySetRole = "UPDATE users SET role = ?, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL"
+	queryEmail   = "SELECT COUNT(*) FROM users WHERE email = ? AND id <> ?"
+	queryVersion = "SELECT version FROM users WHERE id = ? AND deleted_at IS NULL"
+	queryRestore = "UPDATE users SET deleted_at = NULL, updated_at = ?, updated_by = ?, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL"
+	queryDeleted = "SELECT deleted_at FROM users WHERE id = ?"
+	queryPurge   = "DELETE FROM users WHERE deleted_at < ?"
 )
 
 type UserRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	stmts  *util.StmtCache
+	hasher password.Hasher
+}
+
+func New(db *sql.DB, hasher password.Hasher) *UserRepository {
+	return &UserRepository{db: db, stmts: util.NewStmtCache(db), hasher: hasher}
+}
+
+// WithTx returns a copy of the repository running its queries within tx.
+func (ur *UserRepository) WithTx(tx *util.Tx) *UserRepository {
+	scoped := *ur
+	scoped.stmts = ur.stmts.WithTx(tx)
+
+	return &scoped
 }
 
-func New(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
+// ListSpec lists the fields users can be sorted and filtered by.
+var ListSpec = query.Spec{
+	Sorts: map[string]string{
+		"id":    "id",
+		"name":  "name",
+		"email": "email",
+	},
+	Filters: []query.Filter{
+		{Param: "role", Column: "role", Operator: query.Equal},
+		{Param: "updated_since", Column: "updated_at", Operator: query.GreaterOrEqual, Time: true},
+		{Param: "updated_until", Column: "updated_at", Operator: query.LessOrEqual, Time: true},
+	},
+	DeletedAt: "deleted_at",
 }
 
-func (ur *UserRepository) GetAll() ([]common.UserResponse, error) {
-	query := "SELECT id, name, email FROM users"
+func (ur *UserRepository) GetAll(ctx context.Context, opts query.Options) ([]entity.User, query.Page, error) {
+	page := opts.NewPage()
 
-	result, err := ur.db.Query(query)
+	q, args := opts.Count(queryCount)
+
+	if err := ur.stmts.QueryRow(ctx, q, args...).Scan(&page.Total); err != nil {
+		return nil, page, err
+	}
+
+	q, args = opts.Select(queryGetAll)
+
+	result, err := ur.stmts.Query(ctx, q, args...)
 
 	if err != nil {
-		return nil, err
+		return nil, page, err
 	}
 
 	defer result.Close()
 
-	users := []common.UserResponse{}
-	user := common.UserResponse{}
+	users := []entity.User{}
+	user := entity.User{}
 
 	for result.Next() {
-		if err := result.Scan(&user.Id, &user.Name, &user.Email); err != nil {
-			return nil, err
+		if err := result.Scan(&user.Id, &user.Name, &user.Email, &user.Role, &user.DeletedAt, &user.CreatedAt, &user.UpdatedAt, &user.CreatedBy, &user.UpdatedBy); err != nil {
+			return nil, page, err
 		}
 
 		users = append(users, user)
 	}
 
-	return users, nil
+	if len(users) > page.Limit {
+		users = users[:page.Limit]
+		last := users[len(users)-1]
+		page.More(sortValue(last, opts.Sort), last.Id)
+	}
+
+	return users, page, nil
 }
 
-func (ur *UserRepository) Get(id int) (common.UserResponse, error) {
-	user := common.UserResponse{}
-	query := fmt.Sprintf("SELECT id, name, email FROM users WHERE id=%v", id)
+func sortValue(user entity.User, field string) interface{} {
+	switch field {
+	case "name":
+		return user.Name
+	case "email":
+		return user.Email
+	default:
+		return user.Id
+	}
+}
+
+// Patchable maps the fields of a user that can be patched, by the names
+// clients send them under, to their columns. The role is set on its own.
+var Patchable = map[string]string{
+	"name":     "name",
+	"email":    "email",
+	"password": "password",
+}
 
-	result, err := ur.db.Query(query)
+func (ur *UserRepository) Get(ctx context.Context, id int) (entity.User, error) {
+	user := entity.User{}
+
+	stmt, err := ur.stmts.Prepare(ctx, queryGet)
+
+	if err != nil {
+		return user, err
+	}
+
+	result, err := stmt.QueryContext(ctx, id)
 
 	if err != nil {
 		return user, err
@@ -54,76 +135,408 @@ func (ur *UserRepository) Get(id int) (common.UserResponse, error) {
 	defer result.Close()
 
 	if !result.Next() {
-		return user, err
+		return user, domain.NotFound("user does not exist")
 	}
 
-	if err := result.Scan(&user.Id, &user.Name, &user.Email); err != nil {
+	if err := result.Scan(&user.Id, &user.Name, &user.Email, &user.Role, &user.Version, &user.CreatedAt, &user.UpdatedAt, &user.CreatedBy, &user.UpdatedBy); err != nil {
 		return user, err
 	}
 
 	return user, nil
 }
 
-func (ur *UserRepository) Create(user entity.User) (int, error) {
-	query := fmt.Sprintf("INSERT INTO users (name, email, password) VALUES ('%v','%v','%v')", user.Name, user.Email, user.Password)
-	id := 0
+// Create inserts user and returns it as persisted, read back by the id
+// the insert generated within the same transaction.
+func (ur *UserRepository) Create(ctx context.Context, user entity.User) (entity.User, error) {
+	created := entity.User{}
 
-	if _, err := ur.db.Exec(query); err != nil {
-		return 0, err
+	if err := ur.checkEmail(ctx, user.Email, 0); err != nil {
+		return created, err
 	}
 
-	query = fmt.Sprintf("SELECT id FROM users WHERE name='%v' AND email='%v' AND password='%v' ORDER BY id DESC LIMIT 1", user.Name, user.Email, user.Password)
+	hash, err := ur.hasher.Hash(user.Password)
 
-	result, _ := ur.db.Query(query)
-	defer result.Close()
+	if err != nil {
+		return created, err
+	}
+
+	tx, err := ur.stmts.Begin(ctx)
+
+	if err != nil {
+		return created, err
+	}
+
+	defer tx.Rollback()
+
+	insert, err := tx.Prepare(ctx, queryCreate)
+
+	if err != nil {
+		return created, err
+	}
+
+	get, err := tx.Prepare(ctx, queryGet)
+
+	if err != nil {
+		return created, err
+	}
+
+	now := time.Now()
+
+	result, err := insert.ExecContext(ctx, user.Name, user.Email, hash, user.Role, now, now, user.CreatedBy, user.CreatedBy)
+
+	if key, ok := util.DuplicateKey(err); ok {
+		return created, duplicate(key)
+	}
+
+	if err != nil {
+		return created, err
+	}
+
+	id, err := result.LastInsertId()
+
+	if err != nil {
+		return created, err
+	}
+
+	if err := get.QueryRowContext(ctx, id).Scan(&created.Id, &created.Name, &created.Email, &created.Role, &created.Version, &created.CreatedAt, &created.UpdatedAt, &created.CreatedBy, &created.UpdatedBy); err != nil {
+		return created, err
+	}
 
-	if result.Next() {
-		result.Scan(&id)
+	if err := tx.Commit(); err != nil {
+		return entity.User{}, err
 	}
 
-	return id, nil
+	return created, nil
 }
 
-func (ur *UserRepository) Update(user entity.User) (int, error) {
-	query := fmt.Sprintf("UPDATE users SET name='%v', email='%v', password='%v' WHERE id=%v", user.Name, user.Email, user.Password, user.Id)
+// Update replaces the profile of the user with the id of user, provided it
+// is still at the version of user, and moves it to the next version.
+func (ur *UserRepository) Update(ctx context.Context, user entity.User) error {
+	if err := ur.checkEmail(ctx, user.Email, user.Id); err != nil {
+		return err
+	}
+
+	hash, err := ur.hasher.Hash(user.Password)
+
+	if err != nil {
+		return err
+	}
+
+	stmt, err := ur.stmts.Prepare(ctx, queryUpdate)
+
+	if err != nil {
+		return err
+	}
+
+	result, err := stmt.ExecContext(ctx, user.Name, user.Email, hash, time.Now(), user.UpdatedBy, user.Id, user.Version)
 
-	result, err := ur.db.Exec(query)
+	if key, ok := util.DuplicateKey(err); ok {
+		return duplicate(key)
+	}
 
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("update user failed")
+		return err
 	}
 
 	count, err := result.RowsAffected()
 
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("update user failed")
+		return err
 	}
 
 	if count == 0 {
-		return http.StatusBadRequest, fmt.Errorf("user does not exist")
+		return ur.unmatched(ctx, user.Id)
 	}
 
-	return http.StatusOK, nil
+	return nil
 }
 
-func (ur *UserRepository) Delete(id int) (int, error) {
-	query := fmt.Sprintf("DELETE FROM users WHERE id=%v", id)
+// Patch sets the fields of the user with the id of user named in fields to
+// their values in user, leaving the others as they are, provided it is still
+// at the version of user, and returns it as persisted, read back within the
+// same transaction. A patched password is stored hashed.
+func (ur *UserRepository) Patch(ctx context.Context, user entity.User, fields []string) (entity.User, error) {
+	patched := entity.User{}
+	args := make([]interface{}, 0, len(fields)+4)
+
+	for _, field := range fields {
+		switch field {
+		case "name":
+			args = append(args, user.Name)
+		case "email":
+			if err := ur.checkEmail(ctx, user.Email, user.Id); err != nil {
+				return patched, err
+			}
+
+			args = append(args, user.Email)
+		case "password":
+			hash, err := ur.hasher.Hash(user.Password)
+
+			if err != nil {
+				return patched, err
+			}
+
+			args = append(args, hash)
+		}
+	}
 
-	result, err := ur.db.Exec(query)
+	tx, err := ur.stmts.Begin(ctx)
 
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("delete user failed")
+		return patched, err
+	}
+
+	defer tx.Rollback()
+
+	current, err := tx.Prepare(ctx, queryVersion)
+
+	if err != nil {
+		return patched, err
+	}
+
+	version := 0
+
+	err = current.QueryRowContext(ctx, user.Id).Scan(&version)
+
+	if err == sql.ErrNoRows {
+		return patched, domain.NotFound("user does not exist")
+	}
+
+	if err != nil {
+		return patched, err
+	}
+
+	if version != user.Version {
+		return patched, domain.PreconditionFailed("user has been changed")
+	}
+
+	if len(fields) > 0 {
+		update, err := tx.Prepare(ctx, util.PatchQuery("users", Patchable, fields))
+
+		if err != nil {
+			return patched, err
+		}
+
+		result, err := update.ExecContext(ctx, append(args, time.Now(), user.UpdatedBy, user.Id, user.Version)...)
+
+		if key, ok := util.DuplicateKey(err); ok {
+			return patched, duplicate(key)
+		}
+
+		if err != nil {
+			return patched, err
+		}
+
+		count, err := result.RowsAffected()
+
+		if err != nil {
+			return patched, err
+		}
+
+		if count == 0 {
+			return patched, domain.PreconditionFailed("user has been changed")
+		}
+	}
+
+	get, err := tx.Prepare(ctx, queryGet)
+
+	if err != nil {
+		return patched, err
+	}
+
+	if err := get.QueryRowContext(ctx, user.Id).Scan(&patched.Id, &patched.Name, &patched.Email, &patched.Role, &patched.Version, &patched.CreatedAt, &patched.UpdatedAt, &patched.CreatedBy, &patched.UpdatedBy); err != nil {
+		return patched, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return entity.User{}, err
+	}
+
+	return patched, nil
+}
+
+// duplicate reports the field whose unique key a write collided with. The
+// email check ahead of writes catches most duplicates; the key catches those
+// racing it.
+func duplicate(key string) error {
+	if key == "uq_users_email" {
+		return domain.Invalid(domain.FieldError{Field: "email", Code: "unique", Message: "email is already registered"})
+	}
+
+	return domain.Conflict("user name already taken")
+}
+
+// checkEmail returns a validation error if a user other than id is
+// registered with email.
+func (ur *UserRepository) checkEmail(ctx context.Context, email string, id int) error {
+	stmt, err := ur.stmts.Prepare(ctx, queryEmail)
+
+	if err != nil {
+		return err
+	}
+
+	count := 0
+
+	if err := stmt.QueryRowContext(ctx, email, id).Scan(&count); err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return domain.Invalid(domain.FieldError{Field: "email", Code: "unique", Message: "email is already registered"})
+	}
+
+	return nil
+}
+
+// Delete deletes the user with id, provided it is still at version. The
+// user is only marked deleted, and kept until purged along with its products
+// and tokens.
+func (ur *UserRepository) Delete(ctx context.Context, id int, version int, by int) error {
+	stmt, err := ur.stmts.Prepare(ctx, queryDelete)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	result, err := stmt.ExecContext(ctx, now, now, by, id, version)
+
+	if err != nil {
+		return err
 	}
 
 	count, err := result.RowsAffected()
 
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("delete user failed")
+		return err
 	}
 
 	if count == 0 {
-		return http.StatusBadRequest, fmt.Errorf("user does not exist")
+		return ur.unmatched(ctx, id)
+	}
+
+	return nil
+}
+
+// unmatched tells why a change to the user with id at some version matched
+// no row: the user does not exist, or it has been changed since.
+func (ur *UserRepository) unmatched(ctx context.Context, id int) error {
+	stmt, err := ur.stmts.Prepare(ctx, queryVersion)
+
+	if err != nil {
+		return err
+	}
+
+	version := 0
+
+	err = stmt.QueryRowContext(ctx, id).Scan(&version)
+
+	if err == sql.ErrNoRows {
+		return domain.NotFound("user does not exist")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return domain.PreconditionFailed("user has been changed")
+}
+
+// SetRole sets the role of the user with id; by is the id of the user
+// setting it, 0 when it is set from the command line by domain.System.
+func (ur *UserRepository) SetRole(ctx context.Context, id int, role string, by int) error {
+	stmt, err := ur.stmts.Prepare(ctx, querySetRole)
+
+	if err != nil {
+		return err
+	}
+
+	var updater interface{}
+
+	if by != 0 {
+		updater = by
+	}
+
+	result, err := stmt.ExecContext(ctx, role, time.Now(), updater, id)
+
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return domain.NotFound("user does not exist")
+	}
+
+	return nil
+}
+
+// Restore undoes the deletion of the user with id, which must be deleted and
+// not purged yet.
+func (ur *UserRepository) Restore(ctx context.Context, id int, by int) error {
+	stmt, err := ur.stmts.Prepare(ctx, queryRestore)
+
+	if err != nil {
+		return err
+	}
+
+	result, err := stmt.ExecContext(ctx, time.Now(), by, id)
+
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	stmt, err = ur.stmts.Prepare(ctx, queryDeleted)
+
+	if err != nil {
+		return err
+	}
+
+	deletedAt := sql.NullTime{}
+
+	err = stmt.QueryRowContext(ctx, id).Scan(&deletedAt)
+
+	if err == sql.ErrNoRows {
+		return domain.NotFound("user does not exist")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return domain.Conflict("user is not deleted")
+}
+
+// Purge deletes for good the users deleted before the given time, with
+// their products and tokens, and returns how many users there were.
+func (ur *UserRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	stmt, err := ur.stmts.Prepare(ctx, queryPurge)
+
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := stmt.ExecContext(ctx, before)
+
+	if err != nil {
+		return 0, err
 	}
 
-	return http.StatusOK, nil
+	return result.RowsAffected()
 }
@@ -0,0 +1,63 @@
+// Package audit keeps the record of who changed which book, product or user,
+// when and how, for admins to look up.
+package audit
+
+import (
+	"context"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/entity"
+	auditRepo "rest-api/design-pattern/repository/audit"
+	"rest-api/design-pattern/util/query"
+)
+
+// ListSpec lists the fields audit records can be sorted and filtered by.
+var ListSpec = auditRepo.ListSpec
+
+type AuditService struct {
+	repository auditRepo.Audit
+}
+
+func New(repository auditRepo.Audit) *AuditService {
+	return &AuditService{repository: repository}
+}
+
+// GetAll lists audit records, which only admins may read.
+func (as *AuditService) GetAll(ctx context.Context, actor domain.Actor, opts query.Options) ([]entity.Audit, query.Page, error) {
+	if !actor.Can(domain.ReadAudit) {
+		return nil, query.Page{}, domain.Forbidden("forbidden")
+	}
+
+	return as.repository.GetAll(ctx, opts)
+}
+
+// Record stores with repository the record of the change of action actor
+// made to the resource with id, which was before until then and is after
+// since; either is nil when the resource did not exist or is deleted. A zero
+// actor stands for an anonymous request. The services record changes with
+// the audit repository of the transaction making them, so a change is kept
+// only along with its record. No permission is needed, records are made on
+// behalf of whoever made the change.
+func Record(ctx context.Context, repository auditRepo.Audit, actor domain.Actor, action string, resource string, id int, before interface{}, after interface{}) error {
+	record := entity.Audit{
+		Action:     action,
+		Resource:   resource,
+		ResourceID: id,
+		RequestID:  domain.RequestID(ctx),
+	}
+
+	if actor.Id != 0 {
+		record.ActorID = &actor.Id
+	}
+
+	var err error
+
+	if record.Before, err = snapshot(before); err != nil {
+		return err
+	}
+
+	if record.After, err = snapshot(after); err != nil {
+		return err
+	}
+
+	return repository.Create(ctx, record)
+}
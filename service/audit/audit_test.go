@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/entity"
+	auditRepo "rest-api/design-pattern/repository/audit"
+	"rest-api/design-pattern/util/query"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockAuditRepository keeps the records it is asked to store and lists them
+// back; any other call panics.
+type mockAuditRepository struct {
+	auditRepo.Audit
+	stored []entity.Audit
+}
+
+func (m *mockAuditRepository) GetAll(ctx context.Context, opts query.Options) ([]entity.Audit, query.Page, error) {
+	return m.stored, opts.NewPage(), nil
+}
+
+func (m *mockAuditRepository) Create(ctx context.Context, record entity.Audit) error {
+	m.stored = append(m.stored, record)
+
+	return nil
+}
+
+func TestGetAll(t *testing.T) {
+	t.Run("TestGetAllByAdmin", func(t *testing.T) {
+		repository := &mockAuditRepository{stored: []entity.Audit{{Id: 1, Action: entity.AuditDelete, Resource: entity.AuditProduct, ResourceID: 42}}}
+
+		records, _, err := New(repository).GetAll(context.Background(), domain.Actor{Id: 1, Role: entity.RoleAdmin}, query.Options{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, repository.stored, records)
+	})
+
+	t.Run("TestGetAllForbidden", func(t *testing.T) {
+		for _, role := range []string{entity.RoleMerchant, entity.RoleCustomer} {
+			repository := &mockAuditRepository{stored: []entity.Audit{{Id: 1}}}
+
+			records, _, err := New(repository).GetAll(context.Background(), domain.Actor{Id: 1, Role: role}, query.Options{})
+
+			assert.True(t, errors.Is(err, domain.ErrForbidden), role)
+			assert.Nil(t, records)
+		}
+	})
+}
+
+func TestRecord(t *testing.T) {
+	t.Run("TestRecordAnonymous", func(t *testing.T) {
+		repository := &mockAuditRepository{}
+		ctx := domain.WithRequestID(context.Background(), "request1")
+
+		err := Record(ctx, repository, domain.Actor{}, entity.AuditCreate, entity.AuditUser, 1, nil, entity.User{Id: 1, Name: "user1", Password: "secret"})
+
+		assert.NoError(t, err)
+		assert.Len(t, repository.stored, 1)
+		assert.Nil(t, repository.stored[0].ActorID)
+		assert.Equal(t, "request1", repository.stored[0].RequestID)
+		assert.Nil(t, repository.stored[0].Before)
+		assert.NotContains(t, string(repository.stored[0].After), "secret")
+	})
+
+	t.Run("TestRecordUnknownResource", func(t *testing.T) {
+		repository := &mockAuditRepository{}
+
+		err := Record(context.Background(), repository, domain.Actor{Id: 1}, entity.AuditCreate, entity.AuditUser, 1, nil, "user1")
+
+		assert.Error(t, err)
+		assert.Empty(t, repository.stored)
+	})
+}
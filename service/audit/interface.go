@@ -0,0 +1,12 @@
+package audit
+
+import (
+	"context"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/entity"
+	"rest-api/design-pattern/util/query"
+)
+
+type Audit interface {
+	GetAll(context.Context, domain.Actor, query.Options) ([]entity.Audit, query.Page, error)
+}
@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"rest-api/design-pattern/entity"
+	"time"
+)
+
+// The resources as audit records keep them: the fields the API serves, and
+// never a password.
+type (
+	bookSnapshot struct {
+		Id        int       `json:"id"`
+		Title     string    `json:"title"`
+		Author    string    `json:"author"`
+		Publisher string    `json:"publisher"`
+		Language  string    `json:"language"`
+		Pages     int       `json:"pages"`
+		ISBN13    string    `json:"isbn13"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+		CreatedBy *int      `json:"created_by"`
+		UpdatedBy *int      `json:"updated_by"`
+	}
+
+	productSnapshot struct {
+		Id        int       `json:"id"`
+		Merchant  string    `json:"merchant"`
+		Name      string    `json:"name"`
+		Price     int       `json:"price"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+		CreatedBy *int      `json:"created_by"`
+		UpdatedBy *int      `json:"updated_by"`
+	}
+
+	userSnapshot struct {
+		Id        int       `json:"id"`
+		Name      string    `json:"name"`
+		Email     string    `json:"email"`
+		Role      string    `json:"role"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+		CreatedBy *int      `json:"created_by"`
+		UpdatedBy *int      `json:"updated_by"`
+	}
+)
+
+func snapshot(resource interface{}) (json.RawMessage, error) {
+	var kept interface{}
+
+	switch r := resource.(type) {
+	case nil:
+		return nil, nil
+	case entity.Book:
+		kept = bookSnapshot{r.Id, r.Title, r.Author, r.Publisher, r.Language, r.Pages, r.ISBN13, r.CreatedAt, r.UpdatedAt, r.CreatedBy, r.UpdatedBy}
+	case entity.Product:
+		kept = productSnapshot{r.Id, r.Merchant, r.Name, r.Price, r.CreatedAt, r.UpdatedAt, r.CreatedBy, r.UpdatedBy}
+	case entity.User:
+		kept = userSnapshot{r.Id, r.Name, r.Email, r.Role, r.CreatedAt, r.UpdatedAt, r.CreatedBy, r.UpdatedBy}
+	default:
+		return nil, fmt.Errorf("audit of unknown resource %T", resource)
+	}
+
+	return json.Marshal(kept)
+}
@@ -0,0 +1,255 @@
+// Package auth holds the rules for sessions: a login trades a name and
+// password for a short-lived access token and a single-use refresh token,
+// which a logout revokes.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/entity"
+	authRepo "rest-api/design-pattern/repository/auth"
+	"rest-api/design-pattern/util/password"
+	"rest-api/design-pattern/util/token"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+type AuthService struct {
+	repository authRepo.Auth
+	hasher     password.Hasher
+	tokens     *token.Service
+	refreshTTL time.Duration
+}
+
+// ErrRehash reports that upgrading the hash of a password failed on a login
+// that succeeded nonetheless: the tokens returned along with it are valid,
+// and the upgrade is retried on the next login.
+var ErrRehash = errors.New("rehash password")
+
+func New(repository authRepo.Auth, hasher password.Hasher, tokens *token.Service, refreshTTL time.Duration) *AuthService {
+	return &AuthService{repository: repository, hasher: hasher, tokens: tokens, refreshTTL: refreshTTL}
+}
+
+// Login trades the name and password of a user for a token pair. When only
+// upgrading the hash of the password fails, it returns the tokens along with
+// an error wrapping ErrRehash.
+func (as *AuthService) Login(ctx context.Context, name string, plain string) (entity.Tokens, error) {
+	tokens := entity.Tokens{}
+
+	// No account may be logged into without a password, whatever its row
+	// holds.
+	if plain == "" {
+		return tokens, domain.Unauthorized("password incorrect")
+	}
+
+	eligibles, err := as.repository.GetByName(ctx, name)
+
+	if err != nil {
+		return tokens, err
+	}
+
+	if len(eligibles) == 0 {
+		return tokens, domain.Unauthorized("user does not exist")
+	}
+
+	var user *entity.User
+
+	for i := 0; i < len(eligibles) && user == nil; i++ {
+		matched, err := as.hasher.Verify(eligibles[i].Password, plain)
+
+		if err != nil {
+			return tokens, err
+		}
+
+		if matched {
+			user = &eligibles[i]
+		}
+	}
+
+	if user == nil {
+		return tokens, domain.Unauthorized("password incorrect")
+	}
+
+	var rehashErr error
+
+	if as.hasher.NeedsRehash(user.Password) {
+		if err := as.rehash(ctx, user.Id, plain); err != nil {
+			rehashErr = fmt.Errorf("%w: %v", ErrRehash, err)
+		}
+	}
+
+	family, err := randomToken()
+
+	if err != nil {
+		return tokens, err
+	}
+
+	tokens, err = as.issue(ctx, *user, family)
+
+	if err != nil {
+		return tokens, err
+	}
+
+	return tokens, rehashErr
+}
+
+// Refresh exchanges a refresh token for a new token pair. Each refresh token
+// is single use: presenting one that was already rotated or revoked is taken
+// as a sign of theft and revokes every token descending from the same login.
+func (as *AuthService) Refresh(ctx context.Context, plain string) (entity.Tokens, error) {
+	tokens := entity.Tokens{}
+
+	refresh, err := as.repository.GetRefresh(ctx, hashToken(plain))
+
+	if errors.Is(err, domain.ErrNotFound) {
+		return tokens, domain.Unauthorized("invalid refresh token")
+	}
+
+	if err != nil {
+		return tokens, err
+	}
+
+	if refresh.RevokedAt != nil {
+		return tokens, as.revokeFamily(ctx, refresh.Family)
+	}
+
+	if time.Now().After(refresh.ExpiresAt) {
+		return tokens, domain.Unauthorized("refresh token expired")
+	}
+
+	revoked, err := as.repository.RevokeRefresh(ctx, refresh.Id)
+
+	if err != nil {
+		return tokens, err
+	}
+
+	// Losing the race against a concurrent refresh of the same token means it
+	// has been used twice.
+	if !revoked {
+		return tokens, as.revokeFamily(ctx, refresh.Family)
+	}
+
+	// The role is read again so that role changes apply from the next refresh.
+	user, err := as.repository.Get(ctx, refresh.UserId)
+
+	if err != nil {
+		return tokens, domain.Unauthorized("user does not exist")
+	}
+
+	return as.issue(ctx, user, refresh.Family)
+}
+
+// Logout revokes the given access token and, when supplied, the refresh token
+// of the same user.
+func (as *AuthService) Logout(ctx context.Context, userId int, jti string, expiresAt time.Time, refresh string) error {
+	if refresh != "" {
+		if err := as.repository.RevokeOwnRefresh(ctx, hashToken(refresh), userId); err != nil {
+			return err
+		}
+	}
+
+	return as.repository.RevokeAccess(ctx, jti, expiresAt)
+}
+
+// LogoutAll revokes every refresh token and every access token of the user.
+// The access tokens are revoked by the second they were issued in, so one
+// issued in the same second as the logout is revoked too.
+func (as *AuthService) LogoutAll(ctx context.Context, userId int, jti string, expiresAt time.Time) error {
+	if err := as.repository.RevokeUserRefresh(ctx, userId); err != nil {
+		return err
+	}
+
+	if err := as.repository.RevokeUserAccess(ctx, userId, time.Now().Truncate(time.Second)); err != nil {
+		return err
+	}
+
+	return as.repository.RevokeAccess(ctx, jti, expiresAt)
+}
+
+// IsRevoked reports whether the access token with jti, issued to the user
+// with userId at issuedAt, was revoked or belongs to a user deleted since.
+func (as *AuthService) IsRevoked(ctx context.Context, jti string, userId int, issuedAt time.Time) (bool, error) {
+	return as.repository.IsRevoked(ctx, jti, userId, issuedAt)
+}
+
+func (as *AuthService) rehash(ctx context.Context, id int, plain string) error {
+	hash, err := as.hasher.Hash(plain)
+
+	if err != nil {
+		return err
+	}
+
+	return as.repository.UpdatePassword(ctx, id, hash)
+}
+
+func (as *AuthService) issue(ctx context.Context, user entity.User, family string) (entity.Tokens, error) {
+	tokens := entity.Tokens{}
+
+	access, err := as.tokens.Create(jwt.MapClaims{
+		"authorized": true,
+		"id":         user.Id,
+		"name":       user.Name,
+		"role":       user.Role,
+	})
+
+	if err != nil {
+		return tokens, err
+	}
+
+	refresh, err := randomToken()
+
+	if err != nil {
+		return tokens, err
+	}
+
+	err = as.repository.CreateRefresh(ctx, entity.RefreshToken{
+		UserId:    user.Id,
+		Family:    family,
+		Hash:      hashToken(refresh),
+		ExpiresAt: time.Now().Add(as.refreshTTL),
+	})
+
+	if err != nil {
+		return tokens, err
+	}
+
+	tokens.AccessToken = access
+	tokens.RefreshToken = refresh
+	tokens.TokenType = "Bearer"
+	tokens.ExpiresIn = int(as.tokens.TTL().Seconds())
+
+	return tokens, nil
+}
+
+func (as *AuthService) revokeFamily(ctx context.Context, family string) error {
+	if err := as.repository.RevokeFamily(ctx, family); err != nil {
+		return err
+	}
+
+	return domain.Unauthorized("refresh token reuse detected")
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken stores refresh tokens as SHA-256 digests. They carry 256 random
+// bits, so unlike passwords they need no salt or slow hash.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
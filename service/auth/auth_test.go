@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/entity"
+	authRepo "rest-api/design-pattern/repository/auth"
+	"rest-api/design-pattern/util/password"
+	"rest-api/design-pattern/util/token"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// mockAuthRepository holds one user and the refresh tokens issued to it;
+// any other call panics.
+type mockAuthRepository struct {
+	authRepo.Auth
+	user     entity.User
+	refresh  []entity.RefreshToken
+	families []string
+	cutoff   time.Time
+	revoked  []string
+}
+
+func (m *mockAuthRepository) GetByName(ctx context.Context, name string) ([]entity.User, error) {
+	if name != m.user.Name {
+		return []entity.User{}, nil
+	}
+
+	return []entity.User{m.user}, nil
+}
+
+func (m *mockAuthRepository) Get(ctx context.Context, id int) (entity.User, error) {
+	return m.user, nil
+}
+
+func (m *mockAuthRepository) CreateRefresh(ctx context.Context, refresh entity.RefreshToken) error {
+	refresh.Id = len(m.refresh) + 1
+	m.refresh = append(m.refresh, refresh)
+
+	return nil
+}
+
+func (m *mockAuthRepository) GetRefresh(ctx context.Context, hash string) (entity.RefreshToken, error) {
+	for _, refresh := range m.refresh {
+		if refresh.Hash == hash {
+			return refresh, nil
+		}
+	}
+
+	return entity.RefreshToken{}, domain.NotFound("refresh token does not exist")
+}
+
+func (m *mockAuthRepository) RevokeRefresh(ctx context.Context, id int) (bool, error) {
+	now := time.Now()
+	m.refresh[id-1].RevokedAt = &now
+
+	return true, nil
+}
+
+func (m *mockAuthRepository) RevokeFamily(ctx context.Context, family string) error {
+	m.families = append(m.families, family)
+
+	return nil
+}
+
+func (m *mockAuthRepository) RevokeUserRefresh(ctx context.Context, userId int) error {
+	for i := range m.refresh {
+		now := time.Now()
+		m.refresh[i].RevokedAt = &now
+	}
+
+	return nil
+}
+
+func (m *mockAuthRepository) RevokeUserAccess(ctx context.Context, userId int, at time.Time) error {
+	m.cutoff = at
+
+	return nil
+}
+
+func (m *mockAuthRepository) RevokeAccess(ctx context.Context, jti string, expiresAt time.Time) error {
+	m.revoked = append(m.revoked, jti)
+
+	return nil
+}
+
+func newService(t *testing.T, repository authRepo.Auth) *AuthService {
+	key, err := token.NewRandomHMACKey("test")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := token.New([]*token.Key{key}, key.ID, time.Hour)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New(repository, password.NewBcrypt(bcrypt.MinCost), tokens, 24*time.Hour)
+}
+
+func newRepository(t *testing.T) *mockAuthRepository {
+	hash, err := password.NewBcrypt(bcrypt.MinCost).Hash("password1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &mockAuthRepository{user: entity.User{Id: 1, Name: "user1", Password: hash, Role: entity.RoleCustomer}}
+}
+
+func TestLogin(t *testing.T) {
+	t.Run("TestLoginSuccess", func(t *testing.T) {
+		repository := newRepository(t)
+
+		tokens, err := newService(t, repository).Login(context.Background(), "user1", "password1")
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, tokens.AccessToken)
+		assert.Equal(t, 3600, tokens.ExpiresIn)
+		assert.Len(t, repository.refresh, 1)
+		assert.Equal(t, hashToken(tokens.RefreshToken), repository.refresh[0].Hash)
+	})
+
+	t.Run("TestLoginFail", func(t *testing.T) {
+		for _, login := range [][2]string{{"user2", "password1"}, {"user1", "password2"}, {"user1", ""}} {
+			repository := newRepository(t)
+
+			_, err := newService(t, repository).Login(context.Background(), login[0], login[1])
+
+			assert.True(t, errors.Is(err, domain.ErrUnauthorized), login)
+			assert.Empty(t, repository.refresh)
+		}
+	})
+}
+
+func TestRefresh(t *testing.T) {
+	t.Run("TestRefreshRotates", func(t *testing.T) {
+		repository := newRepository(t)
+		service := newService(t, repository)
+
+		first, _ := service.Login(context.Background(), "user1", "password1")
+		second, err := service.Refresh(context.Background(), first.RefreshToken)
+
+		assert.NoError(t, err)
+		assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+		assert.NotNil(t, repository.refresh[0].RevokedAt)
+		assert.Equal(t, repository.refresh[0].Family, repository.refresh[1].Family)
+	})
+
+	t.Run("TestRefreshReuseRevokesFamily", func(t *testing.T) {
+		repository := newRepository(t)
+		service := newService(t, repository)
+
+		first, _ := service.Login(context.Background(), "user1", "password1")
+		service.Refresh(context.Background(), first.RefreshToken)
+		_, err := service.Refresh(context.Background(), first.RefreshToken)
+
+		assert.True(t, errors.Is(err, domain.ErrUnauthorized))
+		assert.Equal(t, []string{repository.refresh[0].Family}, repository.families)
+	})
+
+	t.Run("TestRefreshUnknown", func(t *testing.T) {
+		_, err := newService(t, newRepository(t)).Refresh(context.Background(), "unknown")
+
+		assert.True(t, errors.Is(err, domain.ErrUnauthorized))
+	})
+}
+
+func TestLogoutAll(t *testing.T) {
+	t.Run("TestLogoutAllRevokesAccessTokens", func(t *testing.T) {
+		repository := newRepository(t)
+		service := newService(t, repository)
+
+		service.Login(context.Background(), "user1", "password1")
+		issuedAt := time.Now().Truncate(time.Second)
+
+		err := service.LogoutAll(context.Background(), 1, "jti1", time.Now().Add(time.Hour))
+
+		assert.NoError(t, err)
+		assert.NotNil(t, repository.refresh[0].RevokedAt)
+		assert.Equal(t, []string{"jti1"}, repository.revoked)
+		// A token is only accepted when issued after the cutoff.
+		assert.False(t, repository.cutoff.Before(issuedAt))
+	})
+}
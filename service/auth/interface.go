@@ -0,0 +1,15 @@
+package auth
+
+import (
+	"context"
+	"rest-api/design-pattern/entity"
+	"time"
+)
+
+type Auth interface {
+	Login(context.Context, string, string) (entity.Tokens, error)
+	Refresh(context.Context, string) (entity.Tokens, error)
+	Logout(context.Context, int, string, time.Time, string) error
+	LogoutAll(context.Context, int, string, time.Time) error
+	IsRevoked(context.Context, string, int, time.Time) (bool, error)
+}
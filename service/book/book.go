@@ -0,0 +1,185 @@
+// Package book holds the rules for managing books: anyone may read them,
+// only actors allowed to may change them, and only into valid books. Every
+// change is audited along with it.
+package book
+
+import (
+	"context"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/entity"
+	bookRepo "rest-api/design-pattern/repository/book"
+	"rest-api/design-pattern/repository/transaction"
+	"rest-api/design-pattern/service/audit"
+	"rest-api/design-pattern/service/validation"
+	"rest-api/design-pattern/util/query"
+)
+
+// ListSpec lists the fields books can be sorted and filtered by.
+var ListSpec = bookRepo.ListSpec
+
+type BookService struct {
+	repository   bookRepo.Book
+	transactions transaction.Runner
+	validator    *validation.Validator
+}
+
+func New(repository bookRepo.Book, transactions transaction.Runner) *BookService {
+	return &BookService{repository: repository, transactions: transactions, validator: validation.New()}
+}
+
+// GetAll lists books, deleted ones included only if the options ask for
+// them and the actor is allowed to see them.
+func (bs *BookService) GetAll(ctx context.Context, actor domain.Actor, opts query.Options) ([]entity.Book, query.Page, error) {
+	if opts.IncludeDeleted && !actor.Can(domain.ListDeleted) {
+		return nil, query.Page{}, domain.Forbidden("forbidden")
+	}
+
+	return bs.repository.GetAll(ctx, opts)
+}
+
+func (bs *BookService) Get(ctx context.Context, id int) (entity.Book, error) {
+	return bs.repository.Get(ctx, id)
+}
+
+func (bs *BookService) Search(ctx context.Context, q string, opts query.Options) ([]entity.BookMatch, query.Page, error) {
+	return bs.repository.Search(ctx, q, opts)
+}
+
+func (bs *BookService) Create(ctx context.Context, actor domain.Actor, book entity.Book) (entity.Book, error) {
+	if !actor.Can(domain.CreateBook) {
+		return entity.Book{}, domain.Forbidden("forbidden")
+	}
+
+	book.CreatedBy = &actor.Id
+
+	if err := bs.validator.Validate(&book); err != nil {
+		return entity.Book{}, err
+	}
+
+	created := entity.Book{}
+
+	err := bs.transactions.Do(ctx, func(repos transaction.Repositories) error {
+		var err error
+
+		if created, err = repos.Books.Create(ctx, book); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, repos.Audits, actor, entity.AuditCreate, entity.AuditBook, created.Id, nil, created)
+	})
+
+	return created, err
+}
+
+// Update replaces the book with the id of book and returns it as stored.
+func (bs *BookService) Update(ctx context.Context, actor domain.Actor, book entity.Book) (entity.Book, error) {
+	if !actor.Can(domain.UpdateBook) {
+		return entity.Book{}, domain.Forbidden("forbidden")
+	}
+
+	book.UpdatedBy = &actor.Id
+
+	if err := bs.validator.Validate(&book); err != nil {
+		return entity.Book{}, err
+	}
+
+	updated := entity.Book{}
+
+	err := bs.transactions.Do(ctx, func(repos transaction.Repositories) error {
+		before, err := repos.Books.Get(ctx, book.Id)
+
+		if err != nil {
+			return err
+		}
+
+		if err := repos.Books.Update(ctx, book); err != nil {
+			return err
+		}
+
+		if updated, err = repos.Books.Get(ctx, book.Id); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, repos.Audits, actor, entity.AuditUpdate, entity.AuditBook, book.Id, before, updated)
+	})
+
+	return updated, err
+}
+
+// Patch changes the fields of the book with the id of book named in fields
+// to their values in book and returns it as stored.
+func (bs *BookService) Patch(ctx context.Context, actor domain.Actor, book entity.Book, fields []string) (entity.Book, error) {
+	if !actor.Can(domain.UpdateBook) {
+		return entity.Book{}, domain.Forbidden("forbidden")
+	}
+
+	book.UpdatedBy = &actor.Id
+
+	if err := bs.validator.ValidatePatch(&book, fields, bookRepo.Patchable); err != nil {
+		return entity.Book{}, err
+	}
+
+	patched := entity.Book{}
+
+	err := bs.transactions.Do(ctx, func(repos transaction.Repositories) error {
+		before, err := repos.Books.Get(ctx, book.Id)
+
+		if err != nil {
+			return err
+		}
+
+		if patched, err = repos.Books.Patch(ctx, book, fields); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, repos.Audits, actor, entity.AuditPatch, entity.AuditBook, book.Id, before, patched)
+	})
+
+	return patched, err
+}
+
+// Delete deletes the book with id, unless it has changed since version.
+func (bs *BookService) Delete(ctx context.Context, actor domain.Actor, id int, version int) error {
+	if !actor.Can(domain.DeleteBook) {
+		return domain.Forbidden("forbidden")
+	}
+
+	return bs.transactions.Do(ctx, func(repos transaction.Repositories) error {
+		before, err := repos.Books.Get(ctx, id)
+
+		if err != nil {
+			return err
+		}
+
+		if err := repos.Books.Delete(ctx, id, version, actor.Id); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, repos.Audits, actor, entity.AuditDelete, entity.AuditBook, id, before, nil)
+	})
+}
+
+// Restore undoes the deletion of the book with id and returns it as stored.
+func (bs *BookService) Restore(ctx context.Context, actor domain.Actor, id int) (entity.Book, error) {
+	if !actor.Can(domain.RestoreDeleted) {
+		return entity.Book{}, domain.Forbidden("forbidden")
+	}
+
+	restored := entity.Book{}
+
+	err := bs.transactions.Do(ctx, func(repos transaction.Repositories) error {
+		if err := repos.Books.Restore(ctx, id, actor.Id); err != nil {
+			return err
+		}
+
+		var err error
+
+		if restored, err = repos.Books.Get(ctx, id); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, repos.Audits, actor, entity.AuditRestore, entity.AuditBook, id, nil, restored)
+	})
+
+	return restored, err
+}
@@ -0,0 +1,189 @@
+package book
+
+import (
+	"context"
+	"errors"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/entity"
+	auditRepo "rest-api/design-pattern/repository/audit"
+	bookRepo "rest-api/design-pattern/repository/book"
+	"rest-api/design-pattern/repository/transaction"
+	"rest-api/design-pattern/util/query"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockBookRepository records the books it is asked to create and keeps the
+// last one stored to read back, deleted or not; any other call panics.
+type mockBookRepository struct {
+	bookRepo.Book
+	created []entity.Book
+	stored  entity.Book
+}
+
+func (m *mockBookRepository) Create(ctx context.Context, book entity.Book) (entity.Book, error) {
+	m.created = append(m.created, book)
+
+	return entity.Book{Id: 1, Title: book.Title}, nil
+}
+
+func (m *mockBookRepository) Get(ctx context.Context, id int) (entity.Book, error) {
+	return m.stored, nil
+}
+
+func (m *mockBookRepository) Update(ctx context.Context, book entity.Book) error {
+	m.stored = book
+
+	return nil
+}
+
+func (m *mockBookRepository) Patch(ctx context.Context, book entity.Book, fields []string) (entity.Book, error) {
+	m.stored.Title = book.Title
+
+	return m.stored, nil
+}
+
+func (m *mockBookRepository) Delete(ctx context.Context, id int, version int, by int) error {
+	return nil
+}
+
+func (m *mockBookRepository) Restore(ctx context.Context, id int, by int) error {
+	return nil
+}
+
+// mockAuditRepository keeps the records it is asked to store, or fails with
+// err; any other call panics.
+type mockAuditRepository struct {
+	auditRepo.Audit
+	stored []entity.Audit
+	err    error
+}
+
+func (m *mockAuditRepository) Create(ctx context.Context, record entity.Audit) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.stored = append(m.stored, record)
+
+	return nil
+}
+
+// newService returns a service on repository whose changes are audited to
+// audits.
+func newService(repository *mockBookRepository, audits *mockAuditRepository) *BookService {
+	return New(repository, transaction.Repositories{Books: repository, Audits: audits})
+}
+
+var validBook = entity.Book{
+	Title:     "title1",
+	Author:    "author1",
+	Publisher: "publisher1",
+	Language:  "language1",
+	Pages:     100,
+	ISBN13:    "9780134190440",
+}
+
+func TestCreate(t *testing.T) {
+	t.Run("TestCreate", func(t *testing.T) {
+		repository := &mockBookRepository{}
+
+		created, err := newService(repository, &mockAuditRepository{}).Create(context.Background(), domain.Actor{Id: 1, Role: entity.RoleAdmin}, validBook)
+
+		stored := validBook
+		creator := 1
+		stored.CreatedBy = &creator
+
+		assert.NoError(t, err)
+		assert.Equal(t, entity.Book{Id: 1, Title: "title1"}, created)
+		assert.Equal(t, []entity.Book{stored}, repository.created)
+	})
+
+	t.Run("TestCreateForbidden", func(t *testing.T) {
+		repository := &mockBookRepository{}
+
+		_, err := newService(repository, &mockAuditRepository{}).Create(context.Background(), domain.Actor{Id: 1, Role: entity.RoleMerchant}, validBook)
+
+		assert.True(t, errors.Is(err, domain.ErrForbidden))
+		assert.Empty(t, repository.created)
+	})
+
+	t.Run("TestCreateInvalid", func(t *testing.T) {
+		repository := &mockBookRepository{}
+		book := validBook
+		book.ISBN13 = "9780134190441"
+
+		_, err := newService(repository, &mockAuditRepository{}).Create(context.Background(), domain.Actor{Id: 1, Role: entity.RoleAdmin}, book)
+
+		assert.True(t, errors.Is(err, domain.ErrValidation))
+		assert.Empty(t, repository.created)
+	})
+}
+
+func TestGetAll(t *testing.T) {
+	t.Run("TestGetAllDeletedForbidden", func(t *testing.T) {
+		repository := &mockBookRepository{}
+
+		_, _, err := newService(repository, &mockAuditRepository{}).GetAll(context.Background(), domain.Actor{}, query.Options{IncludeDeleted: true})
+
+		assert.True(t, errors.Is(err, domain.ErrForbidden))
+	})
+}
+
+// TEST AUDIT
+
+func TestAudit(t *testing.T) {
+	admin := domain.Actor{Id: 1, Role: entity.RoleAdmin}
+	ctx := domain.WithRequestID(context.Background(), "request1")
+
+	t.Run("TestAuditChanges", func(t *testing.T) {
+		repository := &mockBookRepository{stored: entity.Book{Id: 1, Title: "title0"}}
+		audits := &mockAuditRepository{}
+		service := newService(repository, audits)
+
+		_, err := service.Create(ctx, admin, validBook)
+		assert.NoError(t, err)
+
+		updated := validBook
+		updated.Id = 1
+		_, err = service.Update(ctx, admin, updated)
+		assert.NoError(t, err)
+
+		_, err = service.Patch(ctx, admin, entity.Book{Id: 1, Title: "title2"}, []string{"title"})
+		assert.NoError(t, err)
+
+		assert.NoError(t, service.Delete(ctx, admin, 1, 1))
+
+		_, err = service.Restore(ctx, admin, 1)
+		assert.NoError(t, err)
+
+		actions := []string{entity.AuditCreate, entity.AuditUpdate, entity.AuditPatch, entity.AuditDelete, entity.AuditRestore}
+
+		assert.Len(t, audits.stored, len(actions))
+
+		for i, record := range audits.stored {
+			assert.Equal(t, actions[i], record.Action)
+			assert.Equal(t, entity.AuditBook, record.Resource)
+			assert.Equal(t, 1, record.ResourceID)
+			assert.Equal(t, &admin.Id, record.ActorID)
+			assert.Equal(t, "request1", record.RequestID)
+		}
+
+		assert.Nil(t, audits.stored[0].Before)
+		assert.Contains(t, string(audits.stored[1].Before), `"title":"title0"`)
+		assert.Contains(t, string(audits.stored[1].After), `"title":"title1"`)
+		assert.Contains(t, string(audits.stored[2].After), `"title":"title2"`)
+		assert.Contains(t, string(audits.stored[3].Before), `"title":"title2"`)
+		assert.Nil(t, audits.stored[3].After)
+		assert.Nil(t, audits.stored[4].Before)
+	})
+
+	t.Run("TestAuditFailed", func(t *testing.T) {
+		failed := errors.New("audit failed")
+
+		err := newService(&mockBookRepository{}, &mockAuditRepository{err: failed}).Delete(ctx, admin, 1, 1)
+
+		assert.True(t, errors.Is(err, failed))
+	})
+}
@@ -0,0 +1,19 @@
+package book
+
+import (
+	"context"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/entity"
+	"rest-api/design-pattern/util/query"
+)
+
+type Book interface {
+	GetAll(context.Context, domain.Actor, query.Options) ([]entity.Book, query.Page, error)
+	Get(context.Context, int) (entity.Book, error)
+	Create(context.Context, domain.Actor, entity.Book) (entity.Book, error)
+	Update(context.Context, domain.Actor, entity.Book) (entity.Book, error)
+	Patch(context.Context, domain.Actor, entity.Book, []string) (entity.Book, error)
+	Delete(context.Context, domain.Actor, int, int) error
+	Restore(context.Context, domain.Actor, int) (entity.Book, error)
+	Search(context.Context, string, query.Options) ([]entity.BookMatch, query.Page, error)
+}
@@ -0,0 +1,18 @@
+package product
+
+import (
+	"context"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/entity"
+	"rest-api/design-pattern/util/query"
+)
+
+type Product interface {
+	GetAll(context.Context, domain.Actor, query.Options) ([]entity.Product, query.Page, error)
+	Get(context.Context, int) (entity.Product, error)
+	Create(context.Context, domain.Actor, entity.Product) (entity.Product, error)
+	Update(context.Context, domain.Actor, entity.Product) (entity.Product, error)
+	Patch(context.Context, domain.Actor, entity.Product, []string) (entity.Product, error)
+	Delete(context.Context, domain.Actor, int, int) error
+	Restore(context.Context, domain.Actor, int) (entity.Product, error)
+}
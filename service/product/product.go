@@ -0,0 +1,189 @@
+// Package product holds the rules for managing products: anyone may read
+// them, and merchants and admins may register products of their own and
+// change only those. Every change is audited along with it.
+package product
+
+import (
+	"context"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/entity"
+	productRepo "rest-api/design-pattern/repository/product"
+	"rest-api/design-pattern/repository/transaction"
+	"rest-api/design-pattern/service/audit"
+	"rest-api/design-pattern/service/validation"
+	"rest-api/design-pattern/util/query"
+)
+
+// ListSpec lists the fields products can be sorted and filtered by.
+var ListSpec = productRepo.ListSpec
+
+type ProductService struct {
+	repository   productRepo.Product
+	transactions transaction.Runner
+	validator    *validation.Validator
+}
+
+func New(repository productRepo.Product, transactions transaction.Runner) *ProductService {
+	return &ProductService{repository: repository, transactions: transactions, validator: validation.New()}
+}
+
+// GetAll lists products, deleted ones included only if the options ask for
+// them and the actor is allowed to see them.
+func (ps *ProductService) GetAll(ctx context.Context, actor domain.Actor, opts query.Options) ([]entity.Product, query.Page, error) {
+	if opts.IncludeDeleted && !actor.Can(domain.ListDeleted) {
+		return nil, query.Page{}, domain.Forbidden("forbidden")
+	}
+
+	return ps.repository.GetAll(ctx, opts)
+}
+
+func (ps *ProductService) Get(ctx context.Context, id int) (entity.Product, error) {
+	return ps.repository.Get(ctx, id)
+}
+
+// Create registers product as one of the actor's.
+func (ps *ProductService) Create(ctx context.Context, actor domain.Actor, product entity.Product) (entity.Product, error) {
+	if !actor.Can(domain.CreateProduct) {
+		return entity.Product{}, domain.Forbidden("forbidden")
+	}
+
+	product.UserID = actor.Id
+	product.CreatedBy = &actor.Id
+
+	if err := ps.validator.Validate(&product); err != nil {
+		return entity.Product{}, err
+	}
+
+	created := entity.Product{}
+
+	err := ps.transactions.Do(ctx, func(repos transaction.Repositories) error {
+		var err error
+
+		if created, err = repos.Products.Create(ctx, product); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, repos.Audits, actor, entity.AuditCreate, entity.AuditProduct, created.Id, nil, created)
+	})
+
+	return created, err
+}
+
+// Update replaces the product with the id of product, which must be one of
+// the actor's, and returns it as stored.
+func (ps *ProductService) Update(ctx context.Context, actor domain.Actor, product entity.Product) (entity.Product, error) {
+	if !actor.Can(domain.UpdateProduct) {
+		return entity.Product{}, domain.Forbidden("forbidden")
+	}
+
+	product.UserID = actor.Id
+	product.UpdatedBy = &actor.Id
+
+	if err := ps.validator.Validate(&product); err != nil {
+		return entity.Product{}, err
+	}
+
+	updated := entity.Product{}
+
+	err := ps.transactions.Do(ctx, func(repos transaction.Repositories) error {
+		before, err := repos.Products.Get(ctx, product.Id)
+
+		if err != nil {
+			return err
+		}
+
+		if err := repos.Products.Update(ctx, product); err != nil {
+			return err
+		}
+
+		if updated, err = repos.Products.Get(ctx, product.Id); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, repos.Audits, actor, entity.AuditUpdate, entity.AuditProduct, product.Id, before, updated)
+	})
+
+	return updated, err
+}
+
+// Patch changes the fields of the product with the id of product named in
+// fields to their values in product, which must be one of the actor's, and
+// returns it as stored.
+func (ps *ProductService) Patch(ctx context.Context, actor domain.Actor, product entity.Product, fields []string) (entity.Product, error) {
+	if !actor.Can(domain.UpdateProduct) {
+		return entity.Product{}, domain.Forbidden("forbidden")
+	}
+
+	product.UserID = actor.Id
+	product.UpdatedBy = &actor.Id
+
+	if err := ps.validator.ValidatePatch(&product, fields, productRepo.Patchable); err != nil {
+		return entity.Product{}, err
+	}
+
+	patched := entity.Product{}
+
+	err := ps.transactions.Do(ctx, func(repos transaction.Repositories) error {
+		before, err := repos.Products.Get(ctx, product.Id)
+
+		if err != nil {
+			return err
+		}
+
+		if patched, err = repos.Products.Patch(ctx, product, fields); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, repos.Audits, actor, entity.AuditPatch, entity.AuditProduct, product.Id, before, patched)
+	})
+
+	return patched, err
+}
+
+// Delete deletes the product with id, which must be one of the actor's and
+// not have changed since version.
+func (ps *ProductService) Delete(ctx context.Context, actor domain.Actor, id int, version int) error {
+	if !actor.Can(domain.DeleteProduct) {
+		return domain.Forbidden("forbidden")
+	}
+
+	return ps.transactions.Do(ctx, func(repos transaction.Repositories) error {
+		before, err := repos.Products.Get(ctx, id)
+
+		if err != nil {
+			return err
+		}
+
+		if err := repos.Products.Delete(ctx, id, actor.Id, version); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, repos.Audits, actor, entity.AuditDelete, entity.AuditProduct, id, before, nil)
+	})
+}
+
+// Restore undoes the deletion of the product with id and returns it as
+// stored. A product deleted along with its user is restored with the user.
+func (ps *ProductService) Restore(ctx context.Context, actor domain.Actor, id int) (entity.Product, error) {
+	if !actor.Can(domain.RestoreDeleted) {
+		return entity.Product{}, domain.Forbidden("forbidden")
+	}
+
+	restored := entity.Product{}
+
+	err := ps.transactions.Do(ctx, func(repos transaction.Repositories) error {
+		if err := repos.Products.Restore(ctx, id, actor.Id); err != nil {
+			return err
+		}
+
+		var err error
+
+		if restored, err = repos.Products.Get(ctx, id); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, repos.Audits, actor, entity.AuditRestore, entity.AuditProduct, id, nil, restored)
+	})
+
+	return restored, err
+}
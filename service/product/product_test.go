@@ -0,0 +1,163 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/entity"
+	auditRepo "rest-api/design-pattern/repository/audit"
+	productRepo "rest-api/design-pattern/repository/product"
+	"rest-api/design-pattern/repository/transaction"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockProductRepository records the products it is asked to store and the
+// owners of those it is asked to delete, and reads back the last one stored,
+// if any, as sold by merchant1; any other call panics.
+type mockProductRepository struct {
+	productRepo.Product
+	stored []entity.Product
+	owners []int
+}
+
+func (m *mockProductRepository) Create(ctx context.Context, product entity.Product) (entity.Product, error) {
+	m.stored = append(m.stored, product)
+
+	return entity.Product{Id: 1, Name: product.Name, Price: product.Price}, nil
+}
+
+func (m *mockProductRepository) Update(ctx context.Context, product entity.Product) error {
+	m.stored = append(m.stored, product)
+
+	return nil
+}
+
+func (m *mockProductRepository) Get(ctx context.Context, id int) (entity.Product, error) {
+	product := entity.Product{Id: id}
+
+	if len(m.stored) > 0 {
+		product = m.stored[len(m.stored)-1]
+	}
+
+	product.Merchant = "merchant1"
+
+	return product, nil
+}
+
+func (m *mockProductRepository) Delete(ctx context.Context, id int, userid int, version int) error {
+	m.owners = append(m.owners, userid)
+
+	return nil
+}
+
+// mockAuditRepository keeps the records it is asked to store; any other call
+// panics.
+type mockAuditRepository struct {
+	auditRepo.Audit
+	stored []entity.Audit
+}
+
+func (m *mockAuditRepository) Create(ctx context.Context, record entity.Audit) error {
+	m.stored = append(m.stored, record)
+
+	return nil
+}
+
+// newService returns a service on repository whose changes are audited to
+// audits.
+func newService(repository *mockProductRepository, audits *mockAuditRepository) *ProductService {
+	return New(repository, transaction.Repositories{Products: repository, Audits: audits})
+}
+
+func TestCreate(t *testing.T) {
+	t.Run("TestCreateOwnedByActor", func(t *testing.T) {
+		repository := &mockProductRepository{}
+
+		_, err := newService(repository, &mockAuditRepository{}).Create(context.Background(), domain.Actor{Id: 3, Role: entity.RoleMerchant}, entity.Product{UserID: 9, Name: "product1", Price: 100})
+
+		actor := 3
+
+		assert.NoError(t, err)
+		assert.Equal(t, []entity.Product{{UserID: 3, Name: "product1", Price: 100, CreatedBy: &actor}}, repository.stored)
+	})
+
+	t.Run("TestCreateForbidden", func(t *testing.T) {
+		repository := &mockProductRepository{}
+
+		_, err := newService(repository, &mockAuditRepository{}).Create(context.Background(), domain.Actor{Id: 3, Role: entity.RoleCustomer}, entity.Product{Name: "product1", Price: 100})
+
+		assert.True(t, errors.Is(err, domain.ErrForbidden))
+		assert.Empty(t, repository.stored)
+	})
+
+	t.Run("TestCreateInvalid", func(t *testing.T) {
+		repository := &mockProductRepository{}
+
+		_, err := newService(repository, &mockAuditRepository{}).Create(context.Background(), domain.Actor{Id: 3, Role: entity.RoleMerchant}, entity.Product{Name: "product1", Price: -1})
+
+		assert.True(t, errors.Is(err, domain.ErrValidation))
+		assert.Empty(t, repository.stored)
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	t.Run("TestUpdateOwnedByActor", func(t *testing.T) {
+		repository := &mockProductRepository{}
+
+		updated, err := newService(repository, &mockAuditRepository{}).Update(context.Background(), domain.Actor{Id: 3, Role: entity.RoleMerchant}, entity.Product{Id: 1, Name: "product1", Price: 100})
+
+		actor := 3
+
+		assert.NoError(t, err)
+		assert.Equal(t, entity.Product{Id: 1, UserID: 3, Name: "product1", Price: 100, Merchant: "merchant1", UpdatedBy: &actor}, updated)
+		assert.Equal(t, []entity.Product{{Id: 1, UserID: 3, Name: "product1", Price: 100, UpdatedBy: &actor}}, repository.stored)
+	})
+}
+
+func TestDelete(t *testing.T) {
+	t.Run("TestDeleteOwnedByActor", func(t *testing.T) {
+		repository := &mockProductRepository{}
+
+		err := newService(repository, &mockAuditRepository{}).Delete(context.Background(), domain.Actor{Id: 3, Role: entity.RoleMerchant}, 1, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int{3}, repository.owners)
+	})
+}
+
+// TEST AUDIT
+
+func TestAudit(t *testing.T) {
+	t.Run("TestAuditChanges", func(t *testing.T) {
+		merchant := domain.Actor{Id: 3, Role: entity.RoleMerchant}
+		repository := &mockProductRepository{}
+		audits := &mockAuditRepository{}
+		service := newService(repository, audits)
+
+		_, err := service.Create(context.Background(), merchant, entity.Product{Name: "product1", Price: 100})
+		assert.NoError(t, err)
+
+		_, err = service.Update(context.Background(), merchant, entity.Product{Id: 1, Name: "product2", Price: 200})
+		assert.NoError(t, err)
+
+		assert.NoError(t, service.Delete(context.Background(), merchant, 1, 1))
+
+		actions := []string{entity.AuditCreate, entity.AuditUpdate, entity.AuditDelete}
+
+		assert.Len(t, audits.stored, len(actions))
+
+		for i, record := range audits.stored {
+			assert.Equal(t, actions[i], record.Action)
+			assert.Equal(t, entity.AuditProduct, record.Resource)
+			assert.Equal(t, 1, record.ResourceID)
+			assert.Equal(t, &merchant.Id, record.ActorID)
+		}
+
+		assert.Contains(t, string(audits.stored[1].Before), `"name":"product1"`)
+		assert.Contains(t, string(audits.stored[1].After), `"name":"product2"`)
+		assert.Contains(t, string(audits.stored[2].Before), `"name":"product2"`)
+		assert.Nil(t, audits.stored[2].After)
+	})
+}
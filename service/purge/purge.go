@@ -0,0 +1,100 @@
+// Package purge deletes for good the users, books and products deleted
+// longer ago than a retention period, until then kept to be restored, and the
+// refresh tokens and access token revocations that have expired.
+package purge
+
+import (
+	"context"
+	"time"
+)
+
+// Repository is a repository of soft deleted or expiring rows, purging those
+// deleted or expired before the given time.
+type Repository interface {
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Logger reports the outcome of scheduled purges; echo.Logger is one.
+type Logger interface {
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+type PurgeService struct {
+	retention    time.Duration
+	repositories []Repository
+	names        []string
+	tokens       Repository
+	now          func() time.Time
+}
+
+// New returns a service purging what was deleted more than retention ago
+// from products, books and users, in this order, then the tokens that have
+// expired.
+func New(retention time.Duration, products Repository, books Repository, users Repository, tokens Repository) *PurgeService {
+	return &PurgeService{
+		retention:    retention,
+		repositories: []Repository{products, books, users},
+		names:        []string{"products", "books", "users"},
+		tokens:       tokens,
+		now:          time.Now,
+	}
+}
+
+// Purge runs one purge and returns how many rows it deleted from each
+// repository, by name, the tokens under "tokens". It stops at the first
+// repository failing.
+func (ps *PurgeService) Purge(ctx context.Context) (map[string]int64, error) {
+	now := ps.now()
+	before := now.Add(-ps.retention)
+	purged := map[string]int64{}
+
+	for i, repository := range ps.repositories {
+		count, err := repository.Purge(ctx, before)
+
+		if err != nil {
+			return purged, err
+		}
+
+		purged[ps.names[i]] = count
+	}
+
+	count, err := ps.tokens.Purge(ctx, now)
+
+	if err != nil {
+		return purged, err
+	}
+
+	purged["tokens"] = count
+
+	return purged, nil
+}
+
+// Schedule purges every interval until ctx is done, reporting each purge
+// that deleted something and each failure to logger.
+func (ps *PurgeService) Schedule(ctx context.Context, interval time.Duration, logger Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := ps.Purge(ctx)
+
+			if err != nil {
+				logger.Errorf("purge failed: %v", err)
+				continue
+			}
+
+			if purged["products"]+purged["books"]+purged["users"] > 0 {
+				logger.Infof("purged %d products, %d books and %d users", purged["products"], purged["books"], purged["users"])
+			}
+
+			if purged["tokens"] > 0 {
+				logger.Infof("purged %d expired tokens", purged["tokens"])
+			}
+		}
+	}
+}
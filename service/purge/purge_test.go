@@ -0,0 +1,83 @@
+package purge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockRepository records the times it is asked to purge before and purges
+// count rows each time, or fails with err.
+type mockRepository struct {
+	before []time.Time
+	count  int64
+	err    error
+}
+
+func (m *mockRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	m.before = append(m.before, before)
+
+	return m.count, m.err
+}
+
+type mockLogger struct {
+	infos  []string
+	errors []string
+}
+
+func (m *mockLogger) Infof(format string, args ...interface{}) {
+	m.infos = append(m.infos, format)
+}
+
+func (m *mockLogger) Errorf(format string, args ...interface{}) {
+	m.errors = append(m.errors, format)
+}
+
+var now = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func TestPurge(t *testing.T) {
+	t.Run("TestPurge", func(t *testing.T) {
+		products, books, users, tokens := &mockRepository{count: 3}, &mockRepository{count: 2}, &mockRepository{count: 1}, &mockRepository{count: 5}
+
+		service := New(24*time.Hour, products, books, users, tokens)
+		service.now = func() time.Time { return now }
+
+		purged, err := service.Purge(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int64{"products": 3, "books": 2, "users": 1, "tokens": 5}, purged)
+		assert.Equal(t, []time.Time{now.Add(-24 * time.Hour)}, products.before)
+		assert.Equal(t, []time.Time{now.Add(-24 * time.Hour)}, books.before)
+		assert.Equal(t, []time.Time{now.Add(-24 * time.Hour)}, users.before)
+		assert.Equal(t, []time.Time{now}, tokens.before)
+	})
+
+	t.Run("TestPurgeFail", func(t *testing.T) {
+		products, books, users, tokens := &mockRepository{count: 3}, &mockRepository{err: assert.AnError}, &mockRepository{}, &mockRepository{}
+
+		purged, err := New(24*time.Hour, products, books, users, tokens).Purge(context.Background())
+
+		assert.Equal(t, assert.AnError, err)
+		assert.Equal(t, map[string]int64{"products": 3}, purged)
+		assert.Empty(t, users.before)
+		assert.Empty(t, tokens.before)
+	})
+}
+
+func TestSchedule(t *testing.T) {
+	t.Run("TestSchedule", func(t *testing.T) {
+		products, books, users := &mockRepository{count: 1}, &mockRepository{}, &mockRepository{err: assert.AnError}
+		logger := &mockLogger{}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 35*time.Millisecond)
+		defer cancel()
+
+		New(time.Hour, products, books, users, &mockRepository{}).Schedule(ctx, 10*time.Millisecond, logger)
+
+		assert.NotEmpty(t, users.before)
+		assert.Len(t, logger.errors, len(users.before))
+		assert.Empty(t, logger.infos)
+	})
+}
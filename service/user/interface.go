@@ -0,0 +1,19 @@
+package user
+
+import (
+	"context"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/entity"
+	"rest-api/design-pattern/util/query"
+)
+
+type User interface {
+	GetAll(context.Context, domain.Actor, query.Options) ([]entity.User, query.Page, error)
+	Get(context.Context, int) (entity.User, error)
+	Register(context.Context, entity.User) (entity.User, error)
+	Update(context.Context, domain.Actor, entity.User) (entity.User, error)
+	Patch(context.Context, domain.Actor, entity.User, []string) (entity.User, error)
+	Delete(context.Context, domain.Actor, int, int) error
+	Restore(context.Context, domain.Actor, int) (entity.User, error)
+	SetRole(context.Context, domain.Actor, int, string) error
+}
@@ -0,0 +1,268 @@
+// Package user holds the rules for managing users: anyone may register as a
+// customer, users and admins on their behalf may change or delete them, and
+// only actors allowed to may grant other roles. Every change is audited
+// along with it.
+package user
+
+import (
+	"context"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/entity"
+	"rest-api/design-pattern/repository/transaction"
+	userRepo "rest-api/design-pattern/repository/user"
+	"rest-api/design-pattern/service/audit"
+	"rest-api/design-pattern/service/validation"
+	"rest-api/design-pattern/util/query"
+)
+
+// ListSpec lists the fields users can be sorted and filtered by.
+var ListSpec = userRepo.ListSpec
+
+type UserService struct {
+	repository   userRepo.User
+	transactions transaction.Runner
+	validator    *validation.Validator
+}
+
+func New(repository userRepo.User, transactions transaction.Runner) *UserService {
+	return &UserService{repository: repository, transactions: transactions, validator: validation.New()}
+}
+
+// GetAll lists users, deleted ones included only if the options ask for
+// them and the actor is allowed to see them.
+func (us *UserService) GetAll(ctx context.Context, actor domain.Actor, opts query.Options) ([]entity.User, query.Page, error) {
+	if opts.IncludeDeleted && !actor.Can(domain.ListDeleted) {
+		return nil, query.Page{}, domain.Forbidden("forbidden")
+	}
+
+	return us.repository.GetAll(ctx, opts)
+}
+
+func (us *UserService) Get(ctx context.Context, id int) (entity.User, error) {
+	return us.repository.Get(ctx, id)
+}
+
+// Register creates user as a customer, other roles are granted by an admin.
+// Users register themselves, so nobody is recorded as their creator.
+func (us *UserService) Register(ctx context.Context, user entity.User) (entity.User, error) {
+	user.Role = entity.RoleCustomer
+
+	if err := us.validator.Validate(&user); err != nil {
+		return entity.User{}, err
+	}
+
+	created := entity.User{}
+
+	err := us.transactions.Do(ctx, func(repos transaction.Repositories) error {
+		var err error
+
+		if created, err = repos.Users.Create(ctx, user); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, repos.Audits, domain.Actor{}, entity.AuditCreate, entity.AuditUser, created.Id, nil, created)
+	})
+
+	return created, err
+}
+
+// Update replaces the profile of the user with the id of user, leaving its
+// role as it is, and returns it as stored.
+func (us *UserService) Update(ctx context.Context, actor domain.Actor, user entity.User) (entity.User, error) {
+	if !actor.IsOwnerOrAdmin(user.Id) {
+		return entity.User{}, domain.Forbidden("forbidden")
+	}
+
+	user.Role = ""
+	user.UpdatedBy = &actor.Id
+
+	if err := us.validator.Validate(&user); err != nil {
+		return entity.User{}, err
+	}
+
+	updated := entity.User{}
+
+	err := us.transactions.Do(ctx, func(repos transaction.Repositories) error {
+		before, err := repos.Users.Get(ctx, user.Id)
+
+		if err != nil {
+			return err
+		}
+
+		if err := repos.Users.Update(ctx, user); err != nil {
+			return err
+		}
+
+		if updated, err = repos.Users.Get(ctx, user.Id); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, repos.Audits, actor, entity.AuditUpdate, entity.AuditUser, user.Id, before, updated)
+	})
+
+	return updated, err
+}
+
+// Patch changes the fields of the profile of the user with the id of user
+// named in fields to their values in user, leaving the others, the password
+// included, as they are, and returns it as stored.
+func (us *UserService) Patch(ctx context.Context, actor domain.Actor, user entity.User, fields []string) (entity.User, error) {
+	if !actor.IsOwnerOrAdmin(user.Id) {
+		return entity.User{}, domain.Forbidden("forbidden")
+	}
+
+	user.UpdatedBy = &actor.Id
+
+	if err := us.validator.ValidatePatch(&user, fields, userRepo.Patchable); err != nil {
+		return entity.User{}, err
+	}
+
+	patched := entity.User{}
+
+	err := us.transactions.Do(ctx, func(repos transaction.Repositories) error {
+		before, err := repos.Users.Get(ctx, user.Id)
+
+		if err != nil {
+			return err
+		}
+
+		if patched, err = repos.Users.Patch(ctx, user, fields); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, repos.Audits, actor, entity.AuditPatch, entity.AuditUser, user.Id, before, patched)
+	})
+
+	return patched, err
+}
+
+// Delete deletes the user with id together with its products, or neither
+// when the user has changed since version, and revokes its refresh tokens;
+// its access tokens stop being accepted with it. Each product deleted is
+// audited as well as the user.
+func (us *UserService) Delete(ctx context.Context, actor domain.Actor, id int, version int) error {
+	if !actor.IsOwnerOrAdmin(id) {
+		return domain.Forbidden("forbidden")
+	}
+
+	return us.transactions.Do(ctx, func(repos transaction.Repositories) error {
+		before, err := repos.Users.Get(ctx, id)
+
+		if err != nil {
+			return err
+		}
+
+		products, err := repos.Products.GetByUser(ctx, id)
+
+		if err != nil {
+			return err
+		}
+
+		if err := repos.Users.Delete(ctx, id, version, actor.Id); err != nil {
+			return err
+		}
+
+		if err := repos.Products.DeleteByUser(ctx, id, actor.Id); err != nil {
+			return err
+		}
+
+		if err := repos.Auth.RevokeUserRefresh(ctx, id); err != nil {
+			return err
+		}
+
+		if err := audit.Record(ctx, repos.Audits, actor, entity.AuditDelete, entity.AuditUser, id, before, nil); err != nil {
+			return err
+		}
+
+		for _, product := range products {
+			if err := audit.Record(ctx, repos.Audits, actor, entity.AuditDelete, entity.AuditProduct, product.Id, product, nil); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Restore undoes the deletion of the user with id, and of the products
+// deleted along with it, and returns it as stored. Each product restored is
+// audited as well as the user.
+func (us *UserService) Restore(ctx context.Context, actor domain.Actor, id int) (entity.User, error) {
+	if !actor.Can(domain.RestoreDeleted) {
+		return entity.User{}, domain.Forbidden("forbidden")
+	}
+
+	restored := entity.User{}
+
+	err := us.transactions.Do(ctx, func(repos transaction.Repositories) error {
+		if err := repos.Products.RestoreByUser(ctx, id, actor.Id); err != nil {
+			return err
+		}
+
+		if err := repos.Users.Restore(ctx, id, actor.Id); err != nil {
+			return err
+		}
+
+		var err error
+
+		if restored, err = repos.Users.Get(ctx, id); err != nil {
+			return err
+		}
+
+		// Products cannot be added to a deleted user, so those it has now are
+		// the ones just restored.
+		products, err := repos.Products.GetByUser(ctx, id)
+
+		if err != nil {
+			return err
+		}
+
+		if err := audit.Record(ctx, repos.Audits, actor, entity.AuditRestore, entity.AuditUser, id, nil, restored); err != nil {
+			return err
+		}
+
+		for _, product := range products {
+			if err := audit.Record(ctx, repos.Audits, actor, entity.AuditRestore, entity.AuditProduct, product.Id, nil, product); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return restored, err
+}
+
+func (us *UserService) SetRole(ctx context.Context, actor domain.Actor, id int, role string) error {
+	if !actor.Can(domain.AssignUserRole) {
+		return domain.Forbidden("forbidden")
+	}
+
+	if !entity.ValidRole(role) {
+		return domain.Invalid(domain.FieldError{
+			Field:   "role",
+			Code:    "oneof",
+			Message: "role must be one of admin, merchant or customer",
+		})
+	}
+
+	return us.transactions.Do(ctx, func(repos transaction.Repositories) error {
+		before, err := repos.Users.Get(ctx, id)
+
+		if err != nil {
+			return err
+		}
+
+		if err := repos.Users.SetRole(ctx, id, role, actor.Id); err != nil {
+			return err
+		}
+
+		after, err := repos.Users.Get(ctx, id)
+
+		if err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, repos.Audits, actor, entity.AuditSetRole, entity.AuditUser, id, before, after)
+	})
+}
@@ -0,0 +1,328 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/entity"
+	auditRepo "rest-api/design-pattern/repository/audit"
+	authRepo "rest-api/design-pattern/repository/auth"
+	productRepo "rest-api/design-pattern/repository/product"
+	"rest-api/design-pattern/repository/transaction"
+	userRepo "rest-api/design-pattern/repository/user"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockUserRepository records the users it is asked to create, the fields of
+// those it is asked to patch, the ids of those it is asked to delete or
+// restore and the updaters of the roles it is asked to set, any other call
+// panics.
+type mockUserRepository struct {
+	userRepo.User
+	created  []entity.User
+	patched  [][]string
+	deleted  []int
+	restored []int
+	setBy    []int
+}
+
+func (m *mockUserRepository) Create(ctx context.Context, user entity.User) (entity.User, error) {
+	m.created = append(m.created, user)
+
+	return entity.User{Id: 1, Name: user.Name, Email: user.Email, Role: user.Role}, nil
+}
+
+func (m *mockUserRepository) Patch(ctx context.Context, user entity.User, fields []string) (entity.User, error) {
+	m.patched = append(m.patched, fields)
+
+	return entity.User{Id: user.Id, Name: "user1", Email: user.Email, Role: entity.RoleCustomer}, nil
+}
+
+func (m *mockUserRepository) Delete(ctx context.Context, id int, version int, by int) error {
+	m.deleted = append(m.deleted, id)
+
+	return nil
+}
+
+func (m *mockUserRepository) Restore(ctx context.Context, id int, by int) error {
+	m.restored = append(m.restored, id)
+
+	return nil
+}
+
+func (m *mockUserRepository) SetRole(ctx context.Context, id int, role string, by int) error {
+	m.setBy = append(m.setBy, by)
+
+	return nil
+}
+
+func (m *mockUserRepository) Get(ctx context.Context, id int) (entity.User, error) {
+	return entity.User{Id: id, Name: "user1", Email: "user1@mail.com", Role: entity.RoleCustomer}, nil
+}
+
+// mockProductRepository lists products as those of any user and records the
+// owners of those it is asked to delete or restore; any other call panics.
+type mockProductRepository struct {
+	productRepo.Product
+	products []entity.Product
+	deleted  []int
+	restored []int
+}
+
+func (m *mockProductRepository) GetByUser(ctx context.Context, userid int) ([]entity.Product, error) {
+	return m.products, nil
+}
+
+func (m *mockProductRepository) DeleteByUser(ctx context.Context, userid int, by int) error {
+	m.deleted = append(m.deleted, userid)
+
+	return nil
+}
+
+func (m *mockProductRepository) RestoreByUser(ctx context.Context, userid int, by int) error {
+	m.restored = append(m.restored, userid)
+
+	return nil
+}
+
+// mockAuditRepository keeps the records it is asked to store; any other call
+// panics.
+type mockAuditRepository struct {
+	auditRepo.Audit
+	stored []entity.Audit
+}
+
+func (m *mockAuditRepository) Create(ctx context.Context, record entity.Audit) error {
+	m.stored = append(m.stored, record)
+
+	return nil
+}
+
+// mockAuthRepository records the users whose refresh tokens it is asked to
+// revoke; any other call panics.
+type mockAuthRepository struct {
+	authRepo.Auth
+	revoked []int
+}
+
+func (m *mockAuthRepository) RevokeUserRefresh(ctx context.Context, userId int) error {
+	m.revoked = append(m.revoked, userId)
+
+	return nil
+}
+
+// newService returns a service on users and products whose changes are
+// audited to audits.
+func newService(users *mockUserRepository, products *mockProductRepository, audits *mockAuditRepository) *UserService {
+	return newServiceWithAuth(users, products, audits, &mockAuthRepository{})
+}
+
+// newServiceWithAuth returns a service as newService does, revoking tokens
+// with auth.
+func newServiceWithAuth(users *mockUserRepository, products *mockProductRepository, audits *mockAuditRepository, auth *mockAuthRepository) *UserService {
+	return New(users, transaction.Repositories{Users: users, Products: products, Audits: audits, Auth: auth})
+}
+
+func TestRegister(t *testing.T) {
+	t.Run("TestRegisterCustomer", func(t *testing.T) {
+		users := &mockUserRepository{}
+		user := entity.User{Name: "user1", Email: "user1@mail.com", Password: "Passw0rd", Role: entity.RoleAdmin}
+
+		created, err := newService(users, &mockProductRepository{}, &mockAuditRepository{}).Register(context.Background(), user)
+
+		assert.NoError(t, err)
+		assert.Equal(t, entity.RoleCustomer, created.Role)
+		assert.Equal(t, entity.RoleCustomer, users.created[0].Role)
+	})
+
+	t.Run("TestRegisterInvalid", func(t *testing.T) {
+		users := &mockUserRepository{}
+
+		_, err := newService(users, &mockProductRepository{}, &mockAuditRepository{}).Register(context.Background(), entity.User{Name: "user1", Email: "email", Password: "Passw0rd"})
+
+		assert.True(t, errors.Is(err, domain.ErrValidation))
+		assert.Empty(t, users.created)
+	})
+}
+
+func TestPatch(t *testing.T) {
+	t.Run("TestPatchSuppliedFields", func(t *testing.T) {
+		users := &mockUserRepository{}
+
+		patched, err := newService(users, &mockProductRepository{}, &mockAuditRepository{}).Patch(context.Background(), domain.Actor{Id: 1, Role: entity.RoleCustomer}, entity.User{Id: 1, Email: "user2@mail.com"}, []string{"email"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "user2@mail.com", patched.Email)
+		assert.Equal(t, [][]string{{"email"}}, users.patched)
+	})
+
+	t.Run("TestPatchInvalid", func(t *testing.T) {
+		users := &mockUserRepository{}
+
+		_, err := newService(users, &mockProductRepository{}, &mockAuditRepository{}).Patch(context.Background(), domain.Actor{Id: 1, Role: entity.RoleCustomer}, entity.User{Id: 1, Password: "weak"}, []string{"password"})
+
+		var domainErr *domain.Error
+
+		assert.True(t, errors.As(err, &domainErr))
+		assert.Equal(t, []domain.FieldError{{Field: "password", Code: "password", Message: "password must be 8 to 72 characters with an upper case letter, a lower case letter and a digit"}}, domainErr.Fields)
+		assert.Empty(t, users.patched)
+	})
+
+	t.Run("TestPatchReadonly", func(t *testing.T) {
+		users := &mockUserRepository{}
+
+		_, err := newService(users, &mockProductRepository{}, &mockAuditRepository{}).Patch(context.Background(), domain.Actor{Id: 1, Role: entity.RoleCustomer}, entity.User{Id: 1, Role: entity.RoleAdmin}, []string{"id", "role"})
+
+		var domainErr *domain.Error
+
+		assert.True(t, errors.As(err, &domainErr))
+		assert.Equal(t, []domain.FieldError{
+			{Field: "id", Code: "readonly", Message: "id cannot be changed"},
+			{Field: "role", Code: "readonly", Message: "role cannot be changed"},
+		}, domainErr.Fields)
+		assert.Empty(t, users.patched)
+	})
+
+	t.Run("TestPatchForbidden", func(t *testing.T) {
+		users := &mockUserRepository{}
+
+		_, err := newService(users, &mockProductRepository{}, &mockAuditRepository{}).Patch(context.Background(), domain.Actor{Id: 2, Role: entity.RoleCustomer}, entity.User{Id: 1, Name: "user1"}, []string{"name"})
+
+		assert.True(t, errors.Is(err, domain.ErrForbidden))
+		assert.Empty(t, users.patched)
+	})
+}
+
+func TestDelete(t *testing.T) {
+	t.Run("TestDeleteWithProducts", func(t *testing.T) {
+		users := &mockUserRepository{}
+		products := &mockProductRepository{}
+
+		err := newService(users, products, &mockAuditRepository{}).Delete(context.Background(), domain.Actor{Id: 2, Role: entity.RoleCustomer}, 2, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int{2}, products.deleted)
+		assert.Equal(t, []int{2}, users.deleted)
+	})
+
+	t.Run("TestDeleteRevokesTokens", func(t *testing.T) {
+		auth := &mockAuthRepository{}
+
+		err := newServiceWithAuth(&mockUserRepository{}, &mockProductRepository{}, &mockAuditRepository{}, auth).Delete(context.Background(), domain.Actor{Id: 1, Role: entity.RoleAdmin}, 2, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int{2}, auth.revoked)
+	})
+
+	t.Run("TestDeleteForbidden", func(t *testing.T) {
+		users := &mockUserRepository{}
+		products := &mockProductRepository{}
+
+		err := newService(users, products, &mockAuditRepository{}).Delete(context.Background(), domain.Actor{Id: 2, Role: entity.RoleMerchant}, 3, 1)
+
+		assert.True(t, errors.Is(err, domain.ErrForbidden))
+		assert.Empty(t, products.deleted)
+		assert.Empty(t, users.deleted)
+	})
+}
+
+func TestRestore(t *testing.T) {
+	t.Run("TestRestoreWithProducts", func(t *testing.T) {
+		users := &mockUserRepository{}
+		products := &mockProductRepository{}
+
+		restored, err := newService(users, products, &mockAuditRepository{}).Restore(context.Background(), domain.Actor{Id: 1, Role: entity.RoleAdmin}, 2)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, restored.Id)
+		assert.Equal(t, []int{2}, products.restored)
+		assert.Equal(t, []int{2}, users.restored)
+	})
+
+	t.Run("TestRestoreForbidden", func(t *testing.T) {
+		users := &mockUserRepository{}
+		products := &mockProductRepository{}
+
+		_, err := newService(users, products, &mockAuditRepository{}).Restore(context.Background(), domain.Actor{Id: 2, Role: entity.RoleCustomer}, 2)
+
+		assert.True(t, errors.Is(err, domain.ErrForbidden))
+		assert.Empty(t, products.restored)
+		assert.Empty(t, users.restored)
+	})
+}
+
+func TestSetRole(t *testing.T) {
+	t.Run("TestSetRoleBySystem", func(t *testing.T) {
+		users := &mockUserRepository{}
+		audits := &mockAuditRepository{}
+
+		err := newService(users, &mockProductRepository{}, audits).SetRole(context.Background(), domain.System, 2, entity.RoleAdmin)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int{0}, users.setBy)
+		assert.Len(t, audits.stored, 1)
+		assert.Nil(t, audits.stored[0].ActorID)
+	})
+
+	t.Run("TestSetRoleForbidden", func(t *testing.T) {
+		err := newService(&mockUserRepository{}, &mockProductRepository{}, &mockAuditRepository{}).SetRole(context.Background(), domain.Actor{Id: 2, Role: entity.RoleMerchant}, 2, entity.RoleAdmin)
+
+		assert.True(t, errors.Is(err, domain.ErrForbidden))
+	})
+}
+
+// TEST AUDIT
+
+func TestAudit(t *testing.T) {
+	admin := domain.Actor{Id: 1, Role: entity.RoleAdmin}
+
+	t.Run("TestAuditRegisterAnonymous", func(t *testing.T) {
+		audits := &mockAuditRepository{}
+
+		_, err := newService(&mockUserRepository{}, &mockProductRepository{}, audits).Register(context.Background(), entity.User{Name: "user1", Email: "user1@mail.com", Password: "Passw0rd"})
+
+		assert.NoError(t, err)
+		assert.Len(t, audits.stored, 1)
+		assert.Equal(t, entity.AuditCreate, audits.stored[0].Action)
+		assert.Nil(t, audits.stored[0].ActorID)
+		assert.NotContains(t, string(audits.stored[0].After), "Passw0rd")
+	})
+
+	for _, tc := range []struct {
+		name   string
+		action string
+		change func(*UserService) error
+	}{
+		{"TestAuditDeleteCascade", entity.AuditDelete, func(s *UserService) error {
+			return s.Delete(context.Background(), admin, 2, 1)
+		}},
+		{"TestAuditRestoreCascade", entity.AuditRestore, func(s *UserService) error {
+			_, err := s.Restore(context.Background(), admin, 2)
+			return err
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			products := &mockProductRepository{products: []entity.Product{{Id: 3, UserID: 2, Name: "product3"}, {Id: 4, UserID: 2, Name: "product4"}}}
+			audits := &mockAuditRepository{}
+
+			assert.NoError(t, tc.change(newService(&mockUserRepository{}, products, audits)))
+
+			type audited struct {
+				Resource   string
+				ResourceID int
+			}
+
+			records := []audited{}
+
+			for _, record := range audits.stored {
+				assert.Equal(t, tc.action, record.Action)
+				assert.Equal(t, &admin.Id, record.ActorID)
+				records = append(records, audited{record.Resource, record.ResourceID})
+			}
+
+			assert.Equal(t, []audited{{entity.AuditUser, 2}, {entity.AuditProduct, 3}, {entity.AuditProduct, 4}}, records)
+		})
+	}
+}
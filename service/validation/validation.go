@@ -0,0 +1,190 @@
+// Package validation checks entities against their validate tags before the
+// services store them.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"rest-api/design-pattern/domain"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type Validator struct {
+	validate *validator.Validate
+}
+
+// New returns a Validator knowing the custom rules isbn13, which replaces
+// the validator's own to also allow hyphens, and password.
+func New() *Validator {
+	validate := validator.New()
+
+	// Report fields by the names clients send them under.
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+
+		if name == "-" {
+			return ""
+		}
+
+		return name
+	})
+
+	validate.RegisterValidation("isbn13", func(fl validator.FieldLevel) bool {
+		return ValidISBN13(fl.Field().String())
+	})
+	validate.RegisterValidation("password", func(fl validator.FieldLevel) bool {
+		return StrongPassword(fl.Field().String())
+	})
+
+	return &Validator{validate: validate}
+}
+
+// Validate returns a domain validation error listing every field of i that
+// breaks its rules, or nil.
+func (v *Validator) Validate(i interface{}) error {
+	return v.errors(v.validate.Struct(i))
+}
+
+func (v *Validator) errors(err error) error {
+	var invalid validator.ValidationErrors
+
+	if !errors.As(err, &invalid) {
+		return err
+	}
+
+	fields := make([]domain.FieldError, 0, len(invalid))
+
+	for _, fe := range invalid {
+		fields = append(fields, domain.FieldError{
+			Field:   fe.Field(),
+			Code:    fe.Tag(),
+			Message: message(fe),
+		})
+	}
+
+	return domain.Invalid(fields...)
+}
+
+// ValidatePatch validates the fields of i a patch changes, named as
+// clients send them, and rejects those that are not keys of patchable.
+func (v *Validator) ValidatePatch(i interface{}, fields []string, patchable map[string]string) error {
+	var readonly []domain.FieldError
+
+	for _, field := range fields {
+		if _, ok := patchable[field]; !ok {
+			readonly = append(readonly, domain.FieldError{
+				Field:   field,
+				Code:    "readonly",
+				Message: fmt.Sprintf("%v cannot be changed", field),
+			})
+		}
+	}
+
+	if len(readonly) > 0 {
+		return domain.Invalid(readonly...)
+	}
+
+	names := structFields(reflect.TypeOf(i))
+	partial := make([]string, 0, len(fields))
+
+	for _, field := range fields {
+		partial = append(partial, names[field])
+	}
+
+	return v.errors(v.validate.StructPartial(i, partial...))
+}
+
+// structFields maps the json names of the fields of the struct t, or of
+// the struct t points to, to their Go names.
+func structFields(t reflect.Type) map[string]string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	names := map[string]string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		names[strings.SplitN(field.Tag.Get("json"), ",", 2)[0]] = field.Name
+	}
+
+	return names
+}
+
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%v is required", fe.Field())
+	case "max":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("%v must be at most %v characters", fe.Field(), fe.Param())
+		}
+
+		return fmt.Sprintf("%v must be at most %v", fe.Field(), fe.Param())
+	case "gt":
+		return fmt.Sprintf("%v must be greater than %v", fe.Field(), fe.Param())
+	case "email":
+		return fmt.Sprintf("%v must be a valid email address", fe.Field())
+	case "isbn13":
+		return fmt.Sprintf("%v must be a valid ISBN-13", fe.Field())
+	case "password":
+		return fmt.Sprintf("%v must be 8 to 72 characters with an upper case letter, a lower case letter and a digit", fe.Field())
+	default:
+		return fmt.Sprintf("%v is invalid", fe.Field())
+	}
+}
+
+// ValidISBN13 reports whether isbn is 13 digits, optionally separated by
+// hyphens, whose checksum matches.
+func ValidISBN13(isbn string) bool {
+	digits := strings.ReplaceAll(isbn, "-", "")
+
+	if len(digits) != 13 {
+		return false
+	}
+
+	sum := 0
+
+	for i, r := range digits {
+		if r < '0' || r > '9' {
+			return false
+		}
+
+		weight := 1
+
+		if i%2 == 1 {
+			weight = 3
+		}
+
+		sum += int(r-'0') * weight
+	}
+
+	return sum%10 == 0
+}
+
+// StrongPassword reports whether password is 8 to 72 bytes long, the most
+// bcrypt hashes, and mixes upper and lower case letters and digits.
+func StrongPassword(password string) bool {
+	if len(password) < 8 || len(password) > 72 {
+		return false
+	}
+
+	var upper, lower, digit bool
+
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+
+	return upper && lower && digit
+}
@@ -0,0 +1,75 @@
+package validation
+
+import (
+	"errors"
+	"rest-api/design-pattern/domain"
+	"rest-api/design-pattern/entity"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidISBN13(t *testing.T) {
+	cases := map[string]bool{
+		"9780134190440":     true,
+		"978-0-13-419044-0": true,
+		"9780134190441":     false,
+		"978013419044":      false,
+		"978013419044X":     false,
+		"isbn1":             false,
+	}
+
+	for isbn, valid := range cases {
+		t.Run(isbn, func(t *testing.T) {
+			assert.Equal(t, valid, ValidISBN13(isbn))
+		})
+	}
+}
+
+func TestStrongPassword(t *testing.T) {
+	cases := map[string]bool{
+		"Passw0rd":                     true,
+		"Pa0":                          false,
+		"password1":                    false,
+		"PASSWORD1":                    false,
+		"Password":                     false,
+		strings.Repeat("Passw0rd", 10): false,
+	}
+
+	for password, valid := range cases {
+		t.Run(password, func(t *testing.T) {
+			assert.Equal(t, valid, StrongPassword(password))
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("TestValidateValid", func(t *testing.T) {
+		book := entity.Book{
+			Title:     "title1",
+			Author:    "author1",
+			Publisher: "publisher1",
+			Language:  "language1",
+			Pages:     100,
+			ISBN13:    "9780134190440",
+		}
+
+		assert.NoError(t, New().Validate(&book))
+	})
+
+	t.Run("TestValidateInvalid", func(t *testing.T) {
+		user := entity.User{Name: "user1", Email: "email", Password: "password"}
+
+		err := New().Validate(&user)
+
+		var domainErr *domain.Error
+
+		assert.True(t, errors.Is(err, domain.ErrValidation))
+		assert.True(t, errors.As(err, &domainErr))
+		assert.Equal(t, []domain.FieldError{
+			{Field: "email", Code: "email", Message: "email must be a valid email address"},
+			{Field: "password", Code: "password", Message: "password must be 8 to 72 characters with an upper case letter, a lower case letter and a digit"},
+		}, domainErr.Fields)
+	})
+}
@@ -4,16 +4,24 @@ import (
 	"database/sql"
 	"fmt"
 	"rest-api/design-pattern/config"
+	"strings"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 var db *sql.DB
 
 func GetDBInstance(config *config.AppConfig) *sql.DB {
 	if db == nil {
-		path := fmt.Sprintf("%v:%v@/%v", config.Username, config.Password, config.DBName)
-		dbNewInstance, err := sql.Open(config.Driver, path)
+		dsn := mysql.NewConfig()
+		dsn.User = config.Username
+		dsn.Passwd = config.Password
+		dsn.Net = "tcp"
+		dsn.Addr = fmt.Sprintf("%v:%v", config.DBHost, config.DBPort)
+		dsn.DBName = config.DBName
+		dsn.ParseTime = true
+
+		dbNewInstance, err := sql.Open(config.Driver, dsn.FormatDSN())
 
 		if err != nil {
 			panic(err)
@@ -23,3 +31,31 @@ func GetDBInstance(config *config.AppConfig) *sql.DB {
 	}
 	return db
 }
+
+// MySQL error numbers the repositories translate into domain errors.
+const (
+	ErrDuplicateEntry  = 1062
+	ErrNoReferencedRow = 1452
+)
+
+// IsMySQLError reports whether err is the MySQL error with the given number.
+func IsMySQLError(err error, number uint16) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+
+	return ok && mysqlErr.Number == number
+}
+
+// DuplicateKey returns the name of the unique key err reports a duplicate
+// entry for, and whether err is such an error at all. MySQL names the key
+// bare before 8.0 and prefixed by its table since.
+func DuplicateKey(err error) (string, bool) {
+	if !IsMySQLError(err, ErrDuplicateEntry) {
+		return "", false
+	}
+
+	message := err.(*mysql.MySQLError).Message
+	key := message[strings.LastIndex(message, " ")+1:]
+	key = strings.Trim(key, "'")
+
+	return key[strings.LastIndex(key, ".")+1:], true
+}
@@ -0,0 +1,57 @@
+package password
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hasher turns plain text passwords into storable hashes and checks login
+// attempts against them.
+//
+// Verify accepts any hash format known to this package, not only the one the
+// hasher produces, so switching algorithms or costs never locks users out.
+// Rows that still hold a plain text password are compared in constant time and
+// reported by NeedsRehash, letting callers upgrade them after a successful
+// login. A blank one matches nothing, not even a blank password.
+type Hasher interface {
+	Hash(string) (string, error)
+	Verify(hash string, password string) (bool, error)
+	NeedsRehash(string) bool
+}
+
+func verify(hash string, password string) (bool, error) {
+	switch {
+	case isBcrypt(hash):
+		return verifyBcrypt(hash, password)
+	case isArgon2id(hash):
+		return verifyArgon2id(hash, password)
+	case hash == "" || password == "":
+		return false, nil
+	default:
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(password)) == 1, nil
+	}
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func isArgon2id(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+// New returns the hasher registered under algorithm with its default
+// parameters.
+func New(algorithm string) (Hasher, error) {
+	switch algorithm {
+	case "bcrypt":
+		return NewBcrypt(bcrypt.DefaultCost), nil
+	case "argon2id":
+		return NewArgon2id(DefaultArgon2idParams), nil
+	default:
+		return nil, fmt.Errorf("unknown password hasher %v", algorithm)
+	}
+}
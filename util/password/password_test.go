@@ -0,0 +1,98 @@
+package password
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var fastArgon2idParams = Argon2idParams{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestBcrypt(t *testing.T) {
+	t.Run("TestBcrypt", func(t *testing.T) {
+		hasher := NewBcrypt(bcrypt.MinCost)
+
+		hash, err := hasher.Hash("password1")
+		assert.NoError(t, err)
+		assert.NotEqual(t, "password1", hash)
+
+		matched, err := hasher.Verify(hash, "password1")
+		assert.NoError(t, err)
+		assert.True(t, matched)
+
+		matched, err = hasher.Verify(hash, "password2")
+		assert.NoError(t, err)
+		assert.False(t, matched)
+
+		assert.False(t, hasher.NeedsRehash(hash))
+		assert.True(t, NewBcrypt(bcrypt.MinCost+1).NeedsRehash(hash))
+	})
+}
+
+func TestArgon2id(t *testing.T) {
+	t.Run("TestArgon2id", func(t *testing.T) {
+		hasher := NewArgon2id(fastArgon2idParams)
+
+		hash, err := hasher.Hash("password1")
+		assert.NoError(t, err)
+		assert.Contains(t, hash, "$argon2id$v=19$m=1024,t=1,p=1$")
+
+		matched, err := hasher.Verify(hash, "password1")
+		assert.NoError(t, err)
+		assert.True(t, matched)
+
+		matched, err = hasher.Verify(hash, "password2")
+		assert.NoError(t, err)
+		assert.False(t, matched)
+
+		assert.False(t, hasher.NeedsRehash(hash))
+		assert.True(t, NewArgon2id(DefaultArgon2idParams).NeedsRehash(hash))
+	})
+}
+
+func TestCrossAlgorithm(t *testing.T) {
+	t.Run("TestCrossAlgorithm", func(t *testing.T) {
+		bcryptHasher := NewBcrypt(bcrypt.MinCost)
+		argon2idHasher := NewArgon2id(fastArgon2idParams)
+
+		hash, _ := bcryptHasher.Hash("password1")
+
+		matched, err := argon2idHasher.Verify(hash, "password1")
+		assert.NoError(t, err)
+		assert.True(t, matched)
+		assert.True(t, argon2idHasher.NeedsRehash(hash))
+	})
+}
+
+func TestLegacyPlaintext(t *testing.T) {
+	t.Run("TestLegacyPlaintext", func(t *testing.T) {
+		hasher := NewBcrypt(bcrypt.MinCost)
+
+		matched, err := hasher.Verify("password1", "password1")
+		assert.NoError(t, err)
+		assert.True(t, matched)
+
+		matched, err = hasher.Verify("password1", "password2")
+		assert.NoError(t, err)
+		assert.False(t, matched)
+
+		assert.True(t, hasher.NeedsRehash("password1"))
+	})
+
+	t.Run("TestLegacyPlaintextBlank", func(t *testing.T) {
+		hasher := NewBcrypt(bcrypt.MinCost)
+
+		for _, pair := range [][2]string{{"", ""}, {"", "password1"}, {"password1", ""}} {
+			matched, err := hasher.Verify(pair[0], pair[1])
+			assert.NoError(t, err)
+			assert.False(t, matched, pair)
+		}
+	})
+}
@@ -0,0 +1,22 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PatchQuery returns an UPDATE of the row of table with the id and version
+// given last, unless it is deleted. It sets the column of each of fields, as
+// mapped by columns, in their order, then updated_at and updated_by, and
+// moves the row to the next version.
+func PatchQuery(table string, columns map[string]string, fields []string) string {
+	sets := make([]string, 0, len(fields)+3)
+
+	for _, field := range fields {
+		sets = append(sets, columns[field]+" = ?")
+	}
+
+	sets = append(sets, "updated_at = ?", "updated_by = ?", "version = version + 1")
+
+	return fmt.Sprintf("UPDATE %s SET %s WHERE id = ? AND version = ? AND deleted_at IS NULL", table, strings.Join(sets, ", "))
+}
@@ -0,0 +1,75 @@
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Page is the pagination metadata returned next to a listing. Page, First,
+// Prev and Last are only set when paginating by page number.
+type Page struct {
+	Total      int    `json:"total"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Links      Links  `json:"links"`
+	sort       string
+}
+
+type Links struct {
+	Self  string `json:"self,omitempty"`
+	First string `json:"first,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Next  string `json:"next,omitempty"`
+	Last  string `json:"last,omitempty"`
+}
+
+// More records that rows follow the last one returned, identified by its id
+// and the value of the field the listing is sorted by.
+func (p *Page) More(value interface{}, id int) {
+	p.NextCursor = encodeCursor(cursor{Sort: p.sort, Value: fmt.Sprint(value), Id: id})
+}
+
+// SetLinks fills Links from the URL of the request that produced the page,
+// keeping its sort and filter parameters.
+func (p *Page) SetLinks(u *url.URL) {
+	p.Links = Links{Self: u.RequestURI()}
+
+	if p.Page == 0 {
+		if p.NextCursor != "" {
+			p.Links.Next = withParams(u, "cursor", p.NextCursor)
+		}
+
+		return
+	}
+
+	last := 1
+
+	if p.Total > 0 && p.Limit > 0 {
+		last = (p.Total + p.Limit - 1) / p.Limit
+	}
+
+	p.Links.First = withParams(u, "page", "1")
+	p.Links.Last = withParams(u, "page", strconv.Itoa(last))
+
+	if p.Page > 1 {
+		p.Links.Prev = withParams(u, "page", strconv.Itoa(p.Page-1))
+	}
+
+	if p.Page < last {
+		p.Links.Next = withParams(u, "page", strconv.Itoa(p.Page+1))
+	}
+}
+
+func withParams(u *url.URL, key string, value string) string {
+	values := u.Query()
+	values.Del("page")
+	values.Del("cursor")
+	values.Set(key, value)
+
+	link := *u
+	link.RawQuery = values.Encode()
+
+	return link.RequestURI()
+}
@@ -0,0 +1,320 @@
+// Package query parses the pagination, sorting and filtering options of list
+// endpoints from request query parameters and turns them into parameterized
+// SQL. Column names only ever come from a Spec declared by the repository,
+// never from the request, so every user supplied value stays a placeholder.
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+type Operator string
+
+const (
+	Equal          Operator = "="
+	GreaterOrEqual Operator = ">="
+	LessOrEqual    Operator = "<="
+)
+
+// Filter maps a query parameter onto a column comparison. The value of a
+// Numeric filter must be an integer, that of a Time filter an RFC 3339
+// timestamp.
+type Filter struct {
+	Param    string
+	Column   string
+	Operator Operator
+	Numeric  bool
+	Time     bool
+}
+
+// Spec declares what a listing can be sorted and filtered by. Sorts maps the
+// public field name to its column and must contain "id". DeletedAt, when
+// set, is the column holding when a row was soft deleted; such rows are left
+// out unless include_deleted is true.
+type Spec struct {
+	Sorts     map[string]string
+	Filters   []Filter
+	DeletedAt string
+}
+
+type condition struct {
+	column   string
+	operator Operator
+	value    interface{}
+}
+
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	Id    int    `json:"id"`
+}
+
+// Options is the parsed form of page, limit, cursor, sort, include_deleted
+// and the filters of a Spec. Page and cursor are exclusive; when a cursor is
+// given the listing continues right after the row it points at.
+type Options struct {
+	Page           int
+	Limit          int
+	Sort           string
+	Desc           bool
+	IncludeDeleted bool
+	spec           Spec
+	cursor         *cursor
+	conditions     []condition
+}
+
+// Parse reads the list options from values. Sort takes a field name,
+// prefixed with "-" for descending order.
+func Parse(values url.Values, spec Spec) (Options, error) {
+	opts := Options{Page: 1, Limit: DefaultLimit, Sort: "id", spec: spec}
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return opts, fmt.Errorf("invalid limit")
+		}
+
+		opts.Limit = limit
+	}
+
+	if value := values.Get("sort"); value != "" {
+		if strings.HasPrefix(value, "-") {
+			opts.Desc = true
+			value = value[1:]
+		}
+
+		if _, ok := spec.Sorts[value]; !ok {
+			return opts, fmt.Errorf("invalid sort field")
+		}
+
+		opts.Sort = value
+	}
+
+	if value := values.Get("page"); value != "" {
+		page, err := strconv.Atoi(value)
+
+		if err != nil || page < 1 {
+			return opts, fmt.Errorf("invalid page")
+		}
+
+		opts.Page = page
+	}
+
+	if value := values.Get("cursor"); value != "" {
+		if values.Get("page") != "" {
+			return opts, fmt.Errorf("page and cursor are exclusive")
+		}
+
+		c, err := decodeCursor(value)
+
+		if err != nil || c.Sort != opts.sortKey() {
+			return opts, fmt.Errorf("invalid cursor")
+		}
+
+		opts.Page = 0
+		opts.cursor = &c
+	}
+
+	if value := values.Get("include_deleted"); value != "" && spec.DeletedAt != "" {
+		include, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return opts, fmt.Errorf("invalid include_deleted")
+		}
+
+		opts.IncludeDeleted = include
+	}
+
+	for _, f := range spec.Filters {
+		value := values.Get(f.Param)
+
+		if value == "" {
+			continue
+		}
+
+		if f.Numeric {
+			number, err := strconv.Atoi(value)
+
+			if err != nil {
+				return opts, fmt.Errorf("invalid %v", f.Param)
+			}
+
+			opts.conditions = append(opts.conditions, condition{f.Column, f.Operator, number})
+			continue
+		}
+
+		if f.Time {
+			at, err := time.Parse(time.RFC3339, value)
+
+			if err != nil {
+				return opts, fmt.Errorf("invalid %v", f.Param)
+			}
+
+			opts.conditions = append(opts.conditions, condition{f.Column, f.Operator, at})
+			continue
+		}
+
+		opts.conditions = append(opts.conditions, condition{f.Column, f.Operator, value})
+	}
+
+	return opts, nil
+}
+
+// ParsePage reads only page and limit from values, for listings ordered by
+// something no cursor can point into, such as search relevance.
+func ParsePage(values url.Values) (Options, error) {
+	page := url.Values{}
+
+	for _, key := range []string{"page", "limit"} {
+		if value, ok := values[key]; ok {
+			page[key] = value
+		}
+	}
+
+	return Parse(page, Spec{})
+}
+
+// Count completes base, a SELECT COUNT(*) without WHERE clause, with the
+// filters of the options.
+func (o Options) Count(base string) (string, []interface{}) {
+	where, args := o.where(false)
+
+	return base + where, args
+}
+
+// Select completes base, a SELECT without WHERE clause, with the filters,
+// cursor, ordering and limit of the options. One row more than the limit is
+// requested so the caller can tell whether another page follows.
+func (o Options) Select(base string) (string, []interface{}) {
+	where, args := o.where(true)
+
+	direction := "ASC"
+
+	if o.Desc {
+		direction = "DESC"
+	}
+
+	id := o.column("id")
+	order := fmt.Sprintf(" ORDER BY %v %v", id, direction)
+
+	if column := o.column(o.Sort); column != id {
+		order = fmt.Sprintf(" ORDER BY %v %v, %v %v", column, direction, id, direction)
+	}
+
+	return o.Paginate(base+where+order, args)
+}
+
+// Paginate appends the limit of the options, and the offset of their page
+// unless a cursor is used, to an ordered query. As with Select, one row more
+// than the limit is requested.
+func (o Options) Paginate(query string, args []interface{}) (string, []interface{}) {
+	query += " LIMIT ?"
+	args = append(args, o.limit()+1)
+
+	if o.cursor == nil && o.Page > 1 {
+		query += " OFFSET ?"
+		args = append(args, (o.Page-1)*o.limit())
+	}
+
+	return query, args
+}
+
+// NewPage returns the pagination metadata of the options, to be completed
+// by the repository with Total and, through More, the next cursor.
+func (o Options) NewPage() Page {
+	return Page{Page: o.Page, Limit: o.limit(), sort: o.sortKey()}
+}
+
+func (o Options) where(withCursor bool) (string, []interface{}) {
+	clauses := []string{}
+	args := []interface{}{}
+
+	if o.spec.DeletedAt != "" && !o.IncludeDeleted {
+		clauses = append(clauses, o.spec.DeletedAt+" IS NULL")
+	}
+
+	for _, c := range o.conditions {
+		clauses = append(clauses, fmt.Sprintf("%v %v ?", c.column, c.operator))
+		args = append(args, c.value)
+	}
+
+	if withCursor && o.cursor != nil {
+		comparison := ">"
+
+		if o.Desc {
+			comparison = "<"
+		}
+
+		id := o.column("id")
+
+		if column := o.column(o.Sort); column == id {
+			clauses = append(clauses, fmt.Sprintf("%v %v ?", id, comparison))
+			args = append(args, o.cursor.Id)
+		} else {
+			clauses = append(clauses, fmt.Sprintf("(%v %v ? OR (%v = ? AND %v %v ?))", column, comparison, column, id, comparison))
+			args = append(args, o.cursor.Value, o.cursor.Value, o.cursor.Id)
+		}
+	}
+
+	if len(clauses) == 0 {
+		return "", args
+	}
+
+	return " WHERE " + strings.Join(clauses, " AND "), args
+}
+
+func (o Options) column(field string) string {
+	if column, ok := o.spec.Sorts[field]; ok {
+		return column
+	}
+
+	return "id"
+}
+
+func (o Options) limit() int {
+	if o.Limit < 1 {
+		return DefaultLimit
+	}
+
+	return o.Limit
+}
+
+func (o Options) sortKey() string {
+	if o.Desc {
+		return "-" + o.Sort
+	}
+
+	return o.Sort
+}
+
+func encodeCursor(c cursor) string {
+	content, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+func decodeCursor(value string) (cursor, error) {
+	c := cursor{}
+
+	content, err := base64.RawURLEncoding.DecodeString(value)
+
+	if err != nil {
+		return c, err
+	}
+
+	err = json.Unmarshal(content, &c)
+
+	return c, err
+}
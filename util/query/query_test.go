@@ -0,0 +1,212 @@
+package query
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var spec = Spec{
+	Sorts: map[string]string{
+		"id":    "p.id",
+		"price": "p.price",
+	},
+	Filters: []Filter{
+		{Param: "merchant", Column: "u.name", Operator: Equal},
+		{Param: "min_price", Column: "p.price", Operator: GreaterOrEqual, Numeric: true},
+		{Param: "updated_since", Column: "p.updated_at", Operator: GreaterOrEqual, Time: true},
+	},
+}
+
+func TestParseDefaults(t *testing.T) {
+	t.Run("TestParseDefaults", func(t *testing.T) {
+		opts, err := Parse(url.Values{}, spec)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, opts.Page)
+		assert.Equal(t, DefaultLimit, opts.Limit)
+		assert.Equal(t, "id", opts.Sort)
+		assert.False(t, opts.Desc)
+
+		query, args := opts.Select("SELECT p.id FROM products p")
+
+		assert.Equal(t, "SELECT p.id FROM products p ORDER BY p.id ASC LIMIT ?", query)
+		assert.Equal(t, []interface{}{DefaultLimit + 1}, args)
+	})
+}
+
+func TestParseInvalid(t *testing.T) {
+	cases := map[string]string{
+		"limit=0":             "invalid limit",
+		"limit=1000":          "invalid limit",
+		"page=0":              "invalid page",
+		"sort=password":       "invalid sort field",
+		"min_price=cheap":     "invalid min_price",
+		"updated_since=today": "invalid updated_since",
+		"cursor=garbage":      "invalid cursor",
+		"page=2&cursor=abc":   "page and cursor are exclusive",
+		"sort=price&limit=-1": "invalid limit",
+	}
+
+	for raw, message := range cases {
+		t.Run(raw, func(t *testing.T) {
+			values, _ := url.ParseQuery(raw)
+
+			_, err := Parse(values, spec)
+
+			assert.EqualError(t, err, message)
+		})
+	}
+}
+
+func TestSelectFiltersSortAndPage(t *testing.T) {
+	t.Run("TestSelectFiltersSortAndPage", func(t *testing.T) {
+		values, _ := url.ParseQuery("merchant=user1&min_price=100&sort=-price&page=3&limit=10")
+
+		opts, err := Parse(values, spec)
+
+		assert.Nil(t, err)
+
+		query, args := opts.Select("SELECT p.id FROM products p")
+
+		assert.Equal(t, "SELECT p.id FROM products p WHERE u.name = ? AND p.price >= ? ORDER BY p.price DESC, p.id DESC LIMIT ? OFFSET ?", query)
+		assert.Equal(t, []interface{}{"user1", 100, 11, 20}, args)
+
+		query, args = opts.Count("SELECT COUNT(*) FROM products p")
+
+		assert.Equal(t, "SELECT COUNT(*) FROM products p WHERE u.name = ? AND p.price >= ?", query)
+		assert.Equal(t, []interface{}{"user1", 100}, args)
+	})
+}
+
+func TestTimeFilter(t *testing.T) {
+	t.Run("TestTimeFilter", func(t *testing.T) {
+		values, _ := url.ParseQuery("updated_since=2022-06-01T12:00:00%2B02:00")
+
+		opts, err := Parse(values, spec)
+
+		assert.Nil(t, err)
+
+		query, args := opts.Count("SELECT COUNT(*) FROM products p")
+
+		assert.Equal(t, "SELECT COUNT(*) FROM products p WHERE p.updated_at >= ?", query)
+		assert.Len(t, args, 1)
+		assert.True(t, time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC).Equal(args[0].(time.Time)))
+	})
+}
+
+func TestIncludeDeleted(t *testing.T) {
+	deletable := spec
+	deletable.DeletedAt = "p.deleted_at"
+
+	cases := []struct {
+		raw   string
+		query string
+		args  []interface{}
+	}{
+		{"", "SELECT COUNT(*) FROM products p WHERE p.deleted_at IS NULL", []interface{}{}},
+		{"include_deleted=false&min_price=100", "SELECT COUNT(*) FROM products p WHERE p.deleted_at IS NULL AND p.price >= ?", []interface{}{100}},
+		{"include_deleted=true", "SELECT COUNT(*) FROM products p", []interface{}{}},
+		{"include_deleted=true&min_price=100", "SELECT COUNT(*) FROM products p WHERE p.price >= ?", []interface{}{100}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.raw, func(t *testing.T) {
+			values, _ := url.ParseQuery(tc.raw)
+
+			opts, err := Parse(values, deletable)
+
+			assert.Nil(t, err)
+
+			query, args := opts.Count("SELECT COUNT(*) FROM products p")
+
+			assert.Equal(t, tc.query, query)
+			assert.Equal(t, tc.args, args)
+		})
+	}
+
+	t.Run("include_deleted=maybe", func(t *testing.T) {
+		values, _ := url.ParseQuery("include_deleted=maybe")
+
+		_, err := Parse(values, deletable)
+
+		assert.EqualError(t, err, "invalid include_deleted")
+	})
+
+	t.Run("include_deleted=true without DeletedAt", func(t *testing.T) {
+		values, _ := url.ParseQuery("include_deleted=true")
+
+		opts, err := Parse(values, spec)
+
+		assert.Nil(t, err)
+		assert.False(t, opts.IncludeDeleted)
+	})
+}
+
+func TestCursor(t *testing.T) {
+	t.Run("TestCursor", func(t *testing.T) {
+		values, _ := url.ParseQuery("sort=price&limit=2")
+
+		opts, _ := Parse(values, spec)
+		page := opts.NewPage()
+		page.More(250, 7)
+
+		values.Set("cursor", page.NextCursor)
+
+		opts, err := Parse(values, spec)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, opts.Page)
+
+		query, args := opts.Select("SELECT p.id FROM products p")
+
+		assert.Equal(t, "SELECT p.id FROM products p WHERE (p.price > ? OR (p.price = ? AND p.id > ?)) ORDER BY p.price ASC, p.id ASC LIMIT ?", query)
+		assert.Equal(t, []interface{}{"250", "250", 7, 3}, args)
+	})
+
+	t.Run("TestCursorOtherSort", func(t *testing.T) {
+		values, _ := url.ParseQuery("sort=price")
+
+		opts, _ := Parse(values, spec)
+		page := opts.NewPage()
+		page.More(250, 7)
+
+		values.Set("sort", "-price")
+		values.Set("cursor", page.NextCursor)
+
+		_, err := Parse(values, spec)
+
+		assert.EqualError(t, err, "invalid cursor")
+	})
+}
+
+func TestSetLinks(t *testing.T) {
+	t.Run("TestSetLinksPage", func(t *testing.T) {
+		u, _ := url.Parse("/products?merchant=user1&page=2&limit=10")
+
+		page := Page{Total: 35, Page: 2, Limit: 10}
+		page.SetLinks(u)
+
+		assert.Equal(t, Links{
+			Self:  "/products?merchant=user1&page=2&limit=10",
+			First: "/products?limit=10&merchant=user1&page=1",
+			Prev:  "/products?limit=10&merchant=user1&page=1",
+			Next:  "/products?limit=10&merchant=user1&page=3",
+			Last:  "/products?limit=10&merchant=user1&page=4",
+		}, page.Links)
+	})
+
+	t.Run("TestSetLinksCursor", func(t *testing.T) {
+		u, _ := url.Parse("/products?cursor=abc")
+
+		page := Page{Total: 35, Limit: 10, NextCursor: "def"}
+		page.SetLinks(u)
+
+		assert.Equal(t, Links{
+			Self: "/products?cursor=abc",
+			Next: "/products?cursor=def",
+		}, page.Links)
+	})
+}
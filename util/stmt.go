@@ -0,0 +1,115 @@
+package util
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// StmtCache lazily prepares statements on a database handle and keeps them
+// for reuse, keyed by their query text. A repository owns one cache so every
+// fixed query it issues goes through a parameterized, prepared statement.
+// Query texts built per request, such as filtered and sorted lists, go
+// through Query and QueryRow instead, still parameterized but not kept, so
+// their variety cannot exhaust the prepared statements the server allows.
+type StmtCache struct {
+	db    *sql.DB
+	store *stmtStore
+	tx    *Tx
+}
+
+type stmtStore struct {
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+func NewStmtCache(db *sql.DB) *StmtCache {
+	return &StmtCache{
+		db:    db,
+		store: &stmtStore{stmts: map[string]*sql.Stmt{}},
+	}
+}
+
+// WithTx returns a view of the cache whose statements are prepared within
+// tx instead.
+func (sc *StmtCache) WithTx(tx *Tx) *StmtCache {
+	return &StmtCache{db: sc.db, store: sc.store, tx: tx}
+}
+
+// Begin starts a transaction, or a savepoint when the cache is bound to one
+// already.
+func (sc *StmtCache) Begin(ctx context.Context) (*Tx, error) {
+	if sc.tx != nil {
+		return sc.tx.Begin(ctx)
+	}
+
+	return Begin(ctx, sc.db)
+}
+
+// Prepare returns the statement for query. The context only bounds the
+// preparation, a cached statement outlives it; pass the context again to
+// the statement's QueryContext or ExecContext.
+func (sc *StmtCache) Prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	if sc.tx != nil {
+		return sc.tx.Prepare(ctx, query)
+	}
+
+	return sc.store.prepare(ctx, sc.db, query)
+}
+
+// Query runs query without preparing it.
+func (sc *StmtCache) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if sc.tx != nil {
+		return sc.tx.Query(ctx, query, args...)
+	}
+
+	return sc.db.QueryContext(ctx, query, args...)
+}
+
+// QueryRow is Query for a single row.
+func (sc *StmtCache) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if sc.tx != nil {
+		return sc.tx.QueryRow(ctx, query, args...)
+	}
+
+	return sc.db.QueryRowContext(ctx, query, args...)
+}
+
+func (sc *StmtCache) Close() error {
+	return sc.store.close()
+}
+
+func (ss *stmtStore) prepare(ctx context.Context, db *sql.DB, query string) (*sql.Stmt, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if stmt, ok := ss.stmts[query]; ok {
+		return stmt, nil
+	}
+
+	stmt, err := db.PrepareContext(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ss.stmts[query] = stmt
+
+	return stmt, nil
+}
+
+func (ss *stmtStore) close() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	var firstErr error
+
+	for query, stmt := range ss.stmts {
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(ss.stmts, query)
+	}
+
+	return firstErr
+}
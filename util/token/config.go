@@ -0,0 +1,99 @@
+package token
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"rest-api/design-pattern/config"
+	"strings"
+)
+
+// FromConfig assembles the token service from jwt.secret and the key files
+// found in jwt.keys_dir. Outside production a random key is generated when
+// none is configured.
+func FromConfig(cfg *config.AppConfig) (*Service, error) {
+	keys := []*Key{}
+	active := cfg.JWTActiveKey
+
+	if cfg.JWTSecret != "" {
+		keys = append(keys, NewHMACKey(cfg.JWTKeyID, []byte(cfg.JWTSecret)))
+
+		if active == "" {
+			active = cfg.JWTKeyID
+		}
+	}
+
+	if cfg.JWTKeysDir != "" {
+		dirKeys, err := readKeysDir(cfg.JWTKeysDir)
+
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, dirKeys...)
+	}
+
+	if len(keys) == 0 {
+		if cfg.Type == config.Production {
+			return nil, fmt.Errorf("no signing key configured")
+		}
+
+		key, err := NewRandomHMACKey("ephemeral")
+
+		if err != nil {
+			return nil, err
+		}
+
+		keys, active = append(keys, key), key.ID
+	}
+
+	if active == "" && len(keys) == 1 {
+		active = keys[0].ID
+	}
+
+	return New(keys, active, cfg.AccessTokenTTL)
+}
+
+func readKeysDir(dir string) ([]*Key, error) {
+	entries, err := ioutil.ReadDir(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []*Key{}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		ext := filepath.Ext(entry.Name())
+		id := strings.TrimSuffix(entry.Name(), ext)
+
+		if ext != ".pem" && ext != ".secret" {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+
+		if err != nil {
+			return nil, err
+		}
+
+		if ext == ".secret" {
+			keys = append(keys, NewHMACKey(id, []byte(strings.TrimSpace(string(data)))))
+			continue
+		}
+
+		key, err := ParsePEMKey(id, data)
+
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,126 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+// Key is a single signing key identified by the kid header of the tokens it
+// signs. Asymmetric keys loaded from a public key only can verify tokens but
+// not sign them, which is how keys issued elsewhere are trusted.
+type Key struct {
+	ID        string
+	Algorithm string
+	secret    []byte
+	private   crypto.Signer
+	public    crypto.PublicKey
+}
+
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{ID: id, Algorithm: HS256, secret: secret}
+}
+
+// NewRandomHMACKey generates a key that only lives as long as the process,
+// good enough for development and tests where no secret is configured.
+func NewRandomHMACKey(id string) (*Key, error) {
+	secret := make([]byte, 32)
+
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	return NewHMACKey(id, secret), nil
+}
+
+// ParsePEMKey reads an RSA or P-256 ECDSA key, private (PKCS#1, SEC 1 or
+// PKCS#8) or public (PKIX), and derives the algorithm from its type.
+func ParsePEMKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+
+	if block == nil {
+		return nil, fmt.Errorf("key %v: no PEM block found", id)
+	}
+
+	var parsed interface{}
+	var err error
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key %v: unsupported PEM block %v", id, block.Type)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("key %v: %v", id, err)
+	}
+
+	return newAsymmetricKey(id, parsed)
+}
+
+func newAsymmetricKey(id string, parsed interface{}) (*Key, error) {
+	key := &Key{ID: id}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Algorithm, key.private, key.public = RS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Algorithm, key.public = RS256, k
+	case *ecdsa.PrivateKey:
+		key.Algorithm, key.private, key.public = ES256, k, &k.PublicKey
+	case *ecdsa.PublicKey:
+		key.Algorithm, key.public = ES256, k
+	default:
+		return nil, fmt.Errorf("key %v: unsupported key type %T", id, parsed)
+	}
+
+	if ec, ok := key.public.(*ecdsa.PublicKey); ok && ec.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("key %v: ES256 requires a P-256 curve", id)
+	}
+
+	return key, nil
+}
+
+func (k *Key) CanSign() bool {
+	return k.secret != nil || k.private != nil
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+func (k *Key) signingKey() interface{} {
+	if k.secret != nil {
+		return k.secret
+	}
+
+	return k.private
+}
+
+func (k *Key) verificationKey() interface{} {
+	if k.secret != nil {
+		return k.secret
+	}
+
+	return k.public
+}
@@ -0,0 +1,123 @@
+package token
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Service signs access tokens with its active key and verifies tokens signed
+// by any of its keys, so a new key can be rolled out while tokens issued with
+// the previous one stay valid until they expire.
+type Service struct {
+	keys   map[string]*Key
+	active *Key
+	ttl    time.Duration
+}
+
+func New(keys []*Key, active string, ttl time.Duration) (*Service, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("token lifetime must be positive")
+	}
+
+	s := &Service{keys: map[string]*Key{}, ttl: ttl}
+
+	for _, key := range keys {
+		if _, ok := s.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %v", key.ID)
+		}
+
+		s.keys[key.ID] = key
+	}
+
+	s.active = s.keys[active]
+
+	if s.active == nil {
+		return nil, fmt.Errorf("active key %v not found", active)
+	}
+
+	if !s.active.CanSign() {
+		return nil, fmt.Errorf("active key %v has no private part", active)
+	}
+
+	return s, nil
+}
+
+func (s *Service) TTL() time.Duration {
+	return s.ttl
+}
+
+// Create signs claims with the active key, stamping the kid header and the
+// iat and exp claims, plus a random jti unless the caller set one.
+func (s *Service) Create(claims jwt.MapClaims) (string, error) {
+	if _, ok := claims["jti"]; !ok {
+		jti, err := randomID()
+
+		if err != nil {
+			return "", err
+		}
+
+		claims["jti"] = jti
+	}
+
+	now := time.Now()
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(s.ttl).Unix()
+
+	token := jwt.NewWithClaims(s.active.method(), claims)
+	token.Header["kid"] = s.active.ID
+
+	return token.SignedString(s.active.signingKey())
+}
+
+// Keyfunc resolves the verification key of a token from its kid header and
+// rejects tokens whose algorithm differs from the one the key is meant for.
+func (s *Service) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %v", kid)
+	}
+
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method %v", token.Method.Alg())
+	}
+
+	return key.verificationKey(), nil
+}
+
+func (s *Service) Parse(signed string) (*jwt.Token, error) {
+	return jwt.Parse(signed, s.Keyfunc)
+}
+
+// JWKS lists the public keys in JWK form. Symmetric keys are never published.
+func (s *Service) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	for _, key := range s.keys {
+		if jwk, ok := key.jwk(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+
+	return set
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,135 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func rsaPEM() []byte {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+func ecPEM() []byte {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalECPrivateKey(key)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func TestCreateAndParse(t *testing.T) {
+	rsaKey, err := ParsePEMKey("rsa", rsaPEM())
+	assert.NoError(t, err)
+
+	ecKey, err := ParsePEMKey("ec", ecPEM())
+	assert.NoError(t, err)
+
+	for _, key := range []*Key{NewHMACKey("hmac", []byte("a-very-long-secret")), rsaKey, ecKey} {
+		t.Run(key.Algorithm, func(t *testing.T) {
+			s, err := New([]*Key{key}, key.ID, time.Hour)
+			assert.NoError(t, err)
+
+			signed, err := s.Create(jwt.MapClaims{"id": 1})
+			assert.NoError(t, err)
+
+			parsed, err := s.Parse(signed)
+			assert.NoError(t, err)
+			assert.True(t, parsed.Valid)
+			assert.Equal(t, key.ID, parsed.Header["kid"])
+			assert.Equal(t, key.Algorithm, parsed.Method.Alg())
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	t.Run("TestRotation", func(t *testing.T) {
+		oldKey := NewHMACKey("2021-01", []byte("old-secret-old-secret"))
+		newKey, _ := ParsePEMKey("2021-02", ecPEM())
+
+		before, _ := New([]*Key{oldKey}, oldKey.ID, time.Hour)
+		signed, _ := before.Create(jwt.MapClaims{"id": 1})
+
+		after, err := New([]*Key{oldKey, newKey}, newKey.ID, time.Hour)
+		assert.NoError(t, err)
+
+		parsed, err := after.Parse(signed)
+		assert.NoError(t, err)
+		assert.True(t, parsed.Valid)
+
+		signed, _ = after.Create(jwt.MapClaims{"id": 1})
+		parsed, _ = after.Parse(signed)
+		assert.Equal(t, "2021-02", parsed.Header["kid"])
+
+		_, err = before.Parse(signed)
+		assert.Error(t, err)
+	})
+}
+
+func TestRejectAlgorithmMismatch(t *testing.T) {
+	t.Run("TestRejectAlgorithmMismatch", func(t *testing.T) {
+		key := NewHMACKey("hmac", []byte("a-very-long-secret"))
+		s, _ := New([]*Key{key}, key.ID, time.Hour)
+
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{"id": 1})
+		forged.Header["kid"] = key.ID
+		signed, _ := forged.SignedString([]byte("a-very-long-secret"))
+
+		_, err := s.Parse(signed)
+		assert.Error(t, err)
+	})
+}
+
+func TestRejectExpired(t *testing.T) {
+	t.Run("TestRejectExpired", func(t *testing.T) {
+		key := NewHMACKey("hmac", []byte("a-very-long-secret"))
+		s, _ := New([]*Key{key}, key.ID, time.Hour)
+
+		expired := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": 1, "exp": time.Now().Add(-time.Minute).Unix()})
+		expired.Header["kid"] = key.ID
+		signed, _ := expired.SignedString([]byte("a-very-long-secret"))
+
+		_, err := s.Parse(signed)
+		assert.Error(t, err)
+	})
+}
+
+func TestJWKS(t *testing.T) {
+	t.Run("TestJWKS", func(t *testing.T) {
+		rsaKey, _ := ParsePEMKey("rsa", rsaPEM())
+		ecKey, _ := ParsePEMKey("ec", ecPEM())
+		hmacKey := NewHMACKey("hmac", []byte("a-very-long-secret"))
+
+		s, _ := New([]*Key{rsaKey, ecKey, hmacKey}, hmacKey.ID, time.Hour)
+		set := s.JWKS()
+
+		assert.Len(t, set.Keys, 2)
+		assert.Equal(t, "EC", set.Keys[0].Kty)
+		assert.Equal(t, "P-256", set.Keys[0].Crv)
+		assert.Len(t, set.Keys[0].X, 43)
+		assert.Equal(t, "RSA", set.Keys[1].Kty)
+		assert.Equal(t, "AQAB", set.Keys[1].E)
+	})
+}
+
+func TestPublicKeyCannotSign(t *testing.T) {
+	t.Run("TestPublicKeyCannotSign", func(t *testing.T) {
+		private, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		der, _ := x509.MarshalPKIXPublicKey(&private.PublicKey)
+
+		key, err := ParsePEMKey("public", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		assert.NoError(t, err)
+
+		_, err = New([]*Key{key}, key.ID, time.Hour)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,110 @@
+package util
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Tx is a database transaction, or a savepoint within one when begun from
+// another Tx. Commit and Rollback of a savepoint release it or roll back to
+// it, leaving the enclosing transaction open. Once a Tx is done, further
+// Commit or Rollback calls return sql.ErrTxDone, so Rollback can always be
+// deferred.
+//
+// Statements are prepared on the transaction's connection and kept until the
+// transaction ends, shared with its savepoints.
+type Tx struct {
+	tx        *sql.Tx
+	stmts     map[string]*sql.Stmt
+	savepoint string
+	count     *int
+	done      bool
+}
+
+// Begin starts a transaction, rolled back by the driver if ctx is done
+// before it is committed.
+func Begin(ctx context.Context, db *sql.DB) (*Tx, error) {
+	tx, err := db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tx{tx: tx, stmts: map[string]*sql.Stmt{}, count: new(int)}, nil
+}
+
+// Begin starts a savepoint within the transaction.
+func (t *Tx) Begin(ctx context.Context) (*Tx, error) {
+	if t.done {
+		return nil, sql.ErrTxDone
+	}
+
+	*t.count++
+	savepoint := fmt.Sprintf("sp_%d", *t.count)
+
+	if _, err := t.tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		return nil, err
+	}
+
+	return &Tx{tx: t.tx, stmts: t.stmts, savepoint: savepoint, count: t.count}, nil
+}
+
+// Prepare returns the statement for query within the transaction, preparing
+// it on first use.
+func (t *Tx) Prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	if stmt, ok := t.stmts[query]; ok {
+		return stmt, nil
+	}
+
+	stmt, err := t.tx.PrepareContext(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	t.stmts[query] = stmt
+
+	return stmt, nil
+}
+
+// Query runs query within the transaction without preparing it, for query
+// texts built per request.
+func (t *Tx) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, query, args...)
+}
+
+// QueryRow is Query for a single row.
+func (t *Tx) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRowContext(ctx, query, args...)
+}
+
+func (t *Tx) Commit() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+
+	t.done = true
+
+	if t.savepoint != "" {
+		_, err := t.tx.Exec("RELEASE SAVEPOINT " + t.savepoint)
+		return err
+	}
+
+	return t.tx.Commit()
+}
+
+func (t *Tx) Rollback() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+
+	t.done = true
+
+	if t.savepoint != "" {
+		_, err := t.tx.Exec("ROLLBACK TO SAVEPOINT " + t.savepoint)
+		return err
+	}
+
+	return t.tx.Rollback()
+}